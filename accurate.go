@@ -0,0 +1,177 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"sync"
+)
+
+// borderPolysPacked is the base64 of the gzip-compressed border
+// polygons used by LookupZoneNameAccurate, in the format of
+// encodeBorders. It's set by z_gen_borders.go, which the generator's
+// --generate_borders flag writes. Without it, there are no polygons.
+var borderPolysPacked string
+
+// Border polygons, once unpacked, by zone.
+var (
+	bordersOnce sync.Once
+	borders     map[string][]override
+)
+
+// LookupZoneNameAccurate is like LookupZoneName, but more accurate near
+// borders if the border polygons were generated.
+//
+// The polygons aren't part of this package as distributed: they must
+// be generated into z_gen_borders.go (see the README). Without that
+// file, LookupZoneNameAccurate is exactly LookupZoneName.
+//
+// With it, coordinates in a tile of the compiled-in tables that mixes
+// zones, or in one of the smallest, quarter degree tiles, are tested
+// against simplified polygons of the zones nearby (see
+// LookupZoneCandidates), and resolve to the first zone with a polygon
+// containing them. Elsewhere, and if no polygon contains the
+// coordinate, it returns what LookupZoneName does, so it's only slower
+// near borders.
+func LookupZoneNameAccurate(lat, long float64) string {
+	return defaultLookuper().lookupAccurate(loadBorders(), lat, long)
+}
+
+// lookupAccurate is LookupZoneNameAccurate, with the border polygons
+// polys.
+func (l *Lookuper) lookupAccurate(polys map[string][]override, lat, long float64) string {
+	if zone, ok := l.override(lat, long); ok {
+		return zone
+	}
+	if tk, _, ok := l.LookupTile(lat, long); ok && tk.Size > 8 {
+		return l.LookupName(lat, long)
+	}
+	if zone, ok := accurateZone(polys, lat, long); ok {
+		return zone
+	}
+	return l.LookupName(lat, long)
+}
+
+// accurateZone returns the first zone near the given coordinate with a
+// polygon in polys containing it.
+func accurateZone(polys map[string][]override, lat, long float64) (zone string, ok bool) {
+	if len(polys) == 0 {
+		return "", false
+	}
+	lat, long = NormalizeCoordinate(lat, long)
+	for _, zone := range LookupZoneCandidates(lat, long) {
+		for i := range polys[zone] {
+			if polys[zone][i].contains(lat, long) {
+				return zone, true
+			}
+		}
+	}
+	return "", false
+}
+
+// loadBorders returns the border polygons, unpacking them on first
+// use. It panics if they're corrupt.
+func loadBorders() map[string][]override {
+	bordersOnce.Do(func() {
+		if borderPolysPacked == "" {
+			return
+		}
+		zr, err := getGzipReader(base64Gzip(borderPolysPacked)())
+		check(err)
+		b, err := ioutil.ReadAll(zr)
+		gzipReaders.Put(zr)
+		check(err)
+		borders, err = decodeBorders(b)
+		check(err)
+	})
+	return borders
+}
+
+// borderUnit is the precision of encoded border polygon vertices, in
+// degrees: about a meter.
+const borderUnit = 1e-5
+
+// encodeBorders encodes each zone's polygons. Each polygon is
+//
+//	len   uvarint  length of the zone name
+//	zone  bytes    zone name
+//	n     uvarint  number of vertices
+//	then n × (lat, long) varint pairs: each vertex's latitude and
+//	longitude in borderUnits, minus the previous vertex's (or 0)
+//
+// A polygon may be several closed rings one after another, such as an
+// outer ring and its holes; see override.contains.
+func encodeBorders(zones []string, polys [][][2]float64) []byte {
+	var b []byte
+	for i, zone := range zones {
+		b = appendUvarint(b, uint64(len(zone)))
+		b = append(b, zone...)
+		b = appendUvarint(b, uint64(len(polys[i])))
+		var lat0, long0 int64
+		for _, v := range polys[i] {
+			lat, long := int64(math.Round(v[0]/borderUnit)), int64(math.Round(v[1]/borderUnit))
+			b = appendVarint(b, lat-lat0)
+			b = appendVarint(b, long-long0)
+			lat0, long0 = lat, long
+		}
+	}
+	return b
+}
+
+var errBadBorders = errors.New("latlong: bogus border polygons")
+
+// decodeBorders decodes the output of encodeBorders.
+func decodeBorders(b []byte) (map[string][]override, error) {
+	polys := map[string][]override{}
+	br := bytes.NewReader(b)
+	for br.Len() > 0 {
+		n, err := binary.ReadUvarint(br)
+		if err != nil || n > uint64(br.Len()) {
+			return nil, errBadBorders
+		}
+		zone := make([]byte, n)
+		br.Read(zone)
+		n, err = binary.ReadUvarint(br)
+		if err != nil || n > uint64(br.Len()/2) { // each vertex takes at least 2 bytes
+			return nil, errBadBorders
+		}
+		poly := make([][2]float64, n)
+		var lat, long int64
+		for i := range poly {
+			dlat, err1 := binary.ReadVarint(br)
+			dlong, err2 := binary.ReadVarint(br)
+			if err1 != nil || err2 != nil {
+				return nil, errBadBorders
+			}
+			lat, long = lat+dlat, long+dlong
+			poly[i] = [2]float64{float64(lat) * borderUnit, float64(long) * borderUnit}
+		}
+		if o, ok := newOverride(string(zone), poly); ok {
+			polys[o.zone] = append(polys[o.zone], o)
+		}
+	}
+	return polys, nil
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
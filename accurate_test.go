@@ -0,0 +1,81 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import "testing"
+
+func TestLookupZoneNameAccurate(t *testing.T) {
+	coords := append(trackCoords(200),
+		[2]float64{47.56, 7.59}, // Basel, on a tile shared with Germany and France
+		[2]float64{46.8, 8.2},   // central Switzerland
+	)
+	// The compiled-in tables have no border polygons.
+	for _, c := range coords {
+		if got, want := LookupZoneNameAccurate(c[0], c[1]), LookupZoneName(c[0], c[1]); got != want {
+			t.Errorf("LookupZoneNameAccurate(%v, %v) = %q; want %q, as without polygons", c[0], c[1], got, want)
+		}
+	}
+
+	// Pretend central Basel and all of central Switzerland are in
+	// Germany.
+	box := func(lat0, long0, lat1, long1 float64) [][2]float64 {
+		return [][2]float64{{lat0, long0}, {lat0, long1}, {lat1, long1}, {lat1, long0}, {lat0, long0}}
+	}
+	polys, err := decodeBorders(encodeBorders(
+		[]string{"Europe/Berlin", "Europe/Berlin"},
+		[][][2]float64{box(47.55, 7.58, 47.57, 7.60), box(46, 7, 47, 9)},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := defaultLookuper()
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{47.56, 7.59, "Europe/Berlin"},
+		{47.55, 7.65, "Europe/Zurich"}, // outside the polygon; as the tables say
+		{46.8, 8.2, "Europe/Zurich"},   // in a large tile, so the polygons aren't checked
+	}
+	for _, tt := range tests {
+		if got := l.lookupAccurate(polys, tt.lat, tt.long); got != tt.want {
+			t.Errorf("lookupAccurate(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeBorders(t *testing.T) {
+	poly := [][2]float64{{1, 2}, {1.5, -3.25}, {-4.00001, 2}, {1, 2}}
+	b := encodeBorders([]string{"A", "B"}, [][][2]float64{poly, poly[:2]})
+	polys, err := decodeBorders(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polys) != 1 || len(polys["A"]) != 1 {
+		t.Fatalf("decoded %v; want just A's polygon", polys)
+	}
+	for i, v := range polys["A"][0].poly {
+		if d := v[0] - poly[i][0] + v[1] - poly[i][1]; d > borderUnit || d < -borderUnit {
+			t.Errorf("vertex %d = %v; want %v", i, v, poly[i])
+		}
+	}
+	for n := 1; n < len(b); n++ {
+		if _, err := decodeBorders(b[:n]); err == nil && n != len(b)-len(encodeBorders([]string{"B"}, [][][2]float64{poly[:2]})) {
+			t.Errorf("decoding %d of %d bytes succeeded", n, len(b))
+		}
+	}
+}
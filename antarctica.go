@@ -0,0 +1,108 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+// The tz_world data has no zones in Antarctica: the continent is all
+// "uninhabited", which the generator skips. Instead, coordinates on
+// the continent (south of a coarse outline of its coast and ice
+// shelves) that the tables don't cover resolve to the zone of the
+// nearest research station that has one.
+//
+// That's only an approximation. Stations keep whatever time suits
+// their supply routes, not their longitude, and the rest of the
+// continent has no official time at all. The Southern Ocean north of
+// the outline has no zone, like other seas.
+
+// antarcticLat is the latitude south of which antarcticZone may
+// apply: the Antarctic Treaty's limit, north of all of the continent.
+const antarcticLat = -60
+
+// antarcticCoast is the outline of the continent for antarcticZone:
+// for each 10 degrees of longitude from 180°W, about the northernmost
+// latitude of its coast or ice shelves there. It's rough, and counts
+// bays such as the Ross Sea's west as land, but it keeps the fallback
+// off the open ocean.
+var antarcticCoast = [36]float64{
+	-78, -78, -77, -75.5, -74.5, -73.5, -73.5, -73.5, -71.8, -72.5, // 180°W to 80°W
+	-69, -63.5, -63, -77.5, -77.5, -75, -72, -70, // 80°W to 0°
+	-69.5, -69.8, -70, -68.5, -66, -65.8, -67, -68, -66, -64.5, // 0° to 100°E
+	-65.5, -65.8, -65.5, -65.8, -66.3, -68.8, -70.3, -71.3, // 100°E to 180°
+}
+
+// onAntarctica reports whether the given coordinate is south of
+// antarcticCoast.
+func onAntarctica(lat, long float64) bool {
+	if !(lat < antarcticLat) {
+		return false
+	}
+	band := int((wrapLong(long) + 180) / 10)
+	if band < 0 || band >= len(antarcticCoast) {
+		return false // NaN
+	}
+	return lat <= antarcticCoast[band]
+}
+
+// antarcticStations are the stations with their own zones in the tz
+// database's zone.tab (release 2025b), plus the Amundsen-Scott South
+// Pole Station, which keeps New Zealand time like McMurdo.
+var antarcticStations = []struct {
+	lat, long float64
+	zone      string
+}{
+	{-77.8333, 166.6, "Antarctica/McMurdo"},
+	{-90, 0, "Antarctica/McMurdo"}, // South Pole
+	{-66.2833, 110.5167, "Antarctica/Casey"},
+	{-68.5833, 77.9667, "Antarctica/Davis"},
+	{-66.6667, 140.0167, "Antarctica/DumontDUrville"},
+	{-67.6, 62.8833, "Antarctica/Mawson"},
+	{-64.8, -64.1, "Antarctica/Palmer"},
+	{-67.5667, -68.1333, "Antarctica/Rothera"},
+	{-69.0061, 39.59, "Antarctica/Syowa"},
+	{-72.0114, 2.5350, "Antarctica/Troll"},
+	{-78.4, 106.9, "Antarctica/Vostok"},
+}
+
+// antarcticZone returns the zone of the station nearest the given
+// coordinate, if it's on the continent, or else the empty string.
+func antarcticZone(lat, long float64) string {
+	if !onAntarctica(lat, long) {
+		return ""
+	}
+	if lat < -90 {
+		lat = -90
+	}
+	best, bestKm := "", 0.0
+	for _, s := range antarcticStations {
+		if d := haversineKm(lat, long, s.lat, s.long); best == "" || d < bestKm {
+			best, bestKm = s.zone, d
+		}
+	}
+	return best
+}
+
+// antarcticZoneNames returns the distinct zones antarcticZone returns.
+func antarcticZoneNames() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, s := range antarcticStations {
+		if !seen[s.zone] {
+			seen[s.zone] = true
+			names = append(names, s.zone)
+		}
+	}
+	return names
+}
@@ -0,0 +1,790 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// BuildFromGeoJSON returns a Lookuper for the regions of a GeoJSON
+// FeatureCollection read from r, built in-process the way the
+// generator builds the compiled-in timezone tables, for looking up
+// custom regions without generating tables ahead of time. Each feature
+// is a region named by its nameProp property, which must be a string,
+// and its geometry must be a Polygon or MultiPolygon. Features with the
+// same name make up one region, and where regions overlap, later
+// features win.
+//
+// The regions are rasterized at scale pixels per degree, which must be
+// a whole number: the compiled-in tables use 32, about 3.5 km at the
+// equator. Pixels are in a region if their centers are. As with the
+// compiled-in tables, where they extend timezones over coastal waters,
+// a tile of a single region and parts of none is taken to be all that
+// region, so regions may reach up to a tile, 256 pixels at most, past
+// their edges, over area no region covers. Building needs
+// about 4*360*180*scale*scale bytes of memory for the world image,
+// 265 MB at scale 32, and several seconds at that scale.
+func BuildFromGeoJSON(r io.Reader, nameProp string, scale float64) (*Lookuper, error) {
+	degPixels := int(scale)
+	if float64(degPixels) != scale || degPixels < 1 {
+		return nil, fmt.Errorf("latlong: scale %v isn't a positive whole number", scale)
+	}
+	if 360*degPixels/8 > 1<<14 {
+		return nil, fmt.Errorf("latlong: scale %v is too big", scale)
+	}
+	features, err := readGeoJSON(r, nameProp)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tiles are 8 pixels square or bigger, so round the height
+	// up to whole tiles. Lookups never reach the extra rows.
+	width, height := 360*degPixels, (180*degPixels+7)/8*8
+	im := image.NewRGBA(image.Rect(0, 0, width, height))
+	zoneOfColor := map[color.RGBA]string{}
+	colorOfZone := map[string]color.RGBA{}
+	for _, f := range features {
+		col, ok := colorOfZone[f.name]
+		if !ok {
+			col = indexColor(len(colorOfZone) + 1)
+			colorOfZone[f.name] = col
+			zoneOfColor[col] = f.name
+		}
+		for _, rings := range f.polygons {
+			fillPolygon(im, rings, col, float64(degPixels))
+		}
+	}
+	g, err := tileImage(im, nil, zoneOfColor, false, maxSizeShift, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewLookuper(degPixels, g.levels, g.zoneLookers.Packed())
+}
+
+// A geoJSONFeature is a feature read by readGeoJSON.
+type geoJSONFeature struct {
+	name     string
+	polygons [][][][2]float64 // polygons of rings of [long, lat] positions
+}
+
+// readGeoJSON reads the features of a GeoJSON FeatureCollection for
+// BuildFromGeoJSON.
+func readGeoJSON(r io.Reader, nameProp string) ([]geoJSONFeature, error) {
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   *struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("latlong: reading GeoJSON: %v", err)
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("latlong: GeoJSON type %q isn't a FeatureCollection", fc.Type)
+	}
+	features := make([]geoJSONFeature, len(fc.Features))
+	for i, ft := range fc.Features {
+		f := &features[i]
+		f.name, _ = ft.Properties[nameProp].(string)
+		if f.name == "" {
+			return nil, fmt.Errorf("latlong: GeoJSON feature %d has no %q string property", i, nameProp)
+		}
+		g := ft.Geometry
+		if g == nil {
+			return nil, fmt.Errorf("latlong: GeoJSON feature %q has no geometry", f.name)
+		}
+		var err error
+		switch g.Type {
+		case "Polygon":
+			var rings [][][2]float64
+			err = json.Unmarshal(g.Coordinates, &rings)
+			f.polygons = [][][][2]float64{rings}
+		case "MultiPolygon":
+			err = json.Unmarshal(g.Coordinates, &f.polygons)
+		default:
+			return nil, fmt.Errorf("latlong: GeoJSON feature %q has unsupported geometry type %q", f.name, g.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("latlong: bad %s for GeoJSON feature %q: %v", g.Type, f.name, err)
+		}
+	}
+	return features, nil
+}
+
+// fillPolygon paints the pixels of im whose centers are in the polygon
+// with the given rings of [long, lat] positions, holes included, with
+// col. It uses the even-odd rule, so holes are holes whichever way
+// they wind.
+func fillPolygon(im *image.RGBA, rings [][][2]float64, col color.RGBA, scale float64) {
+	// pixelY returns the y position, in pixels, of latitude lat.
+	pixelY := func(lat float64) float64 { return (90 - lat) * scale }
+
+	// Rows whose centers, y+0.5, are in [minY, maxY) may be in the
+	// polygon.
+	minY, maxY := im.Bounds().Dy(), 0
+	for _, ring := range rings {
+		for _, pos := range ring {
+			y := int(math.Ceil(pixelY(pos[1]) - 0.5))
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+		}
+	}
+	minY, maxY = clampInt(minY, 0, im.Bounds().Dy()), clampInt(maxY, 0, im.Bounds().Dy())
+	if minY >= maxY {
+		return
+	}
+
+	// crossings[y-minY] are the x positions, in pixels, where the
+	// rings' edges cross the center line of pixel row y.
+	crossings := make([][]float64, maxY-minY)
+	for _, ring := range rings {
+		for i := range ring {
+			a, b := ring[i], ring[(i+1)%len(ring)]
+			ax, ay := (a[0]+180)*scale, pixelY(a[1])
+			bx, by := (b[0]+180)*scale, pixelY(b[1])
+			if ay > by {
+				ax, ay, bx, by = bx, by, ax, ay
+			}
+			y0 := clampInt(int(math.Ceil(ay-0.5)), minY, maxY)
+			y1 := clampInt(int(math.Ceil(by-0.5)), minY, maxY)
+			for y := y0; y < y1; y++ {
+				yc := float64(y) + 0.5
+				x := ax + (yc-ay)*(bx-ax)/(by-ay)
+				crossings[y-minY] = append(crossings[y-minY], x)
+			}
+		}
+	}
+	width := im.Bounds().Dx()
+	for i, xs := range crossings {
+		sort.Float64s(xs)
+		for j := 0; j+1 < len(xs); j += 2 {
+			// Pixels whose centers, x+0.5, are in [xs[j], xs[j+1]).
+			x0 := clampInt(int(math.Ceil(xs[j]-0.5)), 0, width)
+			x1 := clampInt(int(math.Ceil(xs[j+1]-0.5)), 0, width)
+			for x := x0; x < x1; x++ {
+				im.SetRGBA(x, minY+i, col)
+			}
+		}
+	}
+}
+
+// clampInt returns v clamped to [lo, hi].
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+const alphaErased = 22 // magic alpha value to mean tile's been erased
+
+// indexColor returns the color of the i'th zone, for i > 0.
+// Multiplying by an odd constant modulo 1<<24 maps distinct indexes
+// to distinct colors, while spreading them out so neighboring zones
+// are easy to tell apart in debug images.
+func indexColor(i int) color.RGBA {
+	v := uint32(i) * 0x9e3779 & (1<<24 - 1)
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
+
+// A setIndexTracker that tells each index which item number it is, and can
+// retrieve that item's index later as well.
+type setIndexTracker struct {
+	s map[interface{}]uint16
+	l []interface{}
+}
+
+func (s *setIndexTracker) Lookup(v interface{}) (idx uint16, ok bool) {
+	idx, ok = s.s[v]
+	return
+}
+
+func (s *setIndexTracker) Add(v interface{}) (idx uint16, isNew bool) {
+	if idx, ok := s.s[v]; ok {
+		return idx, false
+	}
+
+	if len(s.s) > 0xffff {
+		panic("too many items in set")
+	}
+	idx = uint16(len(s.s))
+	if s.s == nil {
+		s.s = make(map[interface{}]uint16)
+	}
+	s.s[v] = idx
+	s.l = append(s.l, v)
+	return idx, true
+}
+
+// generatedTables are the tables tileImage builds.
+type generatedTables struct {
+	levels      [6][]byte // gzip-compressed tile index of each size
+	zoneLookers zoneLookerWriter
+	sum         genSummary             // without Source, Scale and LostZones
+	zoneTiles   map[string]map[int]int // zone -> tile size -> solid tiles, for --zone_tiles_file
+	tiledZones  map[string]bool        // zones some tile resolves to
+}
+
+var errTooManyLeaves = errors.New("latlong: too many distinct regions and border tiles for the tables")
+
+// maxSizeShift is the size shift of the biggest tiles tileImage can
+// make, 256 pixels square. A tileKey's size bits have room for two
+// more, but the tables are passed around with a level for each of
+// the six sizes up to this.
+const maxSizeShift = 5
+
+// tileImage builds the tables for the world image im, whose colors
+// are the zones of zoneOfColor, for the generator and BuildFromGeoJSON.
+// It erases tiles of im as it goes. If imo is non-nil, the tiles are
+// drawn on it, for the generator's --write_image. If compact is true,
+// the tile indexes are in the version 2 format; see compact.go. The
+// biggest tiles are 8<<maxShift pixels square; the levels of bigger
+// sizes are left empty. Fewer sizes make bigger tables of smaller
+// tiles, each more often only one zone. If logf is non-nil,
+// statistics about each size of tiles are logged with it.
+func tileImage(im, imo *image.RGBA, zoneOfColor map[color.RGBA]string, compact bool, maxShift uint8, logf func(format string, args ...interface{})) (*generatedTables, error) {
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+	if maxShift > maxSizeShift {
+		return nil, fmt.Errorf("latlong: tiles can't be bigger than %d pixels square", 8<<maxSizeShift)
+	}
+	// tileKey has 14 bits for each of a tile's x and y positions.
+	if xtiles := im.Bounds().Dx() / 8; xtiles > 1<<14 {
+		return nil, fmt.Errorf("latlong: image too big: %d tiles across doesn't fit in a tileKey", xtiles)
+	}
+
+	g := &generatedTables{
+		zoneTiles:  map[string]map[int]int{},
+		tiledZones: map[string]bool{},
+	}
+	zoneLookers := &g.zoneLookers
+	sum := &g.sum
+
+	// Maps from a unique key (either a string or colorTile) to
+	// its index.
+	var zoneIndex setIndexTracker
+
+	zoneIndexOfColor := func(c color.RGBA) uint16 {
+		if (c == color.RGBA{}) {
+			return oceanIndex
+		}
+		idx, ok := zoneIndex.Lookup(zoneOfColor[c])
+		if !ok {
+			panic(fmt.Sprintf("failed to find zone index for color %+v", c))
+		}
+		return idx
+	}
+
+	// Add the static timezones (~408 of them). If a tile (which
+	// can range from 8 to 8<<maxShift pixels square) doesn't resolve to
+	// one of these, it'll resolve to an image tile that then
+	// resolves to one of these.
+	{
+		var zones []string
+		for _, zone := range zoneOfColor {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+		for i, zone := range zones {
+			idx, _ := zoneIndex.Add(zone)
+			if idx != uint16(i) {
+				panic("unexpected")
+			}
+			zoneLookers.Add("S" + zone)
+		}
+		logf("Num zones = %d", len(zones))
+		sum.Zones = len(zones)
+	}
+
+	dupColorTiles := 0
+	tiledZones := g.tiledZones
+	zoneTiles := g.zoneTiles
+	var err error // from adding leaves
+
+	for s := maxShift + 1; s <= maxSizeShift; s++ {
+		g.levels[s] = gzipBlob(nil)
+	}
+	for s := int(maxShift); s >= 0; s-- {
+		sizeShift := uint8(s)
+		var keyIdxBuf bytes.Buffer // repeated binary [tilekey][uint16_idx]
+
+		pass := newSizePass(im, imo, sizeShift)
+
+		// addTile appends a tile to keyIdxBuf. The runtime
+		// binary searches each zoom level's keys, so they must
+		// be written in increasing order. foreachTile's row-major
+		// walk does that, since a key's y bits are above its x
+		// bits, but check.
+		var lastKey tileKey
+		var keys []tileKey // for --compact
+		var idxs []uint16
+		addTile := func(tk tileKey, idx uint16) {
+			if keyIdxBuf.Len() > 0 && tk <= lastKey {
+				panic(fmt.Sprintf("size %d: tile key %x written after %x; keys must be sorted", pass.size, tk, lastKey))
+			}
+			lastKey = tk
+			keys = append(keys, tk)
+			idxs = append(idxs, idx)
+			binary.Write(&keyIdxBuf, binary.BigEndian, tk)
+			binary.Write(&keyIdxBuf, binary.BigEndian, idx)
+		}
+
+		skipSquares := 0
+		sizeCount := map[int]int{} // num colors -> count
+
+		pass.foreachTile(func(tile *tileMeta) {
+			if tile.skipped {
+				skipSquares++
+				return
+			}
+			nColor := len(tile.colors)
+			sizeCount[nColor]++
+			if nColor < 2 {
+				tile.erase()
+			}
+			if nColor == 1 {
+				zoneName := zoneOfColor[tile.color()]
+				tiledZones[zoneName] = true
+				if zoneTiles[zoneName] == nil {
+					zoneTiles[zoneName] = map[int]int{}
+				}
+				zoneTiles[zoneName][pass.size]++
+				if idx, isNew := zoneIndex.Add(zoneName); isNew {
+					panic("zone should've been registered: " + zoneName)
+				} else {
+					addTile(tile.key(), idx)
+				}
+				if imo != nil {
+					tile.drawBorder()
+				}
+				return
+			}
+			if nColor == 0 {
+				// Ocean tiles need nothing but the erase above,
+				// unless they're drawn for --write_image.
+				if imo != nil {
+					tile.paintOcean()
+				}
+				return
+			}
+			if sizeShift == 0 && nColor >= 2 {
+				for c := range tile.colors {
+					if (c != color.RGBA{}) {
+						tiledZones[zoneOfColor[c]] = true
+					}
+				}
+				ct := tile.colorTile()
+				if _, ok := zoneIndex.Lookup(ct); !ok && zoneLookers.n >= int(oceanIndex) {
+					err = errTooManyLeaves
+					return
+				}
+				idx, isNew := zoneIndex.Add(ct)
+				if isNew {
+					if nColor == 2 {
+						zoneLookers.Add(fmt.Sprintf("2%s", pass.bitmapPixmapBytes(ct, zoneIndexOfColor)))
+					} else {
+						zoneLookers.Add(fmt.Sprintf("P%s", pass.pixmapIndexBytes(ct, zoneIndexOfColor)))
+					}
+				} else {
+					dupColorTiles++
+				}
+				addTile(tile.key(), idx)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		logf("For size %d, skipped %d, dist: %+v", pass.size, skipSquares, sizeCount)
+
+		index := keyIdxBuf.Bytes()
+		if compact {
+			v2 := encodeLevelV2(keys, idxs)
+			logf("size %d version 2 index: %d bytes (%d bytes compressed), vs %d (%d)", pass.size, len(v2), gzipLen(v2), len(index), gzipLen(index))
+			index = v2
+		}
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		zw.Write(index)
+		zw.Close()
+
+		logf("size %d is %d entries: %d bytes (%d bytes compressed)", pass.size, keyIdxBuf.Len()/6, len(index), zbuf.Len())
+		sum.Levels[sizeShift] = levelSummary{
+			TileSize:        pass.size,
+			Skipped:         skipSquares,
+			Tiles:           keyIdxBuf.Len() / 6,
+			Bytes:           len(index),
+			CompressedBytes: zbuf.Len(),
+		}
+
+		g.levels[sizeShift] = zbuf.Bytes()
+	}
+
+	logf("Duplicate 8x8 pixmaps: %d", dupColorTiles)
+	sum.DuplicatePixmaps = dupColorTiles
+	sum.Leaves = zoneLookers.n
+	sum.CompressedBytes = len(zoneLookers.Packed())
+	for _, b := range g.levels {
+		sum.CompressedBytes += len(b)
+	}
+	return g, nil
+}
+
+// gzipLen returns the gzip-compressed size of b.
+func gzipLen(b []byte) int {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Len()
+}
+
+// A genSummary describes the generated tables, for the generator's
+// --summary_file.
+type genSummary struct {
+	Source           string
+	Scale            int
+	Zones            int             // zones in the source data
+	LostZones        []string        // source zones no tile resolves to
+	Levels           [6]levelSummary // by size shift, smallest tiles first
+	DuplicatePixmaps int             // 8x8 tiles sharing an earlier one's leaf
+	Leaves           int
+	CompressedBytes  int // of the tile indexes and leaves
+}
+
+// A levelSummary describes one zoom level of the generated tables.
+type levelSummary struct {
+	TileSize        int // in pixels
+	Skipped         int // tiles already covered by a larger one
+	Tiles           int
+	Bytes           int // of the tile index
+	CompressedBytes int
+}
+
+type sizePass struct {
+	width, height  int
+	size           int // of tile. 8 << sizeShift
+	sizeShift      uint8
+	xtiles, ytiles int
+	im             *image.RGBA
+	imo            *image.RGBA // or nil if not generating an output image
+
+	buf [128]byte // for pixmap 8x8 uint16 indexes
+}
+
+func newSizePass(im, imo *image.RGBA, sizeShift uint8) *sizePass {
+	p := &sizePass{
+		width:     im.Bounds().Max.X,
+		height:    im.Bounds().Max.Y,
+		im:        im,
+		imo:       imo,
+		sizeShift: sizeShift,
+		size:      int(8 << sizeShift),
+	}
+	p.xtiles = p.width / p.size
+	p.ytiles = p.height / p.size
+	return p
+}
+
+// foreachTile calls fn for each tile of the pass, in row-major order.
+//
+// Finding each tile's colors is the slow part, and only reads that
+// tile's pixels, so it's done for a batch of tile rows in parallel.
+// fn is then called serially, in the same order as if the whole pass
+// were serial, so the output doesn't depend on the number of CPUs.
+func (p *sizePass) foreachTile(fn func(*tileMeta)) {
+	batch := runtime.NumCPU() * 4
+	rows := make([][]tileMeta, batch) // reused between batches
+	for yt0 := 0; yt0 < p.ytiles; yt0 += batch {
+		n := batch
+		if yt0+n > p.ytiles {
+			n = p.ytiles - yt0
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				rows[i] = p.scanRow(yt0+i, rows[i])
+			}(i)
+		}
+		wg.Wait()
+		for _, row := range rows[:n] {
+			for i := range row {
+				fn(&row[i])
+			}
+		}
+	}
+}
+
+// scanRow finds the colors of each tile in row yt, reusing row's
+// memory if possible.
+func (p *sizePass) scanRow(yt int, row []tileMeta) []tileMeta {
+	im := p.im
+	if cap(row) < p.xtiles {
+		row = make([]tileMeta, p.xtiles)
+	}
+	row = row[:p.xtiles]
+	for xt := range row {
+		tm := &row[xt]
+		colors := tm.colors
+		if colors == nil {
+			colors = map[color.RGBA]bool{}
+		}
+		// wipe colors, so we can re-use it.
+		for k := range colors {
+			delete(colors, k)
+		}
+		*tm = tileMeta{p: p, xt: xt, yt: yt, colors: colors}
+		tm.setBounds()
+		sawOcean := false
+		x1, y1 := tm.x1, tm.y1
+	Pixels:
+		for y := tm.y0; y < y1; y++ {
+			for x := tm.x0; x < x1; x++ {
+				off := im.PixOffset(x, y)
+				alpha := im.Pix[off+3]
+				switch alpha {
+				case 0:
+					sawOcean = true
+					continue
+				case alphaErased:
+					if x != tm.x0 || y != tm.y0 {
+						panic("unexpected")
+					}
+					tm.skipped = true
+					break Pixels
+				case 255:
+					// expected
+				default:
+					panic("Unexpected alpha value")
+				}
+				nc := color.RGBA{R: im.Pix[off], G: im.Pix[off+1], B: im.Pix[off+2], A: alpha}
+				colors[nc] = true
+			}
+		}
+		if len(colors) > 1 && sawOcean {
+			// note the ocean, since this can't be solid anyway
+			colors[color.RGBA{}] = true
+		}
+	}
+	return row
+}
+
+func (p *sizePass) pixmapIndexBytes(ct colorTile, fn func(color.RGBA) uint16) []byte {
+	buf := p.buf[:]
+	for _, row := range ct {
+		for _, c := range row {
+			binary.BigEndian.PutUint16(buf, fn(c))
+			buf = buf[2:]
+		}
+	}
+	return p.buf[:128]
+}
+
+// For two-color tiles.
+func (p *sizePass) bitmapPixmapBytes(ct colorTile, fn func(color.RGBA) uint16) []byte {
+	var c1, c2 color.RGBA
+	var bits uint64
+	var n uint8
+	for _, row := range ct {
+		for _, c := range row {
+			if n == 0 {
+				c1 = c
+			} else {
+				if c != c1 {
+					c2 = c
+					bits |= (1 << n)
+				}
+			}
+			n++
+		}
+	}
+	if c1 == c2 {
+		panic("didn't see two colors")
+	}
+	binary.BigEndian.PutUint16(p.buf[0:2], fn(c1))
+	binary.BigEndian.PutUint16(p.buf[2:4], fn(c2))
+	binary.BigEndian.PutUint64(p.buf[4:12], bits)
+	return p.buf[:12]
+}
+
+type tileMeta struct {
+	p      *sizePass
+	xt, yt int
+
+	x0, x1, y0, y1 int
+
+	// skipped reports whether the tile was skipped due to seeing
+	// erasure from previous level.
+	skipped bool
+
+	// colors will only contain a zero color if the tile size is smallest
+	// (8x8) and there's an ocean (zero color) and two others. If there's
+	// an ocean and only 1 other color, only one color will be returned.
+	colors map[color.RGBA]bool
+}
+
+func (t *tileMeta) setBounds() {
+	size := t.p.size
+	t.y0 = t.yt * size
+	t.y1 = (t.yt + 1) * size
+	t.x0 = t.xt * size
+	t.x1 = (t.xt + 1) * size
+}
+
+func (t *tileMeta) color() color.RGBA {
+	if len(t.colors) != 1 {
+		panic("color called with colors != 1")
+	}
+	var c color.RGBA
+	for c = range t.colors {
+		// get first (and only) key
+	}
+	if (c == color.RGBA{}) {
+		panic("no color found for tile")
+	}
+	return c
+}
+
+func (t *tileMeta) key() tileKey {
+	return newTileKey(t.p.sizeShift, uint16(t.xt), uint16(t.yt))
+}
+
+func (t *tileMeta) paintOcean() {
+	p := t.p
+	imo := p.imo
+	if imo == nil {
+		return
+	}
+	blue := [4]uint8{0, 0, 128, 255}
+	size := p.size
+	for y := t.y0; y < t.y1; y++ {
+		off := imo.PixOffset(t.x0, y)
+		for x := 0; x < size; x++ {
+			copy(imo.Pix[off:], blue[:])
+			off += 4
+		}
+	}
+}
+
+func (t *tileMeta) drawBorder() {
+	p := t.p
+	imo := p.imo
+	if imo == nil {
+		return
+	}
+
+	yellow := uint8(255 - (128 - byte(p.size)))
+	color := [4]uint8{yellow, yellow, 0, 255}
+
+	for y := t.y0; y < t.y1; y++ {
+		off := imo.PixOffset(t.x0, y)
+		for x := t.x0; x < t.x1; x++ {
+			// Border:
+			if y == t.y0 || y == t.y1-1 || x == t.x0 || x == t.x1-1 {
+				copy(imo.Pix[off:], color[:])
+			}
+			off += 4
+		}
+	}
+}
+
+func (t *tileMeta) erase() {
+	im := t.p.im
+	for y := t.y0; y < t.y1; y += 8 {
+		for x := t.x0; x < t.x1; x += 8 {
+			off := im.PixOffset(x, y)
+			im.Pix[off+3] = alphaErased
+		}
+	}
+
+}
+
+func (t *tileMeta) colorTile() (ct colorTile) {
+	im := t.p.im
+	for y := range ct {
+		row := &ct[y]
+		pix := im.Pix[im.PixOffset(t.x0, t.y0+y):]
+		for x := range row {
+			row[x] = color.RGBA{pix[0], pix[1], pix[2], pix[3]}
+			pix = pix[4:]
+		}
+	}
+	return
+}
+
+type colorTile [8][8]color.RGBA
+
+type zoneLookerWriter struct {
+	unbuf bytes.Buffer
+	n     int
+}
+
+func (w *zoneLookerWriter) Add(s string) {
+	w.n++
+	if w.n > 0xffff {
+		panic("too many unique leaves")
+	}
+	w.unbuf.WriteString(s)
+	switch s[0] {
+	case 'S':
+		w.unbuf.WriteByte(0)
+	case '2':
+		if len(s) != 12+1 {
+			panic("unexpected length")
+		}
+	case 'P':
+		if len(s) != 128+1 {
+			panic("unexpected length")
+		}
+	default:
+		panic("unexpected type")
+	}
+}
+
+// Packed returns the gzip-compressed leaves.
+func (w *zoneLookerWriter) Packed() []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(w.unbuf.Bytes())
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// testGeoJSON has a square region with a hole in it, and a region of
+// two squares, one overlapping the first region's hole.
+const testGeoJSON = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"properties": {"name": "Square", "pop": 3},
+			"geometry": {
+				"type": "Polygon",
+				"coordinates": [
+					[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+					[[4, 4], [4, 6], [6, 6], [6, 4], [4, 4]]
+				]
+			}
+		},
+		{
+			"type": "Feature",
+			"properties": {"name": "Pair"},
+			"geometry": {
+				"type": "MultiPolygon",
+				"coordinates": [
+					[[[-50, -30], [-40, -30], [-40, -20], [-50, -30]]],
+					[[[8, 8], [12, 8], [12, 12], [8, 12], [8, 8]]]
+				]
+			}
+		}
+	]
+}`
+
+func TestBuildFromGeoJSON(t *testing.T) {
+	l, err := BuildFromGeoJSON(strings.NewReader(testGeoJSON), "name", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{2, 2, "Square"},
+		{7, 3, "Square"},
+		{5, 5, "Square"}, // in the hole, but in a tile of only Square
+		{9, 9, "Pair"},
+		{11, 11, "Pair"},
+		{-28, -42, "Pair"}, // in the triangle
+		{-22, -48, "Pair"}, // beside it, but in a tile of only Pair
+		{-25, -70, ""},
+		{40, 100, ""},
+		{-89.9, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := l.LookupName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	if got := strings.Join(l.Names(), ","); got != "Pair,Square" {
+		t.Errorf("Names = %q; want Pair,Square", got)
+	}
+}
+
+func TestBuildFromGeoJSONErrors(t *testing.T) {
+	tests := []struct {
+		json  string
+		scale float64
+		want  string
+	}{
+		{testGeoJSON, 2.5, "whole number"},
+		{testGeoJSON, 0, "whole number"},
+		{testGeoJSON, 1000, "too big"},
+		{`{"type": "Feature"}`, 4, "isn't a FeatureCollection"},
+		{`{"type": "FeatureCollection"`, 4, "reading GeoJSON"},
+		{`{"type": "FeatureCollection", "features": [{"properties": {"name": 1}}]}`, 4, `no "name" string property`},
+		{`{"type": "FeatureCollection", "features": [{"properties": {"name": "A"}}]}`, 4, "no geometry"},
+		{`{"type": "FeatureCollection", "features": [{"properties": {"name": "A"}, "geometry": {"type": "Point", "coordinates": [1, 2]}}]}`, 4, `unsupported geometry type "Point"`},
+		{`{"type": "FeatureCollection", "features": [{"properties": {"name": "A"}, "geometry": {"type": "Polygon", "coordinates": [1, 2]}}]}`, 4, "bad Polygon"},
+	}
+	for _, tt := range tests {
+		_, err := BuildFromGeoJSON(strings.NewReader(tt.json), "name", tt.scale)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("BuildFromGeoJSON(%.40q, %v) error = %v; want %q", tt.json, tt.scale, err, tt.want)
+		}
+	}
+}
+
+func TestFillPolygon(t *testing.T) {
+	// A 4 by 4 degree square with a 2 by 2 hole, at 1 pixel per
+	// degree, from 0 to 4 degrees east and 86 to 90 north: pixels
+	// 180 to 183 across and 0 to 3 down.
+	im := image.NewRGBA(image.Rect(0, 0, 360, 180))
+	col := color.RGBA{1, 2, 3, 255}
+	fillPolygon(im, [][][2]float64{
+		{{0, 86}, {4, 86}, {4, 90}, {0, 90}},
+		{{1, 87}, {1, 89}, {3, 89}, {3, 87}},
+	}, col, 1)
+	var got []string
+	for y := 0; y < 5; y++ {
+		var row []byte
+		for x := 179; x < 185; x++ {
+			if im.RGBAAt(x, y) == col {
+				row = append(row, '#')
+			} else {
+				row = append(row, '.')
+			}
+		}
+		got = append(got, string(row))
+	}
+	want := []string{
+		".####.",
+		".#..#.",
+		".#..#.",
+		".####.",
+		"......",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTileImageMaxShift(t *testing.T) {
+	// A world of no ocean, so every size of tiles resolves each
+	// pixel to its own zone, at 2 pixels per degree.
+	const degPixels = 2
+	zoneOfColor := map[color.RGBA]string{}
+	world := func() *image.RGBA {
+		im := image.NewRGBA(image.Rect(0, 0, 360*degPixels, 180*degPixels))
+		for y := 0; y < 180*degPixels; y++ {
+			for x := 0; x < 360*degPixels; x++ {
+				idx := 1 + x/100
+				if (x-300)*(x-300)+(y-150)*(y-150) < 2500 {
+					idx = 10
+				}
+				im.SetRGBA(x, y, indexColor(idx))
+				zoneOfColor[indexColor(idx)] = fmt.Sprintf("Zone%d", idx)
+			}
+		}
+		return im
+	}
+	full, err := tileImage(world(), nil, zoneOfColor, false, maxSizeShift, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := tileImage(world(), nil, zoneOfColor, false, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i <= maxSizeShift; i++ {
+		if n := small.sum.Levels[i].Tiles; n != 0 {
+			t.Errorf("max shift 2: level %d has %d tiles; want none", i, n)
+		}
+	}
+	if small.sum.Levels[2].Tiles <= full.sum.Levels[2].Tiles {
+		t.Errorf("max shift 2: %d tiles of size 32; want more than the %d with all sizes", small.sum.Levels[2].Tiles, full.sum.Levels[2].Tiles)
+	}
+	if _, err := tileImage(world(), nil, zoneOfColor, false, maxSizeShift+1, nil); err == nil {
+		t.Error("tileImage with too big a max shift succeeded")
+	}
+
+	lf, err := NewLookuper(degPixels, full.levels, full.zoneLookers.Packed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like compiled-in tables generated with --max_tile_size=32,
+	// without the empty levels of bigger tiles.
+	ls := &Lookuper{
+		degPixels: degPixels,
+		levelData: levelReaders(small.levels[:3]),
+		leafData:  bytesReader(small.zoneLookers.Packed()),
+	}
+	if err := ls.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ls.Stats()); got != 6 {
+		t.Errorf("len(Stats()) = %d; want 6", got)
+	}
+	for y := 0; y < 180*degPixels; y++ {
+		for x := 0; x < 360*degPixels; x++ {
+			lat := 90 - (float64(y)+0.5)/degPixels
+			long := (float64(x)+0.5)/degPixels - 180
+			want := zoneOfColor[indexColor(1+x/100)]
+			if (x-300)*(x-300)+(y-150)*(y-150) < 2500 {
+				want = "Zone10"
+			}
+			if got := lf.LookupName(lat, long); got != want {
+				t.Fatalf("all sizes: LookupName(%v, %v) = %q; want %q", lat, long, got, want)
+			}
+			if got := ls.LookupName(lat, long); got != want {
+				t.Fatalf("max shift 2: LookupName(%v, %v) = %q; want %q", lat, long, got, want)
+			}
+		}
+	}
+}
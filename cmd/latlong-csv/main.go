@@ -0,0 +1,184 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The latlong-csv command adds a timezone column to a CSV file of
+// coordinates.
+//
+// Usage:
+//
+//	latlong-csv [-lat-col=lat] [-lng-col=lng] [file]
+//
+// It reads the CSV file, or standard input without one, whose first
+// row is a header naming the columns, and writes it to standard output
+// with a "timezone" column added to the end of each row, holding the
+// zone at the row's latitude and longitude. The zone is empty for rows
+// whose coordinates are missing or invalid, and where
+// latlong.LookupZoneName finds none. Rows shorter than the header are
+// padded with empty cells, so the zone is always in its column.
+//
+// Rows are read and written a window at a time, so files of any size
+// can be processed. Each window's coordinates are looked up together
+// with latlong.LookupZoneNames, which is faster for nearby points in
+// order, as with GPS tracks.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/latlong"
+)
+
+var (
+	flagLatCol = flag.String("lat-col", "lat", "Name of the header's latitude column")
+	flagLngCol = flag.String("lng-col", "lng", "Name of the header's longitude column")
+)
+
+// window is how many rows are looked up together.
+const window = 256
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: latlong-csv [flags] [file]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "latlong-csv: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	default:
+		usage()
+	}
+	if err := augment(in, os.Stdout, *flagLatCol, *flagLngCol); err != nil {
+		fmt.Fprintf(os.Stderr, "latlong-csv: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// augment copies the CSV from r to w, adding a timezone column for the
+// coordinates in the latCol and lngCol columns.
+func augment(r io.Reader, w io.Writer, latCol, lngCol string) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // rows missing cells get no zone
+	cw := csv.NewWriter(w)
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return fmt.Errorf("no header row")
+	}
+	if err != nil {
+		return err
+	}
+	latIdx, lngIdx := column(header, latCol), column(header, lngCol)
+	if latIdx < 0 {
+		return fmt.Errorf("no %q column in header", latCol)
+	}
+	if lngIdx < 0 {
+		return fmt.Errorf("no %q column in header", lngCol)
+	}
+	if err := cw.Write(append(header, "timezone")); err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, window)
+	coords := make([][2]float64, 0, window)
+	valid := make([]bool, 0, window)
+	flush := func() error {
+		zones := latlong.LookupZoneNames(coords)
+		for i, row := range rows {
+			zone := ""
+			if valid[i] {
+				zone, zones = zones[0], zones[1:]
+			}
+			// Pad short rows so the zone is under its header.
+			for len(row) < len(header) {
+				row = append(row, "")
+			}
+			if err := cw.Write(append(row, zone)); err != nil {
+				return err
+			}
+		}
+		rows, coords, valid = rows[:0], coords[:0], valid[:0]
+		cw.Flush()
+		return cw.Error()
+	}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		lat, long, ok := rowCoords(row, latIdx, lngIdx)
+		if ok {
+			coords = append(coords, [2]float64{lat, long})
+		}
+		rows, valid = append(rows, row), append(valid, ok)
+		if len(rows) == window {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// column returns the index of the header column named name, ignoring
+// surrounding space, or -1 if there's none.
+func column(header []string, name string) int {
+	for i, h := range header {
+		if strings.TrimSpace(h) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// rowCoords returns the coordinates in row's latIdx and lngIdx cells,
+// and whether they're both present and valid.
+func rowCoords(row []string, latIdx, lngIdx int) (lat, long float64, ok bool) {
+	if latIdx >= len(row) || lngIdx >= len(row) {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(row[latIdx]), 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	long, err = strconv.ParseFloat(strings.TrimSpace(row[lngIdx]), 64)
+	if err != nil || math.IsNaN(long) || long < -180 || long > 180 {
+		return 0, 0, false
+	}
+	return lat, long, true
+}
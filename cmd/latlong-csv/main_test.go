@@ -0,0 +1,163 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAugment(t *testing.T) {
+	tests := []struct {
+		name           string
+		in             string
+		latCol, lngCol string
+		want           string // or the error's text, if wantErr
+		wantErr        bool
+	}{
+		{
+			name:   "basic",
+			in:     "name,lat,lng\nNYC,40.7128,-74.0060\n\"Delhi, IN\",28.61,77.2\n",
+			latCol: "lat", lngCol: "lng",
+			want: "name,lat,lng,timezone\nNYC,40.7128,-74.0060,America/New_York\n\"Delhi, IN\",28.61,77.2,Asia/Kolkata\n",
+		},
+		{
+			name:   "padded header and cells",
+			in:     "name, Latitude ,Longitude \nNYC, 40.7128 , -74.0060\n",
+			latCol: "Latitude", lngCol: "Longitude",
+			// encoding/csv quotes fields with leading spaces.
+			want: "name,\" Latitude \",Longitude ,timezone\nNYC,\" 40.7128 \",\" -74.0060\",America/New_York\n",
+		},
+		{
+			name:   "short rows",
+			in:     "lat,lng,name\n40.7128\n40.7128,-74.0060\n",
+			latCol: "lat", lngCol: "lng",
+			want: "lat,lng,name,timezone\n40.7128,,,\n40.7128,-74.0060,,America/New_York\n",
+		},
+		{
+			name: "invalid cells",
+			in: "lat,lng\n" +
+				"x,-74\n" +
+				",-74\n" +
+				"NaN,-74\n" +
+				"40.7,NaN\n" +
+				"91,-74\n" +
+				"40.7,-181\n" +
+				"40.7128,-74.0060\n",
+			latCol: "lat", lngCol: "lng",
+			want: "lat,lng,timezone\n" +
+				"x,-74,\n" +
+				",-74,\n" +
+				"NaN,-74,\n" +
+				"40.7,NaN,\n" +
+				"91,-74,\n" +
+				"40.7,-181,\n" +
+				"40.7128,-74.0060,America/New_York\n",
+		},
+		{
+			name:   "sea",
+			in:     "lat,lng\n0,-140\n",
+			latCol: "lat", lngCol: "lng",
+			want: "lat,lng,timezone\n0,-140,\n",
+		},
+		{
+			name:   "header only",
+			in:     "lat,lng\n",
+			latCol: "lat", lngCol: "lng",
+			want: "lat,lng,timezone\n",
+		},
+		{
+			name:   "missing latitude column",
+			in:     "latitude,lng\n1,2\n",
+			latCol: "lat", lngCol: "lng",
+			want: `no "lat" column in header`, wantErr: true,
+		},
+		{
+			name:   "missing longitude column",
+			in:     "lat,long\n1,2\n",
+			latCol: "lat", lngCol: "lng",
+			want: `no "lng" column in header`, wantErr: true,
+		},
+		{
+			name:   "empty input",
+			in:     "",
+			latCol: "lat", lngCol: "lng",
+			want: "no header row", wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := augment(strings.NewReader(tt.in), &out, tt.latCol, tt.lngCol)
+		if tt.wantErr {
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("%s: error = %v; want %q", tt.name, err, tt.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Tests that zones stay with their rows across windows, with invalid
+// rows, which aren't looked up, mixed in.
+func TestAugmentWindows(t *testing.T) {
+	places := []struct {
+		lat, lng string
+		zone     string
+	}{
+		{"40.7128", "-74.0060", "America/New_York"},
+		{"bad", "0", ""},
+		{"48.8566", "2.3522", "Europe/Paris"},
+		{"35.6762", "139.6503", "Asia/Tokyo"},
+		{"0", "-140", ""},
+		{"-33.8688", "151.2093", "Australia/Sydney"},
+		{"1", "", ""},
+	}
+	const rows = 3*window + 17
+	var in bytes.Buffer
+	in.WriteString("id,lat,lng\n")
+	for i := 0; i < rows; i++ {
+		p := places[i%len(places)]
+		fmt.Fprintf(&in, "%d,%s,%s\n", i, p.lat, p.lng)
+	}
+	var out bytes.Buffer
+	if err := augment(&in, &out, "lat", "lng"); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != rows+1 {
+		t.Fatalf("got %d rows; want %d", len(recs), rows+1)
+	}
+	for i, rec := range recs[1:] {
+		p := places[i%len(places)]
+		if want := []string{fmt.Sprint(i), p.lat, p.lng, p.zone}; strings.Join(rec, ",") != strings.Join(want, ",") {
+			t.Errorf("row %d = %q; want %q", i, rec, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The latlong command prints the timezone at each of the given
+// latitude and longitude pairs.
+//
+// Usage:
+//
+//	latlong [flags] [lat long]...
+//
+// With no coordinates on the command line, latlong reads them from
+// standard input, one "lat long" (or "lat,long") pair per line, and
+// prints one result per line.
+//
+// Coordinates may be negative, as in "latlong -33.87 151.21":
+// arguments that are numbers are never taken as flags. As usual, "--"
+// ends the flags, and everything after it is a coordinate.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/latlong"
+)
+
+var (
+	flagOffset = flag.Bool("offset", false, "Also print each zone's current UTC offset")
+	flagJSON   = flag.Bool("json", false, "Print results as JSON objects, one per line")
+	flagStrict = flag.Bool("strict", false, "Exit non-zero if any coordinate doesn't resolve to a zone")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: latlong [flags] [lat long]...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// result is the JSON form of a lookup.
+type result struct {
+	Lat    float64 `json:"lat"`
+	Long   float64 `json:"long"`
+	Zone   string  `json:"zone"`
+	Offset *int    `json:"offset,omitempty"` // seconds east of UTC
+}
+
+func main() {
+	flag.Usage = usage
+	flags, coords := splitArgs(os.Args[1:])
+	flag.CommandLine.Parse(flags)
+
+	failed := false
+	lookup := func(lat, long float64) {
+		if !printZone(lat, long) {
+			failed = true
+		}
+	}
+
+	if len(coords) > 0 {
+		if len(coords)%2 != 0 {
+			usage()
+		}
+		for i := 0; i < len(coords); i += 2 {
+			lat, long, err := parseLatLong(coords[i], coords[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "latlong: %v\n", err)
+				failed = true
+				continue
+			}
+			lookup(lat, long)
+		}
+	} else {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			lat, long, err := parseLine(line)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "latlong: %v\n", err)
+				failed = true
+				continue
+			}
+			lookup(lat, long)
+		}
+		if err := sc.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "latlong: reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if failed && *flagStrict {
+		os.Exit(1)
+	}
+}
+
+// splitArgs splits the command line's arguments into flags, for the
+// flag package, and coordinates. The flag package would take a
+// negative coordinate for an unknown flag and stop at the first
+// positive one, so anything that parses as a number is a coordinate,
+// wherever it is, as is everything after "--".
+func splitArgs(args []string) (flags, coords []string) {
+	for i, a := range args {
+		if a == "--" {
+			return flags, append(coords, args[i+1:]...)
+		}
+		if _, err := strconv.ParseFloat(a, 64); err == nil || a == "-" || !strings.HasPrefix(a, "-") {
+			coords = append(coords, a)
+		} else {
+			flags = append(flags, a)
+		}
+	}
+	return flags, coords
+}
+
+// parseLine parses a line of standard input: a "lat long" or
+// "lat,long" pair.
+func parseLine(line string) (lat, long float64, err error) {
+	f := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("bad line %q", line)
+	}
+	return parseLatLong(f[0], f[1])
+}
+
+// parseLatLong parses a latitude and longitude. Like the lookups,
+// it accepts values out of range, but not NaN or infinities, which
+// have no zone and which JSON can't represent.
+func parseLatLong(latStr, longStr string) (lat, long float64, err error) {
+	lat, err = strconv.ParseFloat(latStr, 64)
+	if err != nil || math.IsNaN(lat) || math.IsInf(lat, 0) {
+		return 0, 0, fmt.Errorf("bad latitude %q", latStr)
+	}
+	long, err = strconv.ParseFloat(longStr, 64)
+	if err != nil || math.IsNaN(long) || math.IsInf(long, 0) {
+		return 0, 0, fmt.Errorf("bad longitude %q", longStr)
+	}
+	return lat, long, nil
+}
+
+// printZone prints the zone at (lat, long) and reports whether one
+// was found.
+func printZone(lat, long float64) bool {
+	zone := latlong.LookupZoneName(lat, long)
+	var offset *int
+	if *flagOffset {
+		if secs, ok := latlong.LookupOffset(lat, long, time.Now()); ok {
+			offset = &secs
+		}
+	}
+
+	if *flagJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(result{lat, long, zone, offset}); err != nil {
+			fmt.Fprintf(os.Stderr, "latlong: %v\n", err)
+			os.Exit(1)
+		}
+	} else if offset != nil {
+		fmt.Printf("%s\t%s\n", zone, formatOffset(*offset))
+	} else {
+		fmt.Println(zone)
+	}
+	return zone != ""
+}
+
+// formatOffset formats secs east of UTC like "-07:00".
+func formatOffset(secs int) string {
+	sign := '+'
+	if secs < 0 {
+		sign = '-'
+		secs = -secs
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, secs/3600, secs/60%60)
+}
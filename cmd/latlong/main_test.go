@@ -0,0 +1,77 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		args          []string
+		flags, coords []string
+	}{
+		{[]string{"40.7128", "-74.0060"}, nil, []string{"40.7128", "-74.0060"}},
+		{[]string{"-33.87", "151.21"}, nil, []string{"-33.87", "151.21"}},
+		{[]string{"-json", "-33.87", "151.21", "-offset"}, []string{"-json", "-offset"}, []string{"-33.87", "151.21"}},
+		{[]string{"-strict", "--", "-json", "1"}, []string{"-strict"}, []string{"-json", "1"}},
+		{[]string{"-", "x", "-Inf"}, nil, []string{"-", "x", "-Inf"}},
+		{nil, nil, nil},
+	}
+	for _, tt := range tests {
+		flags, coords := splitArgs(tt.args)
+		if !reflect.DeepEqual(flags, tt.flags) || !reflect.DeepEqual(coords, tt.coords) {
+			t.Errorf("splitArgs(%q) = %q, %q; want %q, %q", tt.args, flags, coords, tt.flags, tt.coords)
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line      string
+		lat, long float64
+		wantErr   bool
+	}{
+		{line: "40.7128 -74.0060", lat: 40.7128, long: -74.0060},
+		{line: "40.7128,-74.0060", lat: 40.7128, long: -74.0060},
+		{line: "-33.87, 151.21", lat: -33.87, long: 151.21},
+		{line: "-33.87\t151.21", lat: -33.87, long: 151.21},
+		{line: "200 400", lat: 200, long: 400}, // normalized by the lookups
+		{line: "40.7128", wantErr: true},
+		{line: "1 2 3", wantErr: true},
+		{line: "north 2", wantErr: true},
+		{line: "1 east", wantErr: true},
+		{line: "NaN 0", wantErr: true},
+		{line: "0 NaN", wantErr: true},
+		{line: "Inf 0", wantErr: true},
+		{line: "0 -Inf", wantErr: true},
+	}
+	for _, tt := range tests {
+		lat, long, err := parseLine(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseLine(%q) = %v, %v; want error", tt.line, lat, long)
+			}
+			continue
+		}
+		if err != nil || lat != tt.lat || long != tt.long {
+			t.Errorf("parseLine(%q) = %v, %v, %v; want %v, %v", tt.line, lat, long, err, tt.lat, tt.long)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// A zoom level's tile index, once decompressed, is in one of two
+// formats. Version 1, the original, is the tiles' records in order:
+//
+//	key uint32 (big-endian tileKey)
+//	idx uint16 (big-endian leaf index)
+//
+// Version 2, written by the generator's --compact flag, takes
+// advantage of keys being sorted and mostly consecutive, and of
+// neighboring tiles often sharing a leaf:
+//
+//	0xff 0x02       header
+//	count  uvarint  number of tiles
+//	then runs of tiles sharing a leaf, until count tiles:
+//	  n    uvarint  number of tiles in the run, at least 1
+//	  idx  uvarint  their leaf index
+//	  n × delta uvarint: each key minus the previous one (or 0)
+//
+// The first byte of a version 1 index is the top byte of a tileKey,
+// whose top bit is never set, so the header tells them apart.
+const (
+	levelV2Magic   = 0xff
+	levelV2Version = 2
+)
+
+var errBadLevelV2 = errors.New("bogus version 2 tile index")
+
+// isLevelV2 reports whether the decompressed tile index b is in
+// version 2 format.
+func isLevelV2(b []byte) bool {
+	return len(b) >= 2 && b[0] == levelV2Magic
+}
+
+// encodeLevelV2 encodes a zoom level's tile index in version 2
+// format. The keys must be sorted and distinct.
+func encodeLevelV2(keys []tileKey, idxs []uint16) []byte {
+	b := []byte{levelV2Magic, levelV2Version}
+	b = appendUvarint(b, uint64(len(keys)))
+	var prev tileKey
+	for i := 0; i < len(keys); {
+		n := 1
+		for i+n < len(keys) && idxs[i+n] == idxs[i] {
+			n++
+		}
+		b = appendUvarint(b, uint64(n))
+		b = appendUvarint(b, uint64(idxs[i]))
+		for _, k := range keys[i : i+n] {
+			b = appendUvarint(b, uint64(k-prev))
+			prev = k
+		}
+		i += n
+	}
+	return b
+}
+
+// decodeLevelV2 decodes a version 2 tile index, checking that its keys
+// are in increasing order.
+func decodeLevelV2(b []byte) (keys []tileKey, idxs []uint16, err error) {
+	if !isLevelV2(b) || b[1] != levelV2Version {
+		return nil, nil, errBadLevelV2
+	}
+	b = b[2:]
+	next := func() (uint64, bool) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, false
+		}
+		b = b[n:]
+		return v, true
+	}
+	count, ok := next()
+	if !ok || count > uint64(len(b)) { // each tile takes at least a byte
+		return nil, nil, errBadLevelV2
+	}
+	keys = make([]tileKey, 0, count)
+	idxs = make([]uint16, 0, count)
+	var key uint64
+	for uint64(len(keys)) < count {
+		n, ok1 := next()
+		idx, ok2 := next()
+		if !ok1 || !ok2 || n == 0 || n > count-uint64(len(keys)) || idx > 0xffff {
+			return nil, nil, errBadLevelV2
+		}
+		for ; n > 0; n-- {
+			delta, ok := next()
+			if !ok || (delta == 0 && len(keys) > 0) {
+				return nil, nil, errBadLevelV2
+			}
+			key += delta
+			if key > 0xffffffff {
+				return nil, nil, errBadLevelV2
+			}
+			keys = append(keys, tileKey(key))
+			idxs = append(idxs, uint16(idx))
+		}
+	}
+	if len(b) != 0 {
+		return nil, nil, errBadLevelV2
+	}
+	return keys, idxs, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// Tests that the compiled-in tables round-trip through the version 2
+// format and resolve the same with it, and logs the savings.
+func TestLevelV2(t *testing.T) {
+	tab := defaultLookuper().mustLoad()
+	levels, leaves := compiledTables(t)
+	var v2Levels [6][]byte
+	var v2Bytes [6]int
+	v1Size, v2Size := 0, 0
+	for i, zl := range tab.levels {
+		b := encodeLevelV2(zl.keys, zl.idxs)
+		if !isLevelV2(b) {
+			t.Fatalf("level %d: encoding not detected as version 2", i)
+		}
+		keys, idxs, err := decodeLevelV2(b)
+		if err != nil {
+			t.Fatalf("level %d: %v", i, err)
+		}
+		if len(keys) != len(zl.keys) || len(keys) > 0 && (!reflect.DeepEqual(keys, zl.keys) || !reflect.DeepEqual(idxs, zl.idxs)) {
+			t.Fatalf("level %d: round trip mismatch", i)
+		}
+		v2Levels[i] = gzipBytes(b)
+		v2Bytes[i] = len(b)
+		v1Size += len(levels[i])
+		v2Size += len(v2Levels[i])
+	}
+	t.Logf("compressed tile indexes: version 1 %d bytes, version 2 %d bytes (%.1f%% smaller)",
+		v1Size, v2Size, 100*float64(v1Size-v2Size)/float64(v1Size))
+	if v2Size >= v1Size {
+		t.Errorf("version 2 is %d bytes; not smaller than version 1's %d", v2Size, v1Size)
+	}
+
+	l, err := NewLookuper(degPixels, v2Levels, leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range trackCoords(2000) {
+		if got, want := l.LookupName(c[0], c[1]), LookupZoneName(c[0], c[1]); got != want {
+			t.Fatalf("LookupName(%v, %v) = %q with version 2; want %q", c[0], c[1], got, want)
+		}
+	}
+	for i, st := range l.Stats() {
+		if st.Bytes != v2Bytes[i] {
+			t.Errorf("level %d: Stats Bytes = %d with version 2; want %d", i, st.Bytes, v2Bytes[i])
+		}
+	}
+}
+
+func TestLevelV2Errors(t *testing.T) {
+	good := encodeLevelV2([]tileKey{5, 6, 9}, []uint16{1, 1, 2})
+	if keys, idxs, err := decodeLevelV2(good); err != nil || !reflect.DeepEqual(keys, []tileKey{5, 6, 9}) || !reflect.DeepEqual(idxs, []uint16{1, 1, 2}) {
+		t.Fatalf("decodeLevelV2 = %v, %v, %v", keys, idxs, err)
+	}
+	for name, b := range map[string][]byte{
+		"empty":      {},
+		"version":    {levelV2Magic, 3, 0},
+		"truncated":  good[:len(good)-1],
+		"trailing":   append(append([]byte(nil), good...), 0),
+		"zero delta": {levelV2Magic, levelV2Version, 2, 2, 0, 1, 0},
+		"empty run":  {levelV2Magic, levelV2Version, 1, 0, 0, 1},
+		"long run":   {levelV2Magic, levelV2Version, 1, 2, 0, 1, 1},
+		"big idx":    {levelV2Magic, levelV2Version, 1, 1, 0x80, 0x80, 0x04, 1},
+		"huge count": {levelV2Magic, levelV2Version, 0xff, 0xff, 0xff, 0xff, 0x0f},
+	} {
+		if _, _, err := decodeLevelV2(b); err == nil {
+			t.Errorf("%s: decodeLevelV2(%x) succeeded", name, b)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupCountryCode(t *testing.T) {
+	cases := []struct {
+		lat, long float64
+		want      string
+	}{
+		{40.7128, -74.0060, "US"},
+		{37.7833, -122.4167, "US"},
+		{51.5074, -0.1278, "GB"},
+		{48.8566, 2.3522, "FR"},
+		{35.6762, 139.6503, "JP"},
+		{-33.8688, 151.2093, "AU"},
+		{-23.5505, -46.6333, "BR"},
+		{0, -140, ""},          // ocean
+		{-77.846, 166.676, ""}, // Antarctica/McMurdo
+		{-80, 0, ""},           // Antarctica/Troll, from the fallback
+
+		// Macquarie Island is Australian, despite its zone's name:
+		{-54.62, 158.86, "AU"},
+	}
+	for _, tt := range cases {
+		if got := LookupCountryCode(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupCountryCode(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+// Tests that every zone in the compiled-in tables has a country,
+// except the Etc and Antarctic zones, which needn't.
+func TestZoneCountryCoverage(t *testing.T) {
+	l := defaultLookuper()
+	for _, z := range l.mustLoad().leaf {
+		name, ok := z.(staticZone)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(string(name), "Antarctica/") || strings.HasPrefix(string(name), "Etc/") {
+			continue
+		}
+		if zoneCountry[string(name)] == "" {
+			t.Errorf("no country for zone %q", name)
+		}
+	}
+}
+
+// Tests that the only Antarctic zone with a country is Macquarie
+// Island's; the stations' zones have none.
+func TestZoneCountryAntarctica(t *testing.T) {
+	for zone, cc := range zoneCountry {
+		if !strings.HasPrefix(zone, "Antarctica/") {
+			continue
+		}
+		if zone != "Antarctica/Macquarie" || cc != "AU" {
+			t.Errorf("zoneCountry[%q] = %q; want no entry", zone, cc)
+		}
+	}
+	for _, s := range antarcticStations {
+		if cc := zoneCountry[s.zone]; cc != "" {
+			t.Errorf("station zone %q has country %q; want none", s.zone, cc)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LookupZoneNameDMS is like LookupZoneName but takes the coordinate as
+// a string in any of the forms ParseDMS accepts. It returns the empty
+// string if s is malformed.
+func LookupZoneNameDMS(s string) string {
+	lat, long, err := ParseDMS(s)
+	if err != nil {
+		return ""
+	}
+	return LookupZoneName(lat, long)
+}
+
+// LookupZoneNameString is like LookupZoneNameStrict but takes the
+// coordinate as a single "lat,long" string in decimal degrees, such as
+// "40.7128,-74.0060", as found in web forms and CSV files. Whitespace
+// around either number is ignored. It returns an error if s isn't two
+// numbers separated by one comma, or ErrLatitudeRange or
+// ErrLongitudeRange if they're out of range.
+func LookupZoneNameString(s string) (string, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("latlong: invalid coordinate %q: want \"lat,long\"", s)
+	}
+	var v [2]float64
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return "", fmt.Errorf("latlong: invalid coordinate %q: missing %s", s, [2]string{"latitude", "longitude"}[i])
+		}
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return "", fmt.Errorf("latlong: invalid coordinate %q: bad number %q", s, part)
+		}
+		v[i] = f
+	}
+	return LookupZoneNameStrict(v[0], v[1])
+}
+
+// ParseDMS parses a latitude and longitude written in degrees,
+// minutes, and seconds, such as
+//
+//	40°42'46"N 74°00'22"W
+//
+// Each of the two coordinates is degrees, optionally followed by
+// minutes and then seconds, each number marked with its unit: ° (or
+// º) for degrees, ' (or ′) for minutes, and " (or ″ or ”) for
+// seconds. The last number of each may have a fractional part, and
+// the degree mark may be omitted if nothing follows it, so
+// 40°42.767'N and 40.7128N are accepted too. The coordinates may be
+// separated by spaces or a comma.
+//
+// A coordinate's hemisphere is given by one of the letters N, S, E,
+// or W before or after it, or else by a leading minus sign, for south
+// or west. With hemisphere letters, the coordinates may be in either
+// order; without, the latitude comes first, so signed decimal degrees
+// such as "40.7128, -74.0060" work as well.
+func ParseDMS(s string) (lat, long float64, err error) {
+	p := dmsParser{s: s}
+	v1, h1, err := p.coord()
+	if err != nil {
+		return 0, 0, fmt.Errorf("latlong: invalid coordinate %q: %v", s, err)
+	}
+	p.skipSpace()
+	if p.peek() == ',' {
+		p.i++
+	}
+	v2, h2, err := p.coord()
+	if err != nil {
+		return 0, 0, fmt.Errorf("latlong: invalid coordinate %q: %v", s, err)
+	}
+	if p.skipSpace(); p.i < len(s) {
+		return 0, 0, fmt.Errorf("latlong: invalid coordinate %q: unexpected %q", s, s[p.i:])
+	}
+
+	isLong := func(h byte) bool { return h == 'E' || h == 'W' }
+	isLat := func(h byte) bool { return h == 'N' || h == 'S' }
+	switch {
+	case isLong(h1) && !isLong(h2), isLat(h2) && !isLat(h1):
+		v1, v2 = v2, v1
+	case h1 != 0 && h2 != 0 && isLat(h1) == isLat(h2):
+		return 0, 0, fmt.Errorf("latlong: invalid coordinate %q: hemispheres %c and %c", s, h1, h2)
+	}
+	lat, long = v1, v2
+	if math.Abs(lat) > 90 {
+		return 0, 0, fmt.Errorf("latlong: invalid coordinate %q: latitude %v out of range", s, lat)
+	}
+	if math.Abs(long) > 180 {
+		return 0, 0, fmt.Errorf("latlong: invalid coordinate %q: longitude %v out of range", s, long)
+	}
+	return lat, long, nil
+}
+
+// dmsParser scans the coordinates of a ParseDMS string.
+type dmsParser struct {
+	s string
+	i int // offset of the next byte to scan
+}
+
+func (p *dmsParser) peek() rune {
+	r, _ := utf8.DecodeRuneInString(p.s[p.i:])
+	return r
+}
+
+func (p *dmsParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// hemisphere scans a hemisphere letter, if there is one, returning it
+// in upper case or 0.
+func (p *dmsParser) hemisphere() byte {
+	p.skipSpace()
+	if p.i < len(p.s) {
+		switch c := p.s[p.i] &^ 0x20; c { // upper case
+		case 'N', 'S', 'E', 'W':
+			p.i++
+			return c
+		}
+	}
+	return 0
+}
+
+// number scans an unsigned decimal number.
+func (p *dmsParser) number() (v float64, frac bool, ok bool) {
+	start := p.i
+	for p.i < len(p.s) && (p.s[p.i] >= '0' && p.s[p.i] <= '9' || p.s[p.i] == '.') {
+		frac = frac || p.s[p.i] == '.'
+		p.i++
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.i], 64)
+	if err != nil {
+		p.i = start
+		return 0, false, false
+	}
+	return v, frac, true
+}
+
+// unit scans a unit mark, returning 0 for degrees, 1 for minutes, 2
+// for seconds, or -1 if there isn't one.
+func (p *dmsParser) unit() int {
+	p.skipSpace()
+	rest := p.s[p.i:]
+	for _, u := range []struct {
+		mark string
+		unit int
+	}{
+		{"°", 0}, {"º", 0},
+		{"''", 2}, {"'", 1}, {"′", 1},
+		{`"`, 2}, {"″", 2},
+	} {
+		if strings.HasPrefix(rest, u.mark) {
+			p.i += len(u.mark)
+			return u.unit
+		}
+	}
+	return -1
+}
+
+// coord scans one coordinate, returning its value in degrees and its
+// hemisphere letter, if any.
+func (p *dmsParser) coord() (v float64, hemi byte, err error) {
+	hemi = p.hemisphere()
+	p.skipSpace()
+	neg := false
+	if c := p.peek(); c == '-' || c == '+' {
+		neg = c == '-'
+		p.i++
+	}
+	last, lastFrac := -1, false // last unit scanned, and whether it had a fraction
+	for last < 2 {
+		p.skipSpace()
+		start := p.i
+		n, frac, ok := p.number()
+		if !ok {
+			if last == -1 {
+				return 0, 0, fmt.Errorf("missing degrees at %q", p.s[start:])
+			}
+			break
+		}
+		u := p.unit()
+		if u == -1 && last == -1 {
+			u = 0 // bare degrees
+		}
+		if last == -1 && u != 0 {
+			return 0, 0, fmt.Errorf("missing degrees at %q", p.s[start:])
+		}
+		if u <= last {
+			// The start of the next coordinate.
+			p.i = start
+			break
+		}
+		if lastFrac {
+			return 0, 0, fmt.Errorf("fractional value before %q", p.s[start:])
+		}
+		if u > 0 && n >= 60 {
+			return 0, 0, fmt.Errorf("%v is out of range for minutes or seconds", n)
+		}
+		v += n / math.Pow(60, float64(u))
+		last, lastFrac = u, frac
+	}
+	if hemi == 0 {
+		hemi = p.hemisphere()
+	}
+	if hemi != 0 && neg {
+		return 0, 0, fmt.Errorf("both a sign and hemisphere %c", hemi)
+	}
+	if neg || hemi == 'S' || hemi == 'W' {
+		v = -v
+	}
+	return v, hemi, nil
+}
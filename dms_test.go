@@ -0,0 +1,115 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDMS(t *testing.T) {
+	tests := []struct {
+		in        string
+		lat, long float64
+	}{
+		{`40°42'46"N 74°00'22"W`, 40 + 42.0/60 + 46.0/3600, -(74 + 22.0/3600)},
+		{`40° 42' 46" N, 74° 0' 22" W`, 40 + 42.0/60 + 46.0/3600, -(74 + 22.0/3600)},
+		{`40°42′46″N 74°0′22″W`, 40 + 42.0/60 + 46.0/3600, -(74 + 22.0/3600)},
+		{`40º42'46''N 74º0'22''W`, 40 + 42.0/60 + 46.0/3600, -(74 + 22.0/3600)},
+		{`74°00'22"W 40°42'46"N`, 40 + 42.0/60 + 46.0/3600, -(74 + 22.0/3600)},
+		{`N40°42'46" W74°00'22"`, 40 + 42.0/60 + 46.0/3600, -(74 + 22.0/3600)},
+		{`33°52'S 151°12.5'E`, -(33 + 52.0/60), 151 + 12.5/60},
+		{`33.8688s 151.2093e`, -33.8688, 151.2093},
+		{`40.7128, -74.0060`, 40.7128, -74.0060},
+		{`-33.8688 151.2093`, -33.8688, 151.2093},
+		{`51°30'N 0°7'W`, 51.5, -7.0 / 60},
+		{`0 0`, 0, 0},
+	}
+	for _, tt := range tests {
+		lat, long, err := ParseDMS(tt.in)
+		if err != nil {
+			t.Errorf("ParseDMS(%q): %v", tt.in, err)
+			continue
+		}
+		if math.Abs(lat-tt.lat) > 1e-9 || math.Abs(long-tt.long) > 1e-9 {
+			t.Errorf("ParseDMS(%q) = %v, %v; want %v, %v", tt.in, lat, long, tt.lat, tt.long)
+		}
+	}
+}
+
+func TestParseDMSErrors(t *testing.T) {
+	for _, in := range []string{
+		``,
+		`40°42'46"N`,
+		`40°42'46"N 74°00'22"W extra`,
+		`40°42'46"N 74°00'22"S`,
+		`40°61'N 74°W`,
+		`40°42'60"N 74°W`,
+		`40.5°30'N 74°W`,
+		`-40°N 74°W`,
+		`91°N 74°W`,
+		`40°N 181°W`,
+		`42'N 74°W`,
+		`abc def`,
+		`40..1 74`,
+	} {
+		if lat, long, err := ParseDMS(in); err == nil {
+			t.Errorf("ParseDMS(%q) = %v, %v; want error", in, lat, long)
+		}
+	}
+}
+
+func TestLookupZoneNameDMS(t *testing.T) {
+	if got, want := LookupZoneNameDMS(`40°42'46"N 74°00'22"W`), "America/New_York"; got != want {
+		t.Errorf("LookupZoneNameDMS = %q; want %q", got, want)
+	}
+	if got := LookupZoneNameDMS("nowhere"); got != "" {
+		t.Errorf("LookupZoneNameDMS(malformed) = %q; want empty", got)
+	}
+}
+
+func TestLookupZoneNameString(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"40.7128,-74.0060", "America/New_York", false},
+		{"  40.7128 ,\t-74.0060  ", "America/New_York", false},
+		{"51.5074,-0.1278", "Europe/London", false},
+		{"0,-140", "", false},
+		{"40.7128,-74.0060,", "", true},
+		{",-74.0060", "", true},
+		{"40.7128,", "", true},
+		{"40.7128 -74.0060", "", true},
+		{"40.7128;-74.0060", "", true},
+		{"north,west", "", true},
+		{"", "", true},
+		{"91,0", "", true},
+		{"0,-181", "", true},
+		{"NaN,0", "", true},
+	}
+	for _, tt := range tests {
+		got, err := LookupZoneNameString(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("LookupZoneNameString(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := LookupZoneNameString("91,0"); err != ErrLatitudeRange {
+		t.Errorf("latitude 91: error = %v; want ErrLatitudeRange", err)
+	}
+}
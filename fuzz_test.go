@@ -0,0 +1,75 @@
+// +build go1.18
+
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"math"
+	"testing"
+)
+
+func FuzzTileKey(f *testing.F) {
+	f.Add(uint8(0), uint16(0), uint16(0))
+	f.Add(uint8(5), uint16(1<<14-1), uint16(1<<14-1))
+	f.Add(uint8(3), uint16(1234), uint16(567))
+	f.Fuzz(func(t *testing.T, size uint8, x, y uint16) {
+		size &= 7
+		x &= 1<<14 - 1
+		y &= 1<<14 - 1
+		tk := newTileKey(size, x, y)
+		if tk.size() != size || tk.x() != x || tk.y() != y {
+			t.Fatalf("newTileKey(%d, %d, %d) = %x, which unpacks to (%d, %d, %d)",
+				size, x, y, uint32(tk), tk.size(), tk.x(), tk.y())
+		}
+		if tk>>31 != 0 {
+			t.Fatalf("newTileKey(%d, %d, %d) = %x uses the high bit", size, x, y, uint32(tk))
+		}
+		px, py := int(x)<<(3+size), int(y)<<(3+size)
+		if got := pixelTileKey(size, px, py); got != tk {
+			t.Fatalf("pixelTileKey(%d, %d, %d) = %x; want %x", size, px, py, uint32(got), uint32(tk))
+		}
+	})
+}
+
+func FuzzLookup(f *testing.F) {
+	f.Add(40.7128, -74.0060)
+	f.Add(90.0, 180.0)
+	f.Add(-90.0, -180.0)
+	f.Add(0.0, 0.0)
+	f.Add(math.NaN(), math.Inf(1))
+	f.Add(1e308, -1e308)
+
+	l := defaultLookuper()
+	l.mustLoad()
+	names := map[string]bool{"": true}
+	for _, name := range ZoneNames() {
+		names[name] = true
+	}
+	f.Fuzz(func(t *testing.T, lat, long float64) {
+		zone := LookupZoneName(lat, long)
+		if !names[zone] {
+			t.Fatalf("LookupZoneName(%v, %v) = %q, which isn't in the tables", lat, long, zone)
+		}
+		if tk, tzone, _ := LookupTile(lat, long); tzone != zone || tk.Size > 256 {
+			t.Fatalf("LookupTile(%v, %v) = %+v, %q; want zone %q", lat, long, tk, tzone, zone)
+		}
+		if got := LookupZoneNames([][2]float64{{lat, long}}); got[0] != zone {
+			t.Fatalf("LookupZoneNames(%v, %v) = %q; want %q", lat, long, got[0], zone)
+		}
+	})
+}
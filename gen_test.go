@@ -22,8 +22,11 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/base64"
-	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"go/format"
@@ -31,10 +34,15 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,8 +53,34 @@ import (
 
 var (
 	flagGenerate   = flag.Bool("generate", false, "Do generation")
-	flagWriteImage = flag.Bool("write_image", false, "Write out a debug image")
-	flagScale      = flag.Float64("scale", 32, "Scaling factor. This many pixels wide & tall per degree (e.g. scale 1 is 360 x 180). Increasingly this code assumes a scale of 32, though.")
+	flagWriteImage = flag.Bool("write_image", false, "Write out debug images: regions.png of the tiles, and coverage.png of what the generated tables resolve each pixel to")
+	flagScale      = flag.Float64("scale", 32, "Scaling factor. This many pixels wide & tall per degree (e.g. scale 1 is 360 x 180). Must be a whole number; tables at scales other than 32 can be checked with --scale_check.")
+	flagBBox       = flag.String("bbox", "", "If non-empty, a minLat,minLong,maxLat,maxLong box outside of which no zones are generated, for a smaller, non-global build")
+	flagZones      = flag.String("zones", "", "If non-empty, a comma-separated list of the only zones to generate, such as America/New_York,America/Chicago, for a smaller build covering just those; other zones are treated as ocean. Combines with --bbox.")
+	flagYear       = flag.Int("year", 0, "If non-zero, the year whose borders the source shapes have, recorded in the tables for Lookuper.Year and LookupZoneNameAt; zero means current borders")
+	flagSimplify   = flag.Float64("simplify_tolerance", 0, "If non-zero, simplify each polygon with the Douglas-Peucker algorithm, dropping points within this many degrees of the simplified outline, for smaller output with less accurate borders")
+	flagImageCache = flag.String("image_cache", "", "If non-empty, a file caching the rasterized world image between runs, so only the tiling is redone. It's rebuilt when the source data or the flags affecting rasterization change.")
+	flagTablesFile = flag.String("tables_file", "", "If non-empty, also write the tables to this file, for loading with ReadLookuper")
+	flagShardsDir  = flag.String("shards_dir", "", "If non-empty, also write the tables split into shards of a coarse grid to this directory, each a tables file named by ShardFile, for programs to load only those they need with ReadLookuper and LoadShard")
+	flagShardDeg   = flag.Int("shard_degrees", 40, "With --shards_dir, the width and height of the shards' grid cells, in degrees. Each must be a whole number of 256 pixel tiles.")
+	flagMaxTile    = flag.Int("max_tile_size", 256, "The size, in pixels, of the biggest tiles: 8, 16, 32, 64, 128 or 256. Smaller means fewer sizes of tiles and bigger tables.")
+	flagCompact    = flag.Bool("compact", false, "Write the tile indexes in the smaller version 2 format (see compact.go), which older versions of this package can't read")
+	flagSummary    = flag.String("summary_file", "", "If non-empty, also write a JSON summary of the generated tables (zone counts, entries and bytes per size) to this file, for CI to compare between builds")
+	flagZoneTiles  = flag.String("zone_tiles_file", "", "If non-empty, also write a JSON object mapping each zone to the number of solid tiles it has of each size, in pixels, to this file, for seeing how coarsely each zone is tiled")
+	flagNoTZCheck  = flag.Bool("no_tzdata_validate", false, "If true, keep source zones that time.LoadLocation can't load, such as with an incomplete local timezone database, logging them, rather than failing. The generated tables are the same.")
+	flagStrict     = flag.Bool("strict", false, "Fail generation on problems with the source data that are otherwise just reported: zones painting over each other's pixels, and zones left with no tiles")
+	flagSource     = flag.String("source", "tzworld", `Timezone shape source: "tzworld" for efele.net's world/tz_world.shp, or "tzbb" for timezone-boundary-builder's world/combined.json`)
+	flagGenOffsets = flag.Bool("generate_offsets", false, "Generate z_gen_offsets.go, the standard UTC offset of each zone in the tables, for LookupStandardOffset. Needs the local timezone database but not the shape files.")
+	flagOffsetsAt  = flag.String("offsets_at", "", "With --generate_offsets, the RFC 3339 reference instant whose standard offsets are used; empty means now")
+	flagGenLinks   = flag.Bool("generate_links", false, "Generate z_gen_links.go, the tz database's links from alias names to canonical zones, for CanonicalZone. Needs only --tzdata_zi.")
+	flagTZDataZI   = flag.String("tzdata_zi", "/usr/share/zoneinfo/tzdata.zi", "With --generate_links, the tz database in zic's input format, as installed with it")
+	flagBorders    = flag.Bool("generate_borders", false, "Generate z_gen_borders.go, simplified polygons of each zone that LookupZoneNameAccurate tests coordinates near borders against. Needs the shape files but not the other generated files.")
+	flagBorderTol  = flag.Float64("border_tolerance", 0.005, "With --generate_borders, how many degrees the simplified border polygons may be off by; smaller is more accurate but bigger")
+	flagScaleCheck = flag.Bool("scale_check", false, "If true, TestGenerateScales generates tables in memory at --scale and twice it and checks that their lookups agree. Rendering at scale 64 needs over 1 GB of memory.")
+	flagLookupImg  = flag.String("lookup_image", "", "If non-empty, TestLookupImage writes a PNG image to this file of the compiled-in tables' lookups in --lookup_image_bbox, each zone in its own color, for comparing before and after changes to the tables")
+	flagLookupBBox = flag.String("lookup_image_bbox", "-90,-180,90,180", "With --lookup_image, the minLat,minLong,maxLat,maxLong box to draw")
+	flagLookupRes  = flag.Float64("lookup_image_scale", 8, "With --lookup_image, how many pixels wide and tall to draw each degree")
+	flagAccuracy   = flag.Float64("accuracy_step", 0, "If non-zero, TestAccuracy compares the compiled-in tables' lookups at a grid of points this many degrees apart with exact point-in-polygon lookups in the source shapes")
 )
 
 func saveToPNGFile(filePath string, m image.Image) {
@@ -92,118 +126,762 @@ func loadImage(filename string) *image.NRGBA {
 	return im.(*image.NRGBA)
 }
 
-const alphaErased = 22 // magic alpha value to mean tile's been erased
-
-// the returned zoneOfColor always has A == 256.
+// worldImage returns the rasterized world image and the zone of each
+// of its colors, from --image_cache if it's set and up to date.
+//
+// the returned zoneOfColor always has A == 255.
 func worldImage(t *testing.T) (im *image.RGBA, zoneOfColor map[color.RGBA]string) {
+	if err := checkSourceFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if *flagImageCache == "" {
+		return renderWorldImage(t)
+	}
+	key := imageCacheKey(t)
+	if im, zoneOfColor, ok := readImageCache(key); ok {
+		log.Printf("Using world image from %s", *flagImageCache)
+		return im, zoneOfColor
+	}
+	im, zoneOfColor = renderWorldImage(t)
+	if err := writeImageCache(key, im, zoneOfColor); err != nil {
+		t.Fatalf("writing --image_cache: %v", err)
+	}
+	return im, zoneOfColor
+}
+
+// imageCache is the gob-encoded, gzipped contents of --image_cache.
+type imageCache struct {
+	Key         string // from imageCacheKey
+	Rect        image.Rectangle
+	Pix         []uint8
+	ZoneOfColor map[color.RGBA]string
+}
+
+// imageCacheKey returns a checksum of the source data and of the flags
+// that affect rasterizing it.
+func imageCacheKey(t *testing.T) string {
+	h := sha256.New()
+	src := sourceFiles[*flagSource]
+	files := []string{src}
+	if strings.HasSuffix(src, ".shp") {
+		files = append(files, strings.TrimSuffix(src, ".shp")+".dbf") // zone names
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fmt.Fprintf(h, "source=%s scale=%v bbox=%s zones=%s simplify=%v", *flagSource, *flagScale, *flagBBox, *flagZones, *flagSimplify)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// readImageCache returns the cached world image, if --image_cache has
+// one for key.
+func readImageCache(key string) (im *image.RGBA, zoneOfColor map[color.RGBA]string, ok bool) {
+	f, err := os.Open(*flagImageCache)
+	if err != nil {
+		return nil, nil, false
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, nil, false
+	}
+	var c imageCache
+	if err := gob.NewDecoder(zr).Decode(&c); err != nil || c.Key != key {
+		return nil, nil, false
+	}
+	im = &image.RGBA{Pix: c.Pix, Stride: 4 * c.Rect.Dx(), Rect: c.Rect}
+	if len(im.Pix) != im.Stride*c.Rect.Dy() {
+		return nil, nil, false
+	}
+	return im, c.ZoneOfColor, true
+}
+
+// writeImageCache writes im and zoneOfColor to --image_cache.
+func writeImageCache(key string, im *image.RGBA, zoneOfColor map[color.RGBA]string) error {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	err := gob.NewEncoder(zw).Encode(imageCache{
+		Key:         key,
+		Rect:        im.Rect,
+		Pix:         im.Pix,
+		ZoneOfColor: zoneOfColor,
+	})
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*flagImageCache, buf.Bytes(), 0644)
+}
+
+// renderWorldImage rasterizes the source shapes. See worldImage.
+func renderWorldImage(t *testing.T) (im *image.RGBA, zoneOfColor map[color.RGBA]string) {
 	scale := *flagScale
 	width := int(scale * 360)
 	height := int(scale * 180)
 
 	im = image.NewRGBA(image.Rect(0, 0, width, height))
 	zoneOfColor = map[color.RGBA]string{}
-	tab := crc32.MakeTable(crc32.IEEE + 1)
+	colorOfZone := map[string]color.RGBA{}
+
+	// zoneColor returns the color of zoneName, assigning the next
+	// unused one the first time it's seen.
+	zoneColor := func(zoneName string) color.RGBA {
+		if col, ok := colorOfZone[zoneName]; ok {
+			return col
+		}
+		col := indexColor(len(colorOfZone) + 1)
+		if name, ok := zoneOfColor[col]; ok {
+			t.Fatalf("Color %+v dup: %s and %s", col, name, zoneName)
+		}
+		colorOfZone[zoneName] = col
+		zoneOfColor[col] = zoneName
+		return col
+	}
 
-	drawPoly := func(col color.RGBA, xys ...int) {
-		painter := raster.NewRGBAPainter(im)
-		painter.SetColor(col)
+	// overlaps counts the pixels each source polygon paints over
+	// another zone's, which then silently belongs to whichever
+	// zone was drawn last.
+	overlaps := &overlapPainter{im: im, zoneOfColor: zoneOfColor}
+
+	// drawPoly draws a polygon. If check, overlaps with zones
+	// already drawn are counted.
+	drawPoly := func(col color.RGBA, check bool, xys ...int) {
+		rgba := raster.NewRGBAPainter(im)
+		rgba.SetColor(col)
+		var painter raster.Painter = rgba
+		if check {
+			overlaps.Painter, overlaps.col = rgba, col
+			painter = overlaps
+		}
 		r := raster.NewRasterizer(width, height)
 		r.Start(fixed.P(xys[0], xys[1]))
 		for i := 2; i < len(xys); i += 2 {
 			r.Add1(fixed.P(xys[i], xys[i+1]))
 		}
 		r.Add1(fixed.P(xys[0], xys[1]))
-		r.Rasterize(raster.NewMonochromePainter(painter))
+		r.Rasterize(&monochromePainter{Painter: painter})
 	}
 
-	sr, err := shp.Open("world/tz_world.shp")
-	if err != nil {
-		t.Fatalf("Error opening world/tz_world.shp: %v; unzip it from http://efele.net/maps/tz/world/tz_world.zip", err)
+	bb, haveBBox := parseBBox(t)
+	include := shapeFilter(t)
+	var nPoints, nSimplified int
+	unloadable := map[string]bool{} // with --no_tzdata_validate
+	readShapes(t, func(zoneName string, pts []shp.Point) {
+		if !include(zoneName, pts) {
+			return
+		}
+		if *flagSimplify > 0 {
+			nPoints += len(pts)
+			pts = simplify(pts, *flagSimplify)
+			nSimplified += len(pts)
+		}
+		if _, err := time.LoadLocation(zoneName); err != nil {
+			if !*flagNoTZCheck {
+				t.Fatalf("Failed to load: %v (%v); see --no_tzdata_validate", zoneName, err)
+			}
+			unloadable[zoneName] = true
+		}
+		col := zoneColor(zoneName)
+
+		var xys []int
+		for _, pt := range pts {
+			xys = append(xys, int((pt.X+180)*scale), int((90-pt.Y)*scale))
+		}
+		drawPoly(col, true, xys...)
+	})
+	if *flagSimplify > 0 {
+		log.Printf("Simplified %d polygon points to %d", nPoints, nSimplified)
 	}
-	defer sr.Close()
+	if len(unloadable) > 0 {
+		var names []string
+		for name := range unloadable {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.Printf("Warning: %d zones not in the local timezone database, kept unvalidated: %s", len(names), strings.Join(names, ", "))
+	}
+	overlaps.report(t)
+
+	if *flagSource == "tzworld" {
+		fixTZWorld(scale, func(zoneName string, xys ...int) {
+			// Zones outside --bbox aren't drawn at all.
+			// These deliberately paint over other zones.
+			if col, ok := colorOfZone[zoneName]; ok {
+				drawPoly(col, false, xys...)
+			}
+		})
+	}
+	if haveBBox {
+		bb.clip(im, scale)
+	}
+	for zoneName := range parseZones() {
+		if _, ok := colorOfZone[zoneName]; !ok {
+			t.Fatalf("--zones includes %q, which the source doesn't have (within --bbox)", zoneName)
+		}
+	}
+	return
+}
 
-	for sr.Next() {
-		i, s := sr.Shape()
-		p, ok := s.(*shp.Polygon)
-		if !ok {
-			t.Fatalf("Unknown shape %T", p)
+// simplify returns pts simplified with the Douglas-Peucker
+// algorithm: it keeps the first and last points and, recursively, the
+// point farthest from the line between the points kept on either side
+// of it, as long as that point is more than tolerance away.
+func simplify(pts []shp.Point, tolerance float64) []shp.Point {
+	if len(pts) < 3 {
+		return pts
+	}
+	keep := make([]bool, len(pts))
+	keep[0], keep[len(pts)-1] = true, true
+	type span struct{ first, last int }
+	stack := []span{{0, len(pts) - 1}}
+	for len(stack) > 0 {
+		sp := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		maxDist, maxi := 0.0, -1
+		for i := sp.first + 1; i < sp.last; i++ {
+			if d := segmentDist(pts[i], pts[sp.first], pts[sp.last]); d > maxDist {
+				maxDist, maxi = d, i
+			}
 		}
-		zoneName := sr.ReadAttribute(i, 0)
-		if zoneName == "uninhabited" {
+		if maxi >= 0 && maxDist > tolerance {
+			keep[maxi] = true
+			stack = append(stack, span{sp.first, maxi}, span{maxi, sp.last})
+		}
+	}
+	var out []shp.Point
+	for i, pt := range pts {
+		if keep[i] {
+			out = append(out, pt)
+		}
+	}
+	return out
+}
+
+// segmentDist returns the distance from p to the line segment from a
+// to b, treating degrees as planar coordinates.
+func segmentDist(p, a, b shp.Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+	f := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / (dx*dx + dy*dy)
+	f = math.Max(0, math.Min(1, f))
+	return math.Hypot(p.X-(a.X+f*dx), p.Y-(a.Y+f*dy))
+}
+
+// monochromePainter is like raster.MonochromePainter: it wraps
+// another Painter, making spans fully opaque or dropping them at an
+// alpha threshold of one half and merging adjacent spans. Unlike
+// raster.MonochromePainter, it doesn't flush an empty span when done
+// if it never accumulated one, so degenerate polygons paint nothing.
+type monochromePainter struct {
+	Painter raster.Painter
+
+	hasSpan   bool // whether y, x0, and x1 hold an accumulated span
+	y, x0, x1 int
+}
+
+func (m *monochromePainter) Paint(ss []raster.Span, done bool) {
+	// Compact ss in place, dropping mostly transparent spans.
+	j := 0
+	for _, s := range ss {
+		if s.Alpha < 0x8000 || s.X0 >= s.X1 {
 			continue
 		}
-		if _, err := time.LoadLocation(zoneName); err != nil {
-			t.Fatalf("Failed to load: %v (%v)", zoneName, err)
+		if m.hasSpan && m.y == s.Y && m.x1 == s.X0 {
+			m.x1 = s.X1
+			continue
 		}
-		hash := crc32.Checksum([]byte(zoneName), tab)
-		col := color.RGBA{uint8(hash >> 24), uint8(hash >> 16), uint8(hash >> 8), 255}
-		if name, ok := zoneOfColor[col]; ok {
-			if name != zoneName {
-				log.Fatalf("Color %+v dup: %s and %s", col, name, zoneName)
-			}
+		if m.hasSpan {
+			ss[j] = raster.Span{Y: m.y, X0: m.x0, X1: m.x1, Alpha: 1<<16 - 1}
+			j++
+		}
+		m.hasSpan, m.y, m.x0, m.x1 = true, s.Y, s.X0, s.X1
+	}
+	if !done {
+		m.Painter.Paint(ss[:j], false)
+		return
+	}
+	if m.hasSpan {
+		final := raster.Span{Y: m.y, X0: m.x0, X1: m.x1, Alpha: 1<<16 - 1}
+		if j < len(ss) {
+			ss[j] = final
+			ss = ss[:j+1]
 		} else {
-			zoneOfColor[col] = zoneName
+			ss = append(ss, final)
 		}
+	} else {
+		ss = ss[:j]
+	}
+	m.Painter.Paint(ss, true)
+	// Reset, so m can be reused.
+	m.hasSpan, m.y, m.x0, m.x1 = false, 0, 0, 0
+}
 
-		var xys []int
-		for _, pt := range p.Points {
-			xys = append(xys, int((pt.X+180)*scale), int((90-pt.Y)*scale))
+// overlapPainter is a raster.Painter that counts the pixels it's
+// asked to paint in col that im already has in another zone's color,
+// before passing the spans on to Painter. The spans must be opaque, as
+// from monochromePainter.
+type overlapPainter struct {
+	Painter     raster.Painter
+	im          *image.RGBA
+	col         color.RGBA // being painted
+	zoneOfColor map[color.RGBA]string
+
+	pixels int               // total pixels painted over
+	pairs  map[[2]string]int // [old zone, new zone] -> pixels
+}
+
+func (p *overlapPainter) Paint(ss []raster.Span, done bool) {
+	r := p.im.Bounds()
+	for _, s := range ss {
+		if s.Y < r.Min.Y || s.Y >= r.Max.Y {
+			continue
 		}
-		drawPoly(col, xys...)
+		for x := s.X0; x < s.X1; x++ {
+			if x < r.Min.X || x >= r.Max.X {
+				continue
+			}
+			old := p.im.RGBAAt(x, s.Y)
+			if old.A == 0 || old == p.col {
+				continue
+			}
+			if p.pairs == nil {
+				p.pairs = map[[2]string]int{}
+			}
+			p.pixels++
+			p.pairs[[2]string{p.zoneOfColor[old], p.zoneOfColor[p.col]}]++
+		}
+	}
+	p.Painter.Paint(ss, done)
+}
+
+// report logs the overlaps found, worst first, failing the test if
+// --strict is set and there were any.
+func (p *overlapPainter) report(t *testing.T) {
+	log.Printf("Zone overlaps: %d pixels painted over another zone, in %d zone pairs", p.pixels, len(p.pairs))
+	var pairs [][2]string
+	for pair := range p.pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if ni, nj := p.pairs[pairs[i]], p.pairs[pairs[j]]; ni != nj {
+			return ni > nj
+		}
+		return pairs[i][0]+pairs[i][1] < pairs[j][0]+pairs[j][1]
+	})
+	for i, pair := range pairs {
+		if i == 20 {
+			log.Printf("  ... and %d more pairs", len(pairs)-i)
+			break
+		}
+		log.Printf("  %s painted over %s: %d pixels", pair[1], pair[0], p.pairs[pair])
 	}
+	if *flagStrict && p.pixels > 0 {
+		t.Fatalf("%d pixels painted over another zone, with --strict", p.pixels)
+	}
+}
 
+// fixTZWorld fixes some glitches in rendering tz_world's shapes by
+// drawing the given zones over them.
+func fixTZWorld(scale float64, drawZone func(zoneName string, xys ...int)) {
 	// adjust point from scale 32 to whatever the user is using.
 	ap := func(x int) int { return x * int(scale) / 32 }
 	// Fix some rendering glitches:
-	// {186 205 234 255} = Europe/Rome
-	drawPoly(color.RGBA{186, 205, 234, 255},
+	drawZone("Europe/Rome",
 		ap(6156), ap(1468),
 		ap(6293), ap(1596),
 		ap(6293), ap(1598),
 		ap(6156), ap(1540))
-	// {136 136 180 255} = America/Boise
-	drawPoly(color.RGBA{136, 136, 180, 255},
+	drawZone("America/Boise",
 		ap(2145), ap(1468),
 		ap(2189), ap(1468),
 		ap(2189), ap(1536),
 		ap(2145), ap(1536))
-	// {120 247 14 255} = America/Denver
-	drawPoly(color.RGBA{120, 247, 14, 255},
+	drawZone("America/Denver",
 		ap(2167), ap(1536),
 		ap(2171), ap(1536),
 		ap(2217), ap(1714),
 		ap(2204), ap(1724),
 		ap(2160), ap(1537))
-	return
 }
 
-// A setIndexTracker that tells each index which item number it is, and can
-// retrieve that item's index later as well.
-type setIndexTracker struct {
-	s map[interface{}]uint16
-	l []interface{}
+// A bbox is a latitude and longitude bounding box, from --bbox.
+type bbox struct {
+	minLat, minLong, maxLat, maxLong float64
 }
 
-func (s *setIndexTracker) Lookup(v interface{}) (idx uint16, ok bool) {
-	idx, ok = s.s[v]
-	return
+// parseBBox parses --bbox, reporting whether it was set.
+func parseBBox(t *testing.T) (bb bbox, ok bool) {
+	if *flagBBox == "" {
+		return bb, false
+	}
+	bb, ok = parseBBoxString(*flagBBox)
+	if !ok {
+		t.Fatalf("bad --bbox %q; want minLat,minLong,maxLat,maxLong", *flagBBox)
+	}
+	return bb, true
+}
+
+// parseBBoxString parses a minLat,minLong,maxLat,maxLong box.
+func parseBBoxString(s string) (bb bbox, ok bool) {
+	_, err := fmt.Sscanf(s, "%g,%g,%g,%g", &bb.minLat, &bb.minLong, &bb.maxLat, &bb.maxLong)
+	return bb, err == nil && bb.minLat < bb.maxLat && bb.minLong < bb.maxLong
+}
+
+// parseZones parses --zones, returning nil if it's empty.
+func parseZones() map[string]bool {
+	if *flagZones == "" {
+		return nil
+	}
+	zones := map[string]bool{}
+	for _, zone := range strings.Split(*flagZones, ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			zones[zone] = true
+		}
+	}
+	return zones
 }
 
-func (s *setIndexTracker) Add(v interface{}) (idx uint16, isNew bool) {
-	if idx, ok := s.s[v]; ok {
-		return idx, false
+// shapeFilter returns a func reporting whether a source polygon is
+// included in the generated data: whether it overlaps --bbox and is of
+// one of --zones, if they're set.
+func shapeFilter(t *testing.T) func(zoneName string, pts []shp.Point) bool {
+	bb, haveBBox := parseBBox(t)
+	zones := parseZones()
+	return func(zoneName string, pts []shp.Point) bool {
+		if zones != nil && !zones[zoneName] {
+			return false
+		}
+		return !haveBBox || bb.overlaps(pts)
 	}
+}
 
-	if len(s.s) > 0xffff {
-		panic("too many items in set")
+// overlaps reports whether the bounding box of pts overlaps bb.
+func (bb bbox) overlaps(pts []shp.Point) bool {
+	if len(pts) == 0 {
+		return false
+	}
+	minX, minY, maxX, maxY := pts[0].X, pts[0].Y, pts[0].X, pts[0].Y
+	for _, pt := range pts[1:] {
+		minX, maxX = math.Min(minX, pt.X), math.Max(maxX, pt.X)
+		minY, maxY = math.Min(minY, pt.Y), math.Max(maxY, pt.Y)
 	}
-	idx = uint16(len(s.s))
-	if s.s == nil {
-		s.s = make(map[interface{}]uint16)
+	return minX <= bb.maxLong && maxX >= bb.minLong &&
+		minY <= bb.maxLat && maxY >= bb.minLat
+}
+
+// clip erases (to ocean) every pixel of im outside bb.
+func (bb bbox) clip(im *image.RGBA, scale float64) {
+	in := image.Rect(
+		int(math.Floor((bb.minLong+180)*scale)),
+		int(math.Floor((90-bb.maxLat)*scale)),
+		int(math.Ceil((bb.maxLong+180)*scale)),
+		int(math.Ceil((90-bb.minLat)*scale)),
+	)
+	b := im.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !image.Pt(x, y).In(in) {
+				im.SetRGBA(x, y, color.RGBA{})
+			}
+		}
+	}
+}
+
+// readShapes calls fn for each polygon in the source selected by
+// --source. As in a shapefile, any holes in a polygon follow its outer
+// ring in pts.
+func readShapes(t *testing.T, fn func(zoneName string, pts []shp.Point)) {
+	switch *flagSource {
+	case "tzworld":
+		readTZWorld(t, fn)
+	case "tzbb":
+		readTZBB(t, fn)
+	default:
+		t.Fatalf("unknown --source %q", *flagSource)
+	}
+}
+
+// writeCoverageImage writes coverage.png, an image of what the
+// generated tables resolve each pixel to, using the same lookups as
+// the runtime. Pixels are the colors of their zones in the source
+// image, or transparent where there's no zone, so comparing it with
+// the shapes shows border errors and dropped regions.
+func writeCoverageImage(t *testing.T, levels [6][]byte, leaves []byte, zoneOfColor map[color.RGBA]string) {
+	l, err := NewLookuper(int(*flagScale), levels, leaves)
+	if err != nil {
+		t.Fatalf("generated tables: %v", err)
+	}
+	colorOfZone := map[string]color.RGBA{}
+	for c, zone := range zoneOfColor {
+		colorOfZone[zone] = c
+	}
+	width, height := 360*l.degPixels, 180*l.degPixels
+	im := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if zone := l.lookupPixel(x, y); zone != "" {
+				im.SetRGBA(x, y, colorOfZone[zone])
+			}
+		}
+	}
+	saveToPNGFile("coverage.png", im)
+}
+
+// lookupColor returns the color lookup images draw zone in: one of
+// the generator's zone colors, picked by a hash of the zone's name
+// rather than the order of the source shapes, so a zone is the same
+// color in images from different tables.
+func lookupColor(zone string) color.RGBA {
+	return indexColor(int(crc32.ChecksumIEEE([]byte(zone))&(1<<24-1)) | 1) // nonzero, as indexColor needs
+}
+
+// renderLookupImage returns an image of what l's lookups return in bb,
+// sampled at the center of each of perDegree by perDegree pixels per
+// degree, with north up. Pixels are their zone's lookupColor, or
+// transparent where there's no zone.
+func renderLookupImage(l *Lookuper, bb bbox, perDegree float64) *image.RGBA {
+	width := int(math.Ceil((bb.maxLong - bb.minLong) * perDegree))
+	height := int(math.Ceil((bb.maxLat - bb.minLat) * perDegree))
+	im := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		lat := bb.maxLat - (float64(y)+0.5)/perDegree
+		for x := 0; x < width; x++ {
+			long := bb.minLong + (float64(x)+0.5)/perDegree
+			if zone := l.LookupName(lat, long); zone != "" {
+				im.SetRGBA(x, y, lookupColor(zone))
+			}
+		}
+	}
+	return im
+}
+
+// TestLookupImage writes --lookup_image, an image of the compiled-in
+// tables' lookups in --lookup_image_bbox, for reviewing changes to the
+// tables: comparing the images from before and after a change shows
+// where lookups changed.
+func TestLookupImage(t *testing.T) {
+	if *flagLookupImg == "" {
+		t.Skip("skipping lookup image without --lookup_image flag")
+	}
+	if degPixels == -1 {
+		t.Skip("data not generated yet")
+	}
+	bb, ok := parseBBoxString(*flagLookupBBox)
+	if !ok {
+		t.Fatalf("bad --lookup_image_bbox %q; want minLat,minLong,maxLat,maxLong", *flagLookupBBox)
+	}
+	if !(*flagLookupRes > 0) {
+		t.Fatalf("bad --lookup_image_scale %v", *flagLookupRes)
+	}
+	saveToPNGFile(*flagLookupImg, renderLookupImage(defaultLookuper(), bb, *flagLookupRes))
+}
+
+func TestRenderLookupImage(t *testing.T) {
+	if degPixels == -1 {
+		t.Skip("data not generated yet")
+	}
+	// Around Lake Geneva: France, Switzerland and the lake.
+	bb := bbox{minLat: 46, minLong: 6, maxLat: 46.5, maxLong: 7}
+	im := renderLookupImage(defaultLookuper(), bb, 20)
+	if got := im.Bounds().Size(); got != image.Pt(20, 10) {
+		t.Fatalf("image size = %v; want 20x10", got)
+	}
+	colors := map[color.RGBA]bool{}
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			lat, long := 46.5-(float64(y)+0.5)/20, 6+(float64(x)+0.5)/20
+			want := color.RGBA{}
+			if zone := LookupZoneName(lat, long); zone != "" {
+				want = lookupColor(zone)
+			}
+			if got := im.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d, %d) = %v; want %v", x, y, got, want)
+			}
+			colors[want] = true
+		}
+	}
+	if len(colors) < 2 {
+		t.Errorf("image has %d colors; want several zones", len(colors))
+	}
+	if lookupColor("Europe/Paris") == lookupColor("Europe/Zurich") {
+		t.Error("Paris and Zurich are the same color")
+	}
+}
+
+// compiledSize returns the total compressed size of the compiled-in
+// tables, which are from the previous generation.
+func compiledSize() int {
+	n := base64.StdEncoding.DecodedLen(len(uniqueLeavesPacked))
+	for _, zl := range zoomLevels {
+		if zl != nil {
+			n += base64.StdEncoding.DecodedLen(len(zl.gzipData))
+		}
+	}
+	return n
+}
+
+// sourceFiles maps each --source to its data file.
+var sourceFiles = map[string]string{
+	"tzworld": "world/tz_world.shp",
+	"tzbb":    "world/combined.json",
+}
+
+// sourceURLs maps each --source to where to download its data.
+var sourceURLs = map[string]string{
+	"tzworld": "http://efele.net/maps/tz/world/tz_world.zip",
+	"tzbb":    "https://github.com/evansiroky/timezone-boundary-builder/releases",
+}
+
+// A missingSourceError reports the source data files that are missing
+// or empty.
+type missingSourceError struct {
+	Source string   // the --source
+	Files  []string // missing or empty files
+	URL    string   // where to download them
+}
+
+func (e *missingSourceError) Error() string {
+	return fmt.Sprintf("missing --source=%s data: %s; unzip it into the world directory from %s",
+		e.Source, strings.Join(e.Files, ", "), e.URL)
+}
+
+// checkSourceFiles reports whether the current --source's data files
+// exist and are non-empty, returning a *missingSourceError listing
+// those that aren't. A shapefile's .dbf of zone names and .shx index
+// are needed too.
+func checkSourceFiles() error {
+	src, ok := sourceFiles[*flagSource]
+	if !ok {
+		return fmt.Errorf("unknown --source %q", *flagSource)
+	}
+	files := []string{src}
+	if strings.HasSuffix(src, ".shp") {
+		base := strings.TrimSuffix(src, ".shp")
+		files = append(files, base+".dbf", base+".shx")
+	}
+	var missing []string
+	for _, file := range files {
+		if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) > 0 {
+		return &missingSourceError{Source: *flagSource, Files: missing, URL: sourceURLs[*flagSource]}
+	}
+	return nil
+}
+
+// dataVersionOf returns the DataVersion for tables with numZones
+// zones, generated from the current --source. The dataset's date is
+// its data file's modification time, which unzipping preserves.
+func dataVersionOf(t *testing.T, numZones int) string {
+	name := map[string]string{"tzworld": "tz_world", "tzbb": "timezone-boundary-builder"}[*flagSource]
+	fi, err := os.Stat(sourceFiles[*flagSource])
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := fmt.Sprintf("%s %s, scale %d, %d zones", name, fi.ModTime().UTC().Format("2006-01-02"), int(*flagScale), numZones)
+	if *flagBBox != "" {
+		v += ", bbox " + *flagBBox
+	}
+	if *flagZones != "" {
+		v += ", selected zones"
+	}
+	return v
+}
+
+// readTZWorld reads efele.net's tz_world shapefile.
+func readTZWorld(t *testing.T, fn func(zoneName string, pts []shp.Point)) {
+	sr, err := shp.Open("world/tz_world.shp")
+	if err != nil {
+		t.Fatalf("Error opening world/tz_world.shp: %v; unzip it from http://efele.net/maps/tz/world/tz_world.zip", err)
+	}
+	defer sr.Close()
+
+	for sr.Next() {
+		i, s := sr.Shape()
+		p, ok := s.(*shp.Polygon)
+		if !ok {
+			t.Fatalf("Unknown shape %T", p)
+		}
+		zoneName := sr.ReadAttribute(i, 0)
+		if zoneName == "uninhabited" {
+			continue
+		}
+		fn(zoneName, p.Points)
+	}
+}
+
+// readTZBB reads the combined GeoJSON release of
+// https://github.com/evansiroky/timezone-boundary-builder, whose
+// features have their zone name in the "tzid" property.
+func readTZBB(t *testing.T, fn func(zoneName string, pts []shp.Point)) {
+	f, err := os.Open("world/combined.json")
+	if err != nil {
+		t.Fatalf("Error opening world/combined.json: %v; unzip it from a timezone-boundary-builder release", err)
+	}
+	defer f.Close()
+
+	var fc struct {
+		Features []struct {
+			Properties struct {
+				TZID string `json:"tzid"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(bufio.NewReader(f)).Decode(&fc); err != nil {
+		t.Fatalf("Error decoding world/combined.json: %v", err)
+	}
+
+	// polygon flattens a GeoJSON polygon's rings ([long, lat]
+	// positions) into one point list.
+	polygon := func(rings [][][]float64) []shp.Point {
+		var pts []shp.Point
+		for _, ring := range rings {
+			for _, pos := range ring {
+				pts = append(pts, shp.Point{X: pos[0], Y: pos[1]})
+			}
+		}
+		return pts
+	}
+	for _, ft := range fc.Features {
+		zoneName := ft.Properties.TZID
+		g := ft.Geometry
+		switch g.Type {
+		case "Polygon":
+			var rings [][][]float64
+			if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+				t.Fatalf("Bad Polygon for %s: %v", zoneName, err)
+			}
+			fn(zoneName, polygon(rings))
+		case "MultiPolygon":
+			var polys [][][][]float64
+			if err := json.Unmarshal(g.Coordinates, &polys); err != nil {
+				t.Fatalf("Bad MultiPolygon for %s: %v", zoneName, err)
+			}
+			for _, rings := range polys {
+				fn(zoneName, polygon(rings))
+			}
+		default:
+			t.Fatalf("Unknown geometry type %q for %s", g.Type, zoneName)
+		}
 	}
-	s.s[v] = idx
-	s.l = append(s.l, v)
-	return idx, true
 }
 
 func init() {
@@ -243,137 +921,466 @@ func testAllPixels_gen(t *testing.T) {
 	t.Logf("%d pixels tested; %d failures", total, fail)
 }
 
-func TestGenerate(t *testing.T) {
-	if !*flagGenerate {
-		t.Skip("skipping generationg without --generate flag")
-	}
-
-	im, zoneOfColor := worldImage(t)
+// spanRecorder is a raster.Painter that records what it's asked to
+// paint.
+type spanRecorder struct {
+	spans []raster.Span
+	done  bool
+}
 
-	// The auto-generated source file (z_gen_tables.go)
-	var gen bytes.Buffer
-	gen.WriteString("// Auto-generated file. See README or Makefile.\n\npackage latlong\n\n")
-	gen.WriteString("func init() {\n")
+func (r *spanRecorder) Paint(ss []raster.Span, done bool) {
+	r.spans = append(r.spans, ss...)
+	r.done = r.done || done
+}
 
-	fmt.Fprintf(&gen, "degPixels = %d\n", int(*flagScale))
+func TestSimplify(t *testing.T) {
+	pt := func(x, y float64) shp.Point { return shp.Point{X: x, Y: y} }
+	pts := []shp.Point{pt(0, 0), pt(1, 0.05), pt(2, -0.05), pt(3, 2), pt(4, 0), pt(5, 0)}
+	tests := []struct {
+		tolerance float64
+		want      []shp.Point
+	}{
+		{0, pts},
+		{0.1, []shp.Point{pt(0, 0), pt(2, -0.05), pt(3, 2), pt(4, 0), pt(5, 0)}},
+		{10, []shp.Point{pt(0, 0), pt(5, 0)}},
+	}
+	for _, tt := range tests {
+		if got := simplify(pts, tt.tolerance); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("simplify(%v) = %v; want %v", tt.tolerance, got, tt.want)
+		}
+	}
+}
 
-	// Source code for just the zoneLookers variables.
-	var zoneLookers zoneLookerWriter
+func TestImageCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "latlong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { *flagImageCache = old }(*flagImageCache)
+	*flagImageCache = dir + "/image.cache"
+
+	im := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	im.SetRGBA(3, 4, indexColor(1))
+	zoneOfColor := map[color.RGBA]string{indexColor(1): "Europe/Berlin"}
+	if err := writeImageCache("key1", im, zoneOfColor); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := readImageCache("key2"); ok {
+		t.Error("read cache with the wrong key")
+	}
+	im2, zoneOfColor2, ok := readImageCache("key1")
+	if !ok {
+		t.Fatal("didn't read cache")
+	}
+	if !reflect.DeepEqual(im2, im) || !reflect.DeepEqual(zoneOfColor2, zoneOfColor) {
+		t.Errorf("read %v, %v; want %v, %v", im2.Bounds(), zoneOfColor2, im.Bounds(), zoneOfColor)
+	}
+}
 
-	// Maps from a unique key (either a string or colorTile) to
-	// its index.
-	var zoneIndex setIndexTracker
+func TestOverlapPainter(t *testing.T) {
+	im := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	berlin, paris := indexColor(1), indexColor(2)
+	im.SetRGBA(2, 1, berlin)
+	im.SetRGBA(3, 1, paris)
+	im.SetRGBA(4, 1, berlin)
+	var rec spanRecorder
+	p := &overlapPainter{
+		Painter:     &rec,
+		im:          im,
+		col:         paris,
+		zoneOfColor: map[color.RGBA]string{berlin: "Europe/Berlin", paris: "Europe/Paris"},
+	}
+	p.Paint([]raster.Span{
+		{Y: 1, X0: 0, X1: 8, Alpha: 1<<16 - 1},
+		{Y: 2, X0: 0, X1: 8, Alpha: 1<<16 - 1},
+		{Y: 9, X0: 0, X1: 8, Alpha: 1<<16 - 1}, // off the image
+	}, true)
+	if p.pixels != 2 {
+		t.Errorf("pixels = %d; want 2", p.pixels)
+	}
+	want := map[[2]string]int{{"Europe/Berlin", "Europe/Paris"}: 2}
+	if !reflect.DeepEqual(p.pairs, want) {
+		t.Errorf("pairs = %v; want %v", p.pairs, want)
+	}
+	if len(rec.spans) != 3 || !rec.done {
+		t.Errorf("passed on %d spans, done %v; want 3, true", len(rec.spans), rec.done)
+	}
+}
 
-	zoneIndexOfColor := func(c color.RGBA) uint16 {
-		if (c == color.RGBA{}) {
-			return oceanIndex
+func TestShapeFilter(t *testing.T) {
+	defer func(old string) { *flagZones = old }(*flagZones)
+	defer func(old string) { *flagBBox = old }(*flagBBox)
+	pt := func(x, y float64) shp.Point { return shp.Point{X: x, Y: y} }
+	nyc := []shp.Point{pt(-74, 40), pt(-73, 40), pt(-73, 41)}
+	chicago := []shp.Point{pt(-88, 41), pt(-87, 41), pt(-87, 42)}
+	tests := []struct {
+		zones, bbox       string
+		wantNYC, wantChic bool
+	}{
+		{"", "", true, true},
+		{"America/New_York, America/Denver", "", true, false},
+		{"America/New_York,America/Chicago", "", true, true},
+		{"", "35,-80,45,-70", true, false},
+		{"America/Chicago", "35,-80,45,-70", false, false},
+	}
+	for _, tt := range tests {
+		*flagZones, *flagBBox = tt.zones, tt.bbox
+		include := shapeFilter(t)
+		if got := include("America/New_York", nyc); got != tt.wantNYC {
+			t.Errorf("--zones=%q --bbox=%q: New York included = %v; want %v", tt.zones, tt.bbox, got, tt.wantNYC)
 		}
-		idx, ok := zoneIndex.Lookup(zoneOfColor[c])
-		if !ok {
-			t.Fatalf("failed to find zone index for color %+v", c)
+		if got := include("America/Chicago", chicago); got != tt.wantChic {
+			t.Errorf("--zones=%q --bbox=%q: Chicago included = %v; want %v", tt.zones, tt.bbox, got, tt.wantChic)
 		}
-		return idx
 	}
+}
 
-	// Add the static timezones (~408 of them). If a tile (which
-	// can range from 8 to 256 pixels square) doesn't resolve to
-	// one of these, it'll resolve to an image tile that then
-	// resolves to one of these.
-	{
-		var zones []string
-		for _, zone := range zoneOfColor {
-			zones = append(zones, zone)
-		}
-		sort.Strings(zones)
-		for i, zone := range zones {
-			idx, _ := zoneIndex.Add(zone)
-			if idx != uint16(i) {
-				panic("unexpected")
-			}
-			zoneLookers.Add("S" + zone)
-		}
-		log.Printf("Num zones = %d", len(zones))
+func TestCheckSourceFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "latlong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { sourceFiles["tzworld"] = old }(sourceFiles["tzworld"])
+	defer func(old string) { *flagSource = old }(*flagSource)
+	*flagSource = "tzworld"
+	sourceFiles["tzworld"] = dir + "/tz_world.shp"
+
+	ioutil.WriteFile(dir+"/tz_world.shp", []byte("shapes"), 0644)
+	ioutil.WriteFile(dir+"/tz_world.shx", nil, 0644)
+	err = checkSourceFiles()
+	me, ok := err.(*missingSourceError)
+	if !ok {
+		t.Fatalf("checkSourceFiles = %v; want a *missingSourceError", err)
+	}
+	want := []string{dir + "/tz_world.dbf", dir + "/tz_world.shx"}
+	if !reflect.DeepEqual(me.Files, want) || me.URL != sourceURLs["tzworld"] {
+		t.Errorf("missing %q from %s; want %q from %s", me.Files, me.URL, want, sourceURLs["tzworld"])
 	}
 
-	var imo *image.RGBA
-	if *flagWriteImage {
-		imo = cloneImage(im)
+	ioutil.WriteFile(dir+"/tz_world.dbf", []byte("names"), 0644)
+	ioutil.WriteFile(dir+"/tz_world.shx", []byte("index"), 0644)
+	if err := checkSourceFiles(); err != nil {
+		t.Errorf("checkSourceFiles = %v; want nil", err)
 	}
-	dupColorTiles := 0
+}
 
-	gen.WriteString("zoomLevels = [6]*zoomLevel{\n")
-	for _, sizeShift := range []uint8{5, 4, 3, 2, 1, 0} {
-		fmt.Fprintf(&gen, "\t%d: &zoomLevel{\n", sizeShift)
-		var keyIdxBuf bytes.Buffer // repeated binary [tilekey][uint16_idx]
+func TestShapeIndex(t *testing.T) {
+	pt := func(x, y float64) shp.Point { return shp.Point{X: x, Y: y} }
+	square := func(x0, y0, x1, y1 float64) []shp.Point {
+		return []shp.Point{pt(x0, y0), pt(x1, y0), pt(x1, y1), pt(x0, y1), pt(x0, y0)}
+	}
+	si := newShapeIndex()
+	// A square with a square hole, then a zone drawn over its corner.
+	si.add("A", append(square(0, 0, 4, 4), square(1, 1, 2, 2)...))
+	si.add("B", square(3, 3, 5, 5))
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{0.5, 0.5, "A"},
+		{1.5, 1.5, ""}, // in the hole
+		{2.5, 2.5, "A"},
+		{3.5, 3.5, "B"}, // drawn last
+		{4.5, 4.5, "B"},
+		{-1, -1, ""},
+	}
+	for _, tt := range tests {
+		if got := si.zoneAt(tt.lat, tt.long); got != tt.want {
+			t.Errorf("zoneAt(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	if d := si.borderDist(2.5, 0.5, 2); math.Abs(d-0.5) > 1e-9 {
+		t.Errorf("borderDist = %v; want 0.5", d)
+	}
+	if d := si.borderDist(50, 50, 2); !math.IsInf(d, 1) {
+		t.Errorf("borderDist far away = %v; want +Inf", d)
+	}
+}
+
+func TestIndexColor(t *testing.T) {
+	seen := map[color.RGBA]int{}
+	for i := 1; i < 1<<16; i++ {
+		c := indexColor(i)
+		if c.A != 255 {
+			t.Fatalf("indexColor(%d) = %v; want opaque", i, c)
+		}
+		if j, ok := seen[c]; ok {
+			t.Fatalf("indexColor(%d) = indexColor(%d) = %v", i, j, c)
+		}
+		seen[c] = i
+	}
+}
 
-		pass := newSizePass(im, imo, sizeShift)
+func TestMonochromePainter(t *testing.T) {
+	// Nothing to paint, or nothing opaque enough, paints nothing.
+	for _, ss := range [][]raster.Span{
+		nil,
+		{{Y: 3, X0: 1, X1: 2, Alpha: 0x7fff}},
+		{{Y: 0, X0: 0, X1: 0, Alpha: 0xffff}, {Y: 5, X0: 4, X1: 4, Alpha: 0xffff}},
+	} {
+		var rec spanRecorder
+		m := &monochromePainter{Painter: &rec}
+		m.Paint(ss, false)
+		m.Paint(nil, true)
+		if len(rec.spans) != 0 || !rec.done {
+			t.Errorf("painting %+v: got %+v, done=%v; want nothing, done", ss, rec.spans, rec.done)
+		}
+	}
 
-		skipSquares := 0
-		sizeCount := map[int]int{} // num colors -> count
+	// Adjacent spans on a row are merged and opaque.
+	var rec spanRecorder
+	m := &monochromePainter{Painter: &rec}
+	m.Paint([]raster.Span{
+		{Y: 1, X0: 2, X1: 4, Alpha: 0x9000},
+		{Y: 1, X0: 4, X1: 6, Alpha: 0xffff},
+		{Y: 1, X0: 6, X1: 7, Alpha: 0x100},
+		{Y: 2, X0: 0, X1: 1, Alpha: 0xffff},
+	}, false)
+	m.Paint(nil, true)
+	want := []raster.Span{
+		{Y: 1, X0: 2, X1: 6, Alpha: 0xffff},
+		{Y: 2, X0: 0, X1: 1, Alpha: 0xffff},
+	}
+	if !reflect.DeepEqual(rec.spans, want) {
+		t.Errorf("got %+v; want %+v", rec.spans, want)
+	}
+	if m.hasSpan {
+		t.Error("not reset after done")
+	}
+}
 
-		pass.foreachTile(func(tile *tileMeta) {
-			if tile.skipped {
-				skipSquares++
-				return
-			}
-			nColor := len(tile.colors)
-			sizeCount[nColor]++
-			if nColor < 2 {
-				tile.erase()
-			}
-			if nColor == 1 {
-				zoneName := zoneOfColor[tile.color()]
-				if idx, isNew := zoneIndex.Add(zoneName); isNew {
-					panic("zone should've been registered: " + zoneName)
-				} else {
-					binary.Write(&keyIdxBuf, binary.BigEndian, tile.key())
-					binary.Write(&keyIdxBuf, binary.BigEndian, idx)
+// Tests that drawing the output image for --write_image doesn't
+// change how tiles are classified, and so doesn't change the tables.
+func TestSizePassOutputImage(t *testing.T) {
+	const w, h = 512, 256
+	newImage := func() *image.RGBA {
+		im := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				switch {
+				case y < 40 || x > 400: // ocean
+				case x*x/300+y < 200:
+					im.SetRGBA(x, y, indexColor(1))
+				case (x-250)*(x-250)+(y-150)*(y-150) < 1000:
+					im.SetRGBA(x, y, indexColor(2))
+				default:
+					im.SetRGBA(x, y, indexColor(3+x%5/4))
 				}
-				tile.drawBorder()
-				return
 			}
-			if nColor == 0 {
-				tile.paintOcean()
-				return
-			}
-			if sizeShift == 0 && nColor >= 2 {
-				ct := tile.colorTile()
-				idx, isNew := zoneIndex.Add(ct)
-				if isNew {
-					if nColor == 2 {
-						zoneLookers.Add(fmt.Sprintf("2%s", pass.bitmapPixmapBytes(ct, zoneIndexOfColor)))
-					} else {
-						zoneLookers.Add(fmt.Sprintf("P%s", pass.pixmapIndexBytes(ct, zoneIndexOfColor)))
-					}
-				} else {
-					dupColorTiles++
+		}
+		return im
+	}
+	classify := func(im, imo *image.RGBA) (tiles []string) {
+		for _, sizeShift := range []uint8{5, 4, 3, 2, 1, 0} {
+			newSizePass(im, imo, sizeShift).foreachTile(func(tile *tileMeta) {
+				if tile.skipped {
+					return
+				}
+				var colors []string
+				for c := range tile.colors {
+					colors = append(colors, fmt.Sprint(c))
 				}
-				binary.Write(&keyIdxBuf, binary.BigEndian, tile.key())
-				binary.Write(&keyIdxBuf, binary.BigEndian, idx)
+				sort.Strings(colors)
+				tiles = append(tiles, fmt.Sprintf("%x %v", tile.key(), colors))
+				nColor := len(tile.colors)
+				if nColor < 2 {
+					tile.erase()
+				}
+				switch {
+				case imo == nil:
+				case nColor == 1:
+					tile.drawBorder()
+				case nColor == 0:
+					tile.paintOcean()
+				}
+			})
+		}
+		return tiles
+	}
+	im := newImage()
+	want := classify(im, nil)
+	im2 := newImage()
+	imo := cloneImage(im2)
+	got := classify(im2, imo)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with an output image, got %d tiles; want %d without", len(got), len(want))
+	}
+	if !bytes.Equal(im2.Pix, im.Pix) {
+		t.Error("drawing the output image changed the input image")
+	}
+	if bytes.Equal(imo.Pix, newImage().Pix) {
+		t.Error("output image not drawn on")
+	}
+}
+
+// Tests that tileImage's tables work at scales other than 32, by
+// tiling the same synthetic world at two scales and checking that
+// lookups at both agree with it away from its borders.
+func TestTileImageScales(t *testing.T) {
+	// zoneAt returns the index, from 1, of the synthetic world's
+	// zone at lat, long. Its borders are curved, so they're tiled
+	// with bitmaps and pixmaps as well as solid tiles. It has no
+	// ocean, since tiles of a single zone also cover the ocean in
+	// them, which makes how far zones reach offshore depend on the
+	// scale.
+	zoneAt := func(lat, long float64) int {
+		band := int((long + 180 + 8*math.Sin(lat*math.Pi/45)) / 24)
+		if band < 0 {
+			band = 0
+		} else if band > 14 {
+			band = 14
+		}
+		idx := 1 + 2*band
+		if lat >= 5*math.Cos(long*math.Pi/60) {
+			idx++
+		}
+		return idx
+	}
+	zoneName := func(idx int) string {
+		return fmt.Sprintf("Synth/Zone%02d", idx)
+	}
+	lookuper := func(scale int) *Lookuper {
+		im := image.NewRGBA(image.Rect(0, 0, 360*scale, 180*scale))
+		zoneOfColor := map[color.RGBA]string{}
+		for y := 0; y < 180*scale; y++ {
+			for x := 0; x < 360*scale; x++ {
+				// The zone at the pixel's center.
+				idx := zoneAt(90-(float64(y)+0.5)/float64(scale), (float64(x)+0.5)/float64(scale)-180)
+				im.SetRGBA(x, y, indexColor(idx))
+				zoneOfColor[indexColor(idx)] = zoneName(idx)
 			}
-		})
-		log.Printf("For size %d, skipped %d, dist: %+v", pass.size, skipSquares, sizeCount)
+		}
+		g := genTileImage(t, im, nil, zoneOfColor)
+		l, err := NewLookuper(scale, g.levels, g.zoneLookers.Packed())
+		if err != nil {
+			t.Fatalf("scale %d: %v", scale, err)
+		}
+		return l
+	}
+	const scale1, scale2 = 4, 8
+	l1, l2 := lookuper(scale1), lookuper(scale2)
+
+	// Coordinates within a couple of the coarser scale's pixels of a
+	// border may legitimately resolve to either side of it.
+	const tol = 2.0 / scale1
+	coords := randomCoords(20000)
+	checked := 0
+	for _, c := range coords {
+		lat, long := c[0], c[1]
+		want := zoneAt(lat, long)
+		nearBorder := false
+		for _, d := range [][2]float64{{-tol, -tol}, {-tol, tol}, {tol, -tol}, {tol, tol}, {0, -tol}, {0, tol}, {-tol, 0}, {tol, 0}} {
+			if zoneAt(lat+d[0], long+d[1]) != want {
+				nearBorder = true
+				break
+			}
+		}
+		if nearBorder || math.Abs(long)+tol >= 180 || math.Abs(lat)+tol >= 90 {
+			continue
+		}
+		checked++
+		z1, z2 := l1.LookupName(lat, long), l2.LookupName(lat, long)
+		if z1 != zoneName(want) || z2 != zoneName(want) {
+			t.Errorf("at %v, %v: scale %d = %q, scale %d = %q; want %q", lat, long, scale1, z1, scale2, z2, zoneName(want))
+		}
+	}
+	if checked < len(coords)/2 {
+		t.Errorf("only checked %d of %d coordinates away from borders", checked, len(coords))
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	if !*flagGenerate {
+		t.Skip("skipping generationg without --generate flag")
+	}
 
-		var zbuf bytes.Buffer
-		zw := gzip.NewWriter(&zbuf)
-		zw.Write(keyIdxBuf.Bytes())
-		zw.Close()
+	im, zoneOfColor := worldImage(t)
 
-		log.Printf("size %d is %d entries: %d bytes (%d bytes compressed)", pass.size, keyIdxBuf.Len()/6, keyIdxBuf.Len(), zbuf.Len())
+	var imo *image.RGBA
+	if *flagWriteImage {
+		imo = cloneImage(im)
+	}
+	g := genTileImage(t, im, imo, zoneOfColor)
+	g.sum.Source, g.sum.Scale = *flagSource, int(*flagScale)
+	if imo != nil {
+		saveToPNGFile("regions.png", imo)
+	}
+	leaves := g.zoneLookers.Packed()
 
-		fmt.Fprintf(&gen, "\t\tgzipData: %q,\n", base64.StdEncoding.EncodeToString(zbuf.Bytes()))
+	// The auto-generated source file (z_gen_tables.go)
+	var gen bytes.Buffer
+	gen.WriteString("// Auto-generated file. See README or Makefile.\n")
+	if *flagBBox != "" {
+		fmt.Fprintf(&gen, "//\n// Generated with --bbox=%s: coordinates outside it have no zone.\n", *flagBBox)
+	}
+	if *flagZones != "" {
+		fmt.Fprintf(&gen, "//\n// Generated with --zones=%s: coordinates in other zones have none.\n", *flagZones)
+	}
+	gen.WriteString("\npackage latlong\n\n")
+	gen.WriteString("func init() {\n")
+	fmt.Fprintf(&gen, "degPixels = %d\n", int(*flagScale))
+	fmt.Fprintf(&gen, "dataVersion = %q\n", dataVersionOf(t, g.sum.Zones))
+	if *flagBBox != "" || *flagZones != "" {
+		gen.WriteString("partialTables = true\n")
+	}
+	if *flagYear != 0 {
+		fmt.Fprintf(&gen, "dataYear = %d\n", *flagYear)
+	}
+	maxShift, _ := tileSizeShift(*flagMaxTile) // checked by genTileImage
+	gen.WriteString("zoomLevels = []*zoomLevel{\n")
+	for s := int(maxShift); s >= 0; s-- {
+		sizeShift := uint8(s)
+		fmt.Fprintf(&gen, "\t%d: &zoomLevel{\n", sizeShift)
+		fmt.Fprintf(&gen, "\t\tgzipData: %q,\n", base64.StdEncoding.EncodeToString(g.levels[sizeShift]))
 		gen.WriteString("\t},\n")
 	}
 	gen.WriteString("}\n\n")
+	gen.Write(g.zoneLookers.Source())
+	gen.WriteString("}\n") // close init
 
-	log.Printf("Duplicate 8x8 pixmaps: %d", dupColorTiles)
+	if *flagWriteImage {
+		writeCoverageImage(t, g.levels, leaves, zoneOfColor)
+	}
 
-	if imo != nil {
-		saveToPNGFile("regions.png", imo)
+	log.Printf("Total compressed size = %d bytes (was %d)", g.sum.CompressedBytes, compiledSize())
+
+	if *flagSummary != "" {
+		if err := writeJSONFile(*flagSummary, g.sum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *flagZoneTiles != "" {
+		if err := writeJSONFile(*flagZoneTiles, g.zoneTiles); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	gen.Write(zoneLookers.Source())
-	gen.WriteString("}\n") // close init
+	if *flagTablesFile != "" {
+		var tbuf bytes.Buffer
+		if err := writeTables(&tbuf, int(*flagScale), g.levels, leaves); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(*flagTablesFile, tbuf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *flagShardsDir != "" {
+		shards, err := splitShards(int(*flagScale), g.levels, leaves, *flagShardDeg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(*flagShardsDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, b := range shards {
+			if err := ioutil.WriteFile(filepath.Join(*flagShardsDir, name), b, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		log.Printf("Wrote %d shards to %s", len(shards), *flagShardsDir)
+	}
 
 	fmt, err := format.Source(gen.Bytes())
 	if err != nil {
@@ -385,263 +1392,573 @@ func TestGenerate(t *testing.T) {
 	}
 }
 
-type sizePass struct {
-	width, height  int
-	size           int // of tile. 8 << sizeShift
-	sizeShift      uint8
-	xtiles, ytiles int
-	im             *image.RGBA
-	imo            *image.RGBA // or nil if not generating an output image
+// genTileImage is tileImage with the generator's flags and logging,
+// also checking for zones lost in tiling.
+func genTileImage(t *testing.T, im, imo *image.RGBA, zoneOfColor map[color.RGBA]string) *generatedTables {
+	maxShift, err := tileSizeShift(*flagMaxTile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := tileImage(im, imo, zoneOfColor, *flagCompact, maxShift, log.Printf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.sum.LostZones = checkTiledZones(t, zoneOfColor, g.tiledZones)
+	return g
+}
 
-	buf [128]byte // for pixmap 8x8 uint16 indexes
+// tileSizeShift returns the size shift of tiles size pixels square,
+// for --max_tile_size.
+func tileSizeShift(size int) (uint8, error) {
+	for s := uint8(0); s <= maxSizeShift; s++ {
+		if 8<<s == size {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("--max_tile_size=%d isn't 8, 16, 32, 64, 128 or 256", size)
 }
 
-func newSizePass(im, imo *image.RGBA, sizeShift uint8) *sizePass {
-	p := &sizePass{
-		width:     im.Bounds().Max.X,
-		height:    im.Bounds().Max.Y,
-		im:        im,
-		imo:       imo,
-		sizeShift: sizeShift,
-		size:      int(8 << sizeShift),
+// writeJSONFile writes v to the named file as indented JSON.
+func writeJSONFile(filename string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
 	}
-	p.xtiles = p.width / p.size
-	p.ytiles = p.height / p.size
-	return p
+	return ioutil.WriteFile(filename, append(b, '\n'), 0644)
 }
 
-func (p *sizePass) foreachTile(fn func(*tileMeta)) {
-	tm := new(tileMeta)
-	im := p.im
+// A shapeIndex finds the source shapes containing a point, and the
+// distance from a point to the nearest shape border, exactly rather
+// than rasterized, as a reference for TestAccuracy. Shapes and their
+// edges are bucketed by the one-degree cell they overlap.
+type shapeIndex struct {
+	shapes []indexedShape            // in the order drawn
+	cells  map[[2]int][]int          // cell -> indexes into shapes
+	edges  map[[2]int][][2]shp.Point // cell -> edges overlapping it
+}
 
-	colors := map[color.RGBA]bool{}
-	// clearColors wipes colors, so we can re-use it.
-	clearColors := func() {
-		for k := range colors {
-			delete(colors, k)
-		}
-	}
-
-	for yt := 0; yt < p.ytiles; yt++ {
-		for xt := 0; xt < p.xtiles; xt++ {
-			*tm = tileMeta{p: p, xt: xt, yt: yt, colors: colors}
-			tm.setBounds()
-			sawOcean := false
-			x1, y1 := tm.x1, tm.y1
-			clearColors()
-		Pixels:
-			for y := tm.y0; y < y1; y++ {
-				for x := tm.x0; x < x1; x++ {
-					off := im.PixOffset(x, y)
-					alpha := im.Pix[off+3]
-					switch alpha {
-					case 0:
-						sawOcean = true
-						continue
-					case alphaErased:
-						if x != tm.x0 || y != tm.y0 {
-							panic("unexpected")
-						}
-						tm.skipped = true
-						break Pixels
-					case 255:
-						// expected
-					default:
-						panic("Unexpected alpha value")
-					}
-					nc := color.RGBA{R: im.Pix[off], G: im.Pix[off+1], B: im.Pix[off+2], A: alpha}
-					colors[nc] = true
-				}
-			}
-			if len(colors) > 1 && sawOcean {
-				// note the ocean, since this can't be solid anyway
-				colors[color.RGBA{}] = true
-			}
-			fn(tm)
+type indexedShape struct {
+	zone string
+	pts  []shp.Point
+}
+
+func newShapeIndex() *shapeIndex {
+	return &shapeIndex{cells: map[[2]int][]int{}, edges: map[[2]int][][2]shp.Point{}}
+}
+
+// cellsOf calls fn for each one-degree cell overlapping the box.
+func cellsOf(minX, minY, maxX, maxY float64, fn func(cell [2]int)) {
+	for y := int(math.Floor(minY)); y <= int(math.Floor(maxY)); y++ {
+		for x := int(math.Floor(minX)); x <= int(math.Floor(maxX)); x++ {
+			fn([2]int{x, y})
 		}
 	}
 }
 
-func (p *sizePass) pixmapIndexBytes(ct colorTile, fn func(color.RGBA) uint16) []byte {
-	buf := p.buf[:]
-	for _, row := range ct {
-		for _, c := range row {
-			binary.BigEndian.PutUint16(buf, fn(c))
-			buf = buf[2:]
+// add adds a shape. Its points are a polygon's rings, one after
+// another, as from readShapes.
+func (si *shapeIndex) add(zone string, pts []shp.Point) {
+	if len(pts) < 3 {
+		return
+	}
+	i := len(si.shapes)
+	si.shapes = append(si.shapes, indexedShape{zone, pts})
+	minX, minY, maxX, maxY := pts[0].X, pts[0].Y, pts[0].X, pts[0].Y
+	for j, a := range pts {
+		minX, maxX = math.Min(minX, a.X), math.Max(maxX, a.X)
+		minY, maxY = math.Min(minY, a.Y), math.Max(maxY, a.Y)
+		b := pts[(j+1)%len(pts)]
+		edge := [2]shp.Point{a, b}
+		cellsOf(math.Min(a.X, b.X), math.Min(a.Y, b.Y), math.Max(a.X, b.X), math.Max(a.Y, b.Y), func(cell [2]int) {
+			si.edges[cell] = append(si.edges[cell], edge)
+		})
+	}
+	cellsOf(minX, minY, maxX, maxY, func(cell [2]int) {
+		si.cells[cell] = append(si.cells[cell], i)
+	})
+}
+
+// zoneAt returns the zone at the given point: that of the last shape
+// drawn containing it, as in the rasterized image.
+func (si *shapeIndex) zoneAt(lat, long float64) string {
+	cand := si.cells[[2]int{int(math.Floor(long)), int(math.Floor(lat))}]
+	for i := len(cand) - 1; i >= 0; i-- {
+		if s := si.shapes[cand[i]]; pointInPolygon(s.pts, long, lat) {
+			return s.zone
 		}
 	}
-	return p.buf[:128]
+	return ""
 }
 
-// For two-color tiles.
-func (p *sizePass) bitmapPixmapBytes(ct colorTile, fn func(color.RGBA) uint16) []byte {
-	var c1, c2 color.RGBA
-	var bits uint64
-	var n uint8
-	for _, row := range ct {
-		for _, c := range row {
-			if n == 0 {
-				c1 = c
-			} else {
-				if c != c1 {
-					c2 = c
-					bits |= (1 << n)
+// borderDist returns the distance in degrees from the given point to
+// the nearest shape edge, or +Inf if none is within maxCells cells.
+func (si *shapeIndex) borderDist(lat, long float64, maxCells int) float64 {
+	p := shp.Point{X: long, Y: lat}
+	cx, cy := int(math.Floor(long)), int(math.Floor(lat))
+	best := math.Inf(1)
+	for r := 0; r <= maxCells; r++ {
+		// Edges in ring r are at least r-1 cells away.
+		if float64(r-1) >= best {
+			break
+		}
+		for y := cy - r; y <= cy+r; y++ {
+			for x := cx - r; x <= cx+r; x++ {
+				if y != cy-r && y != cy+r && x != cx-r && x != cx+r {
+					continue // inside the ring, already scanned
+				}
+				for _, e := range si.edges[[2]int{x, y}] {
+					best = math.Min(best, segmentDist(p, e[0], e[1]))
 				}
 			}
-			n++
 		}
 	}
-	if c1 == c2 {
-		panic("didn't see two colors")
-	}
-	binary.BigEndian.PutUint16(p.buf[0:2], fn(c1))
-	binary.BigEndian.PutUint16(p.buf[2:4], fn(c2))
-	binary.BigEndian.PutUint64(p.buf[4:12], bits)
-	return p.buf[:12]
+	return best
 }
 
-type tileMeta struct {
-	p      *sizePass
-	xt, yt int
-
-	x0, x1, y0, y1 int
+// pointInPolygon reports whether (x, y) is inside the polygon pts by
+// the even-odd rule. The polygon may be several rings one after
+// another, each closed, as holes are: the edges joining them cancel
+// out.
+func pointInPolygon(pts []shp.Point, x, y float64) bool {
+	in := false
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		a, b := pts[i], pts[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
 
-	// skipped reports whether the tile was skipped due to seeing
-	// erasure from previous level.
-	skipped bool
+// TestGenerateScales checks that generation still works at scales
+// other than 32, by generating tables from the source shapes at
+// --scale and twice it and comparing their lookups at random
+// coordinates. The tables are only kept in memory. Coordinates that
+// either scale says are ocean are skipped, since tiles of a single
+// zone cover the ocean in them, to a scale-dependent extent, as are
+// those within a couple of the coarser scale's pixels of a border.
+func TestGenerateScales(t *testing.T) {
+	if !*flagScaleCheck {
+		t.Skip("skipping scale comparison without --scale_check flag")
+	}
+	if err := checkSourceFiles(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(scale float64) { *flagScale = scale }(*flagScale)
+	scale1 := int(*flagScale)
+	var ls []*Lookuper
+	for _, scale := range []int{scale1, 2 * scale1} {
+		*flagScale = float64(scale)
+		im, zoneOfColor := renderWorldImage(t)
+		g := genTileImage(t, im, nil, zoneOfColor)
+		l, err := NewLookuper(scale, g.levels, g.zoneLookers.Packed())
+		if err != nil {
+			t.Fatalf("scale %d: %v", scale, err)
+		}
+		ls = append(ls, l)
+	}
 
-	// colors will only contain a zero color if the tile size is smallest
-	// (8x8) and there's an ocean (zero color) and two others. If there's
-	// an ocean and only 1 other color, only one color will be returned.
-	colors map[color.RGBA]bool
+	tol := 2 / float64(scale1)
+	checked, mismatches := 0, 0
+	for _, c := range randomCoords(100000) {
+		lat, long := c[0], c[1]
+		z1, z2 := ls[0].LookupName(lat, long), ls[1].LookupName(lat, long)
+		if z1 == "" || z2 == "" {
+			continue
+		}
+		nearBorder := false
+		for _, d := range [][2]float64{{-tol, -tol}, {-tol, tol}, {tol, -tol}, {tol, tol}} {
+			if ls[0].LookupName(lat+d[0], long+d[1]) != z1 {
+				nearBorder = true
+				break
+			}
+		}
+		if nearBorder {
+			continue
+		}
+		checked++
+		if z1 != z2 {
+			mismatches++
+			if mismatches <= 10 {
+				t.Logf("at %v, %v: scale %d = %q, scale %d = %q", lat, long, scale1, z1, 2*scale1, z2)
+			}
+		}
+	}
+	log.Printf("Scales %d and %d disagree at %d of %d coordinates away from borders", scale1, 2*scale1, mismatches, checked)
+	// Allow for zones too small to have tiles at the coarser scale.
+	if mismatches*1000 > checked {
+		t.Errorf("scales %d and %d disagree at %d of %d coordinates away from borders", scale1, 2*scale1, mismatches, checked)
+	}
 }
 
-func (t *tileMeta) setBounds() {
-	size := t.p.size
-	t.y0 = t.yt * size
-	t.y1 = (t.yt + 1) * size
-	t.x0 = t.xt * size
-	t.x1 = (t.xt + 1) * size
+// TestAccuracy measures the accuracy of the compiled-in tables against
+// the source shapes. At a grid of points --accuracy_step degrees apart,
+// it compares the tables' zone with the exact one, ignoring points
+// both say have no zone, and reports the mismatch rate and how far
+// the mismatches are from the nearest border, in pixels.
+func TestAccuracy(t *testing.T) {
+	step := *flagAccuracy
+	if step <= 0 {
+		t.Skip("skipping accuracy test without --accuracy_step flag")
+	}
+	if degPixels == -1 {
+		t.Skip("data not generated yet")
+	}
+	if err := checkSourceFiles(); err != nil {
+		t.Fatal(err)
+	}
+	si := newShapeIndex()
+	include := shapeFilter(t)
+	readShapes(t, func(zoneName string, pts []shp.Point) {
+		if include(zoneName, pts) {
+			si.add(zoneName, pts)
+		}
+	})
+
+	const maxCells = 2 // how far to look for borders, in degrees
+	limits := []float64{1, 2, 4, 8, 16, 32, maxCells * float64(degPixels)}
+	hist := make([]int, len(limits)+1)
+
+	l := defaultLookuper()
+	total, mismatches := 0, 0
+	for lat := -90 + step/2; lat < 90; lat += step {
+		for long := -180 + step/2; long < 180; long += step {
+			want := si.zoneAt(lat, long)
+			got := l.lookupPixel(l.pixelOf(lat, long))
+			if want == "" && got == "" {
+				continue
+			}
+			total++
+			if got == want {
+				continue
+			}
+			mismatches++
+			d := si.borderDist(lat, long, maxCells) * float64(degPixels)
+			hist[sort.SearchFloat64s(limits, d)]++
+		}
+	}
+	if total == 0 {
+		t.Fatal("no points with zones")
+	}
+	log.Printf("Accuracy: %d of %d points mismatched (%.3f%%)", mismatches, total, 100*float64(mismatches)/float64(total))
+	lo := 0.0
+	for i, n := range hist {
+		if i < len(limits) {
+			log.Printf("  %4g to %4g pixels from a border: %d", lo, limits[i], n)
+			lo = limits[i]
+		} else {
+			log.Printf("  over %g pixels from a border: %d", lo, n)
+		}
+	}
 }
 
-func (t *tileMeta) color() color.RGBA {
-	if len(t.colors) != 1 {
-		panic("color called with colors != 1")
+// checkTiledZones logs and returns the source zones that no tile
+// resolves to, which lookups can therefore never return: typically
+// tiny enclaves lost when rasterizing or painted over by a neighbor.
+// It fails the test if --strict is set and there are any.
+func checkTiledZones(t *testing.T, zoneOfColor map[color.RGBA]string, tiledZones map[string]bool) []string {
+	var lost []string
+	for _, zone := range zoneOfColor {
+		if !tiledZones[zone] {
+			lost = append(lost, zone)
+		}
 	}
-	var c color.RGBA
-	for c = range t.colors {
-		// get first (and only) key
+	sort.Strings(lost)
+	log.Printf("Zones with no tiles: %d of %d", len(lost), len(zoneOfColor))
+	for _, zone := range lost {
+		log.Printf("  %s", zone)
 	}
-	if (c == color.RGBA{}) {
-		panic("no color found for tile")
+	if *flagStrict && len(lost) > 0 {
+		t.Fatalf("%d zones have no tiles, with --strict: %s", len(lost), strings.Join(lost, ", "))
 	}
-	return c
+	return lost
 }
 
-func (t *tileMeta) key() tileKey {
-	return newTileKey(t.p.sizeShift, uint16(t.xt), uint16(t.yt))
+// standardOffset returns loc's standard-time offset, in seconds east
+// of UTC, at the given instant. For zones observing daylight saving
+// time within six months of it, that's the smallest offset in that
+// year, as in tzdata's "rearguard" format: so Europe/Dublin's is UTC,
+// even though tzdata itself calls its winter time the negative DST.
+func standardOffset(loc *time.Location, at time.Time) int {
+	t := at.In(loc)
+	_, std := t.Zone()
+	dst := false
+	for months := -6; months <= 6; months++ {
+		u := t.AddDate(0, months, 0)
+		if u.IsDST() {
+			dst = true
+		}
+		if _, off := u.Zone(); off < std {
+			std = off
+		}
+	}
+	if !dst {
+		_, std = t.Zone()
+	}
+	return std
 }
 
-func (t *tileMeta) paintOcean() {
-	p := t.p
-	imo := p.imo
-	if imo == nil {
-		return
+func TestGenerateOffsets(t *testing.T) {
+	if !*flagGenOffsets {
+		t.Skip("skipping offset generation without --generate_offsets flag")
+	}
+	if degPixels == -1 {
+		t.Fatal("tables not generated yet; run --generate first")
 	}
-	blue := [4]uint8{0, 0, 128, 255}
-	size := p.size
-	for y := t.y0; y < t.y1; y++ {
-		off := imo.PixOffset(t.x0, y)
-		for x := 0; x < size; x++ {
-			copy(imo.Pix[off:], blue[:])
-			off += 4
+	at := time.Now().UTC().Truncate(time.Second)
+	if *flagOffsetsAt != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, *flagOffsetsAt)
+		if err != nil {
+			t.Fatalf("bad --offsets_at: %v", err)
 		}
 	}
-}
 
-func (t *tileMeta) drawBorder() {
-	p := t.p
-	imo := p.imo
-	if imo == nil {
-		return
+	var gen bytes.Buffer
+	gen.WriteString("// Auto-generated file. See README or Makefile.\n")
+	fmt.Fprintf(&gen, "//\n// Standard UTC offsets as of %s.\n", at.Format(time.RFC3339))
+	gen.WriteString("\npackage latlong\n\n")
+	gen.WriteString("func init() {\n")
+	fmt.Fprintf(&gen, "standardOffsetsAt = %q\n", at.Format(time.RFC3339))
+	gen.WriteString("standardOffsets = map[string]int32{\n")
+	for _, zone := range ZoneNames() {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Fatalf("loading %q: %v", zone, err)
+		}
+		fmt.Fprintf(&gen, "%q: %d,\n", zone, standardOffset(loc, at))
 	}
+	gen.WriteString("}\n")
+	gen.WriteString("}\n") // close init
 
-	yellow := uint8(255 - (128 - byte(p.size)))
-	color := [4]uint8{yellow, yellow, 0, 255}
+	src, err := format.Source(gen.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("z_gen_offsets.go", src, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
 
-	for y := t.y0; y < t.y1; y++ {
-		off := imo.PixOffset(t.x0, y)
-		for x := t.x0; x < t.x1; x++ {
-			// Border:
-			if y == t.y0 || y == t.y1-1 || x == t.x0 || x == t.x1-1 {
-				copy(imo.Pix[off:], color[:])
-			}
-			off += 4
+func TestGenerateBorders(t *testing.T) {
+	if !*flagBorders {
+		t.Skip("skipping border generation without --generate_borders flag")
+	}
+	include := shapeFilter(t)
+	var zones []string
+	var polys [][][2]float64
+	vertices := 0
+	readShapes(t, func(zoneName string, pts []shp.Point) {
+		if !include(zoneName, pts) {
+			return
+		}
+		if poly := borderPolygon(pts, *flagBorderTol); len(poly) > 0 {
+			zones = append(zones, zoneName)
+			polys = append(polys, poly)
+			vertices += len(poly)
 		}
+	})
+	var zbuf bytes.Buffer
+	zw := gzip.NewWriter(&zbuf)
+	zw.Write(encodeBorders(zones, polys))
+	zw.Close()
+	log.Printf("%d border polygons of %d vertices: %d bytes compressed", len(polys), vertices, zbuf.Len())
+
+	var gen bytes.Buffer
+	gen.WriteString("// Auto-generated file. See README or Makefile.\n")
+	fmt.Fprintf(&gen, "//\n// Border polygons simplified to within %v degrees.\n", *flagBorderTol)
+	gen.WriteString("\npackage latlong\n\n")
+	gen.WriteString("func init() {\n")
+	fmt.Fprintf(&gen, "borderPolysPacked = %q\n", base64.StdEncoding.EncodeToString(zbuf.Bytes()))
+	gen.WriteString("}\n")
+
+	src, err := format.Source(gen.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("z_gen_borders.go", src, 0644); err != nil {
+		t.Fatal(err)
 	}
 }
 
-func (t *tileMeta) erase() {
-	im := t.p.im
-	for y := t.y0; y < t.y1; y += 8 {
-		for x := t.x0; x < t.x1; x += 8 {
-			off := im.PixOffset(x, y)
-			im.Pix[off+3] = alphaErased
+// borderPolygon returns the polygon pts, as (latitude, longitude)
+// vertices for encodeBorders, with each of its rings simplified to
+// within tolerance degrees. Rings simplified to nothing are dropped.
+func borderPolygon(pts []shp.Point, tolerance float64) [][2]float64 {
+	var poly [][2]float64
+	for _, ring := range splitRings(pts) {
+		ring = simplify(ring, tolerance)
+		if len(ring) < 4 { // at least a triangle, closed
+			continue
+		}
+		for _, p := range ring {
+			poly = append(poly, [2]float64{p.Y, p.X})
 		}
 	}
+	return poly
+}
 
+// splitRings splits pts into its closed rings, each ending with its
+// first point. Trailing points not closing a ring are dropped.
+func splitRings(pts []shp.Point) [][]shp.Point {
+	var rings [][]shp.Point
+	start := 0
+	for i := 1; i < len(pts); i++ {
+		if i > start && pts[i] == pts[start] {
+			rings = append(rings, pts[start:i+1])
+			start = i + 1
+			i++
+		}
+	}
+	return rings
 }
 
-func (t *tileMeta) colorTile() (ct colorTile) {
-	im := t.p.im
-	for y := range ct {
-		row := &ct[y]
-		pix := im.Pix[im.PixOffset(t.x0, t.y0+y):]
-		for x := range row {
-			row[x] = color.RGBA{pix[0], pix[1], pix[2], pix[3]}
-			pix = pix[4:]
+func TestBorderPolygon(t *testing.T) {
+	pt := func(x, y float64) shp.Point { return shp.Point{X: x, Y: y} }
+	pts := []shp.Point{
+		// Outer ring, with a bump on the bottom edge to simplify away.
+		pt(0, 0), pt(5, 0.001), pt(10, 0), pt(10, 10), pt(0, 10), pt(0, 0),
+		// A hole.
+		pt(4, 4), pt(6, 4), pt(6, 6), pt(4, 6), pt(4, 4),
+		// A sliver simplified to nothing.
+		pt(20, 20), pt(20.001, 20), pt(20, 20),
+	}
+	if rings := splitRings(pts); len(rings) != 3 || len(rings[0]) != 6 || len(rings[1]) != 5 || len(rings[2]) != 3 {
+		t.Fatalf("splitRings = %v; want rings of 6, 5, and 3 points", rings)
+	}
+	poly := borderPolygon(pts, 0.01)
+	if len(poly) != 10 {
+		t.Fatalf("borderPolygon = %v; want 10 vertices", poly)
+	}
+	polys, err := decodeBorders(encodeBorders([]string{"A"}, [][][2]float64{poly}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polys["A"]) != 1 {
+		t.Fatalf("decoded %v; want one polygon of A", polys)
+	}
+	o := polys["A"][0]
+	for _, tt := range []struct {
+		lat, long float64
+		want      bool
+	}{
+		{1, 1, true},
+		{5, 5, false}, // in the hole
+		{5, 3, true},
+		{11, 5, false},
+	} {
+		if got := o.contains(tt.lat, tt.long); got != tt.want {
+			t.Errorf("contains(%v, %v) = %v; want %v", tt.lat, tt.long, got, tt.want)
 		}
 	}
-	return
 }
 
-type colorTile [8][8]color.RGBA
+func TestGenerateLinks(t *testing.T) {
+	if !*flagGenLinks {
+		t.Skip("skipping link generation without --generate_links flag")
+	}
+	f, err := os.Open(*flagTZDataZI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	version, links, err := parseTZLinks(f)
+	if err != nil {
+		t.Fatalf("%s: %v", *flagTZDataZI, err)
+	}
+	names := make([]string, 0, len(links))
+	for link := range links {
+		names = append(names, link)
+	}
+	sort.Strings(names)
 
-type zoneLookerWriter struct {
-	unbuf bytes.Buffer
-	n     int
-}
+	var gen bytes.Buffer
+	gen.WriteString("// Auto-generated file. See README or Makefile.\n")
+	fmt.Fprintf(&gen, "//\n// Links of tz database release %s.\n", version)
+	gen.WriteString("\npackage latlong\n\n")
+	gen.WriteString("func init() {\n")
+	gen.WriteString("tzLinks = map[string]string{\n")
+	for _, link := range names {
+		fmt.Fprintf(&gen, "%q: %q,\n", link, links[link])
+	}
+	gen.WriteString("}\n")
+	gen.WriteString("}\n") // close init
 
-func (w *zoneLookerWriter) Add(s string) {
-	w.n++
-	if w.n > 0xffff {
-		panic("too many unique leaves")
+	src, err := format.Source(gen.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("z_gen_links.go", src, 0644); err != nil {
+		t.Fatal(err)
 	}
-	w.unbuf.WriteString(s)
-	switch s[0] {
-	case 'S':
-		w.unbuf.WriteByte(0)
-	case '2':
-		if len(s) != 12+1 {
-			panic("unexpected length")
+}
+
+// parseTZLinks reads the tz database in zic's input format, as in
+// tzdata.zi, returning its release and its links, from each link's
+// name to the zone it finally refers to, following links to links.
+func parseTZLinks(r io.Reader) (version string, links map[string]string, err error) {
+	links = map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		switch {
+		case len(f) == 3 && f[0] == "#" && f[1] == "version":
+			version = f[2]
+		case len(f) >= 3 && (f[0] == "L" || f[0] == "Link"):
+			links[f[2]] = f[1]
 		}
-	case 'P':
-		if len(s) != 128+1 {
-			panic("unexpected length")
+	}
+	if err := sc.Err(); err != nil {
+		return "", nil, err
+	}
+	if version == "" {
+		return "", nil, errors.New("no version line")
+	}
+	for link, target := range links {
+		for seen := 0; ; seen++ {
+			next, ok := links[target]
+			if !ok {
+				break
+			}
+			if seen > len(links) {
+				return "", nil, fmt.Errorf("link %q is in a cycle", link)
+			}
+			target = next
 		}
-	default:
-		panic("unexpected type")
+		links[link] = target
+	}
+	return version, links, nil
+}
+
+func TestParseTZLinks(t *testing.T) {
+	const zi = `# version 2099z
+# This zic input file is in the public domain.
+Z America/New_York -4:56:2 - LMT 1883 N 18 17u
+-5 u E%sT
+L America/New_York US/Eastern
+Link US/Eastern Old/Alias
+L Europe/Kyiv Europe/Kiev
+`
+	version, links, err := parseTZLinks(strings.NewReader(zi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"US/Eastern":  "America/New_York",
+		"Old/Alias":   "America/New_York",
+		"Europe/Kiev": "Europe/Kyiv",
+	}
+	if version != "2099z" || !reflect.DeepEqual(links, want) {
+		t.Errorf("parseTZLinks = %q, %v; want 2099z, %v", version, links, want)
+	}
+	if _, _, err := parseTZLinks(strings.NewReader("# version 1\nL A B\nL B A\n")); err == nil {
+		t.Error("parseTZLinks of a cycle succeeded")
 	}
 }
 
 func (w *zoneLookerWriter) Source() []byte {
+	bstr := base64.StdEncoding.EncodeToString(w.Packed())
 	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	zw.Write(w.unbuf.Bytes())
-	zw.Close()
-
-	bstr := base64.StdEncoding.EncodeToString(buf.Bytes())
-	buf.Reset()
 	fmt.Fprintf(&buf, "leaf = make([]zoneLooker, %d)\n", w.n)
 	fmt.Fprintf(&buf, "uniqueLeavesPacked = %q\n", bstr)
 	log.Printf("zone lookers packed line = %d bytes", buf.Len())
@@ -0,0 +1,88 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import "fmt"
+
+// LookupZoneNameGeohash is like LookupZoneName but takes the center of
+// the geohash cell gh, such as "dr5regw" for a cell in lower
+// Manhattan. It returns the empty string if gh is malformed. See
+// DecodeGeohash.
+func LookupZoneNameGeohash(gh string) string {
+	lat, long, err := DecodeGeohash(gh)
+	if err != nil {
+		return ""
+	}
+	return LookupZoneName(lat, long)
+}
+
+// geohashAlphabet are the digits of a geohash, base 32 without a, i,
+// l, or o.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohashDigits maps a geohash digit (of either case) to its value
+// plus one, or zero if it isn't one.
+var geohashDigits [256]uint8
+
+func init() {
+	for i := 0; i < len(geohashAlphabet); i++ {
+		c := geohashAlphabet[i]
+		geohashDigits[c] = uint8(i + 1)
+		if c >= 'a' {
+			geohashDigits[c-'a'+'A'] = uint8(i + 1)
+		}
+	}
+}
+
+// DecodeGeohash returns the center of the geohash cell gh. Each digit
+// halves the cell five times, alternately by longitude and latitude,
+// starting with longitude; the longer gh, the smaller the cell. Upper
+// case digits are accepted too.
+func DecodeGeohash(gh string) (lat, long float64, err error) {
+	if gh == "" {
+		return 0, 0, fmt.Errorf("latlong: empty geohash")
+	}
+	minLat, maxLat := -90.0, 90.0
+	minLong, maxLong := -180.0, 180.0
+	isLong := true
+	for i := 0; i < len(gh); i++ {
+		v := geohashDigits[gh[i]]
+		if v == 0 {
+			return 0, 0, fmt.Errorf("latlong: invalid geohash %q: bad digit %q", gh, gh[i])
+		}
+		v--
+		for bit := uint8(16); bit != 0; bit >>= 1 {
+			if isLong {
+				mid := (minLong + maxLong) / 2
+				if v&bit != 0 {
+					minLong = mid
+				} else {
+					maxLong = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if v&bit != 0 {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			isLong = !isLong
+		}
+	}
+	return (minLat + maxLat) / 2, (minLong + maxLong) / 2, nil
+}
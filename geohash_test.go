@@ -0,0 +1,66 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodeGeohash(t *testing.T) {
+	tests := []struct {
+		gh        string
+		lat, long float64
+		tol       float64
+	}{
+		{"s", 22.5, 22.5, 0},
+		{"dr5regw3pg", 40.7128, -74.0060, 1e-4},
+		{"DR5REGW3PG", 40.7128, -74.0060, 1e-4},
+		{"u09tvw0f6", 48.8566, 2.3522, 1e-4},
+		{"r3gx2f77b", -33.8688, 151.2093, 1e-4},
+		{"xn76cydhz", 35.6762, 139.6503, 1e-4},
+	}
+	for _, tt := range tests {
+		lat, long, err := DecodeGeohash(tt.gh)
+		if err != nil {
+			t.Errorf("DecodeGeohash(%q): %v", tt.gh, err)
+			continue
+		}
+		if math.Abs(lat-tt.lat) > tt.tol || math.Abs(long-tt.long) > tt.tol {
+			t.Errorf("DecodeGeohash(%q) = (%v, %v); want (%v, %v)", tt.gh, lat, long, tt.lat, tt.long)
+		}
+	}
+	for _, gh := range []string{"", "dr5a", "u09 t", "ü"} {
+		if lat, long, err := DecodeGeohash(gh); err == nil {
+			t.Errorf("DecodeGeohash(%q) = (%v, %v); want error", gh, lat, long)
+		}
+	}
+}
+
+func TestLookupZoneNameGeohash(t *testing.T) {
+	for gh, want := range map[string]string{
+		"dr5regw": "America/New_York",
+		"u09tvw0": "Europe/Paris",
+		"r3gx2f7": "Australia/Sydney",
+		"xn76cyd": "Asia/Tokyo",
+		"dr5a":    "",
+	} {
+		if got := LookupZoneNameGeohash(gh); got != want {
+			t.Errorf("LookupZoneNameGeohash(%q) = %q; want %q", gh, got, want)
+		}
+	}
+}
@@ -20,110 +20,1452 @@ limitations under the License.
 // to an internal form optimized for low memory overhead and fast lookups
 // at the expense of perfect accuracy when close to borders. The data files
 // are compiled in to this package and do not require explicit loading.
+//
+// Everything in the package is safe for concurrent use by multiple
+// goroutines. The compiled-in tables are unpacked by whichever lookup
+// needs them first, with any others waiting for it, or up front by
+// Warm. The same goes for a Lookuper's methods: lookups may run
+// concurrently with each other and with Warm, Release, and Override,
+// and new Lookupers may be created while others are in use. The one
+// exception is Close, after which a Lookuper must not be used, so it
+// must not be called while lookups are in progress.
 package latlong
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"log"
+	"math"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Populated by z_gen_tables.go:
 var (
 	degPixels          = -1
-	zoomLevels         [6]*zoomLevel
+	zoomLevels         []*zoomLevel // by size shift, up to the largest tiles generated
 	uniqueLeavesPacked string
 	leaf               []zoneLooker
+	dataVersion        string
+	partialTables      bool // generated with --bbox or --zones, so only for some zones
+	dataYear           int  // from --year, the year whose borders the tables have; 0 if current
 )
 
+// DataVersion describes the compiled-in timezone tables: the dataset
+// they were generated from and its date, the scale in pixels per
+// degree, and the number of zones, as in
+//
+//	tz_world 2016-05-28, scale 32, 417 zones
+//
+// Binaries built from the same tables report the same DataVersion.
+// It's "unknown" for tables generated before it was recorded.
+func DataVersion() string {
+	if dataVersion == "" {
+		return "unknown"
+	}
+	return dataVersion
+}
+
 // LookupZoneName returns the timezone name at the given latitude and
 // longitude. The returned name is either the empty string (if not
 // found) or a name suitable for passing to time.LoadLocation. For
 // example, "America/New_York".
+//
+// On the Antarctic continent, where the tables have no zones, it
+// returns the zone of the nearest research station that has one, such
+// as "Antarctica/McMurdo". That's only an approximation; see
+// antarctica.go. The Southern Ocean has no zone.
+//
+// Only the first lookup unpacks the tables; later ones don't allocate.
 func LookupZoneName(lat, long float64) string {
-	x := int((long + 180) * float64(degPixels))
-	y := int((90 - lat) * float64(degPixels))
+	return defaultLookuper().LookupName(lat, long)
+}
+
+// SameZone reports whether the two coordinates resolve to the same
+// timezone in the compiled-in tables. See Lookuper.SameZone.
+func SameZone(lat1, long1, lat2, long2 float64) bool {
+	return defaultLookuper().SameZone(lat1, long1, lat2, long2)
+}
+
+// IsBorderCoordinate reports whether the given coordinate may be near
+// a timezone border, and so its zone less reliable: whether any of the
+// four points epsilonDegrees north, south, east and west of it
+// resolves to a different zone than it does, as by LookupZoneName. A
+// coast, where one of them has no zone, counts as a border. It doesn't
+// find borders passing between the points, so epsilonDegrees should
+// be small compared to the regions of interest.
+func IsBorderCoordinate(lat, long float64, epsilonDegrees float64) bool {
+	l := defaultLookuper()
+	zone := l.LookupName(lat, long)
+	e := math.Abs(epsilonDegrees)
+	for _, d := range [4][2]float64{{e, 0}, {-e, 0}, {0, e}, {0, -e}} {
+		if l.LookupName(lat+d[0], long+d[1]) != zone {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returned by LookupZoneNameStrict.
+var (
+	ErrLatitudeRange  = errors.New("latlong: latitude out of range [-90, 90]")
+	ErrLongitudeRange = errors.New("latlong: longitude out of range [-180, 180]")
+)
+
+// LookupZoneNameStrict is like LookupZoneName, but rather than
+// clamping or wrapping invalid coordinates, it returns
+// ErrLatitudeRange or ErrLongitudeRange for them, including NaNs. A
+// nil error with an empty zone therefore means a valid coordinate with
+// no timezone, such as in the ocean.
+func LookupZoneNameStrict(lat, long float64) (zone string, err error) {
+	if !(lat >= -90 && lat <= 90) {
+		return "", ErrLatitudeRange
+	}
+	if !(long >= -180 && long <= 180) {
+		return "", ErrLongitudeRange
+	}
+	return LookupZoneName(lat, long), nil
+}
+
+// NormalizeCoordinate returns the given coordinate with its longitude
+// wrapped into [-180, 180) and its latitude clamped to [-90, 90], the
+// way lookups treat them. A longitude of 350, as from GPS feeds giving
+// longitudes from 0 to 360, becomes -10, and 180 becomes -180. NaNs
+// are returned unchanged.
+func NormalizeCoordinate(lat, long float64) (float64, float64) {
+	if lat > 90 {
+		lat = 90
+	} else if lat < -90 {
+		lat = -90
+	}
+	return lat, wrapLong(long)
+}
+
+// LookupZoneNameConfidence is like LookupZoneName, but also returns
+// the width, in degrees, of the tile that answered the lookup. Tiles
+// range from 8 pixels (a quarter of a degree) to 256 pixels (8
+// degrees) square. A large tile means the coordinate is far from any
+// border the tables know about. A small one means the zone may be
+// wrong if the coordinate is close to a border. If no tile covers the
+// coordinate, or the zone is from an override or the Antarctic
+// fallback rather than a tile, tileSizeDegrees is zero.
+func LookupZoneNameConfidence(lat, long float64) (zone string, tileSizeDegrees float64) {
+	l := defaultLookuper()
+	if l.degPixels == -1 {
+		return l.LookupName(lat, long), 0
+	}
+	if zone, ok := l.override(lat, long); ok {
+		return zone, 0
+	}
+	t := l.mustLoad()
+	x, y := l.pixelOf(lat, long)
+	if zl, tk := t.lookupLeaf(x, y); zl != nil {
+		zone, _ = zl.LookupZone(t.leaf, x, y, tk)
+		tileSizeDegrees = float64(int(8)<<tk.size()) / float64(l.degPixels)
+	}
+	if zone == "" && l.fallback != nil {
+		if fz := l.fallback(lat, long); fz != "" {
+			return fz, 0
+		}
+	}
+	return zone, tileSizeDegrees
+}
+
+// NearestRadius is how far, in degrees, NearestZoneName searches for a
+// timezone around a coordinate that has none.
+var NearestRadius = 2.0
+
+// NearestZoneName is like LookupZoneName, but if there is no timezone
+// at the given latitude and longitude (for instance, just offshore),
+// it returns the name of the nearest timezone within NearestRadius
+// degrees. It returns the empty string if no timezone is that close.
+// Overrides (see Override) apply at the coordinate itself, but the
+// search around it only finds zones of the tables and the Antarctic
+// fallback.
+//
+// The search expands outward in rings of pixels and measures distance
+// in degrees rather than along the Earth's surface, so the result is
+// only an approximation of the geographically nearest timezone. When
+// the coordinate has no timezone, NearestZoneName is much slower than
+// LookupZoneName.
+func NearestZoneName(lat, long float64) string {
+	l := defaultLookuper()
+	if zone := l.LookupName(lat, long); zone != "" {
+		return zone
+	}
+	x, y := l.pixelOf(lat, long)
+	width := 360 * l.degPixels
+	maxR := int(NearestRadius * float64(l.degPixels))
+	for r := 1; r <= maxR; r++ {
+		var best string
+		var bestDist int
+		for dy := -r; dy <= r; dy++ {
+			py := y + dy
+			if py < 0 || py >= 180*l.degPixels {
+				continue
+			}
+			// Only the ring's edges: every pixel on its top and
+			// bottom rows, but only the ends of the others.
+			step := 2 * r
+			if dy == -r || dy == r {
+				step = 1
+			}
+			for dx := -r; dx <= r; dx += step {
+				// Wrap around the antimeridian, whose two sides
+				// are neighbors.
+				px := ((x+dx)%width + width) % width
+				zone := l.pixelName(px, py)
+				if zone == "" {
+					continue
+				}
+				if d := dx*dx + dy*dy; best == "" || d < bestDist {
+					best, bestDist = zone, d
+				}
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	return ""
+}
+
+// earthRadiusKm is the Earth's mean radius.
+const earthRadiusKm = 6371.0
+
+// haversineKm returns the great-circle distance, in kilometers,
+// between two points given in degrees.
+func haversineKm(lat1, long1, lat2, long2 float64) float64 {
+	const rad = math.Pi / 180
+	sinLat := math.Sin((lat2 - lat1) * rad / 2)
+	sinLong := math.Sin((long2 - long1) * rad / 2)
+	a := sinLat*sinLat + math.Cos(lat1*rad)*math.Cos(lat2*rad)*sinLong*sinLong
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// NearestZoneNameWithin is like LookupZoneName, but if there is no
+// timezone at the given latitude and longitude, it returns the
+// timezone geographically nearest to it, measured along the Earth's
+// surface, as long as it's within maxKm kilometers. The ok result
+// reports whether a timezone was found.
+//
+// Unlike NearestZoneName, it isn't misled by longitude lines
+// converging toward the poles, but it's slower: it measures the
+// distance to the center of every pixel of the tables that might be
+// close enough, which near the poles may be tens of thousands of them.
+func NearestZoneNameWithin(lat, long float64, maxKm float64) (zone string, ok bool) {
+	l := defaultLookuper()
+	if zone := l.LookupName(lat, long); zone != "" {
+		return zone, true
+	}
+	if l.degPixels == -1 || !(maxKm > 0) {
+		return "", false
+	}
+	x0, y0 := l.pixelOf(lat, long)
+	width, height := 360*l.degPixels, 180*l.degPixels
+	deg := 1 / float64(l.degPixels) // pixel size in degrees
+	kmPerDeg := earthRadiusKm * math.Pi / 180
+
+	// Visit rows outward from y0, stopping once a row's latitude
+	// alone puts it farther than the best match so far.
+	best, bestKm := "", maxKm
+	maxRows := int(maxKm/kmPerDeg/deg) + 1
+	for dy := 0; dy <= maxRows; dy++ {
+		if float64(dy-1)*deg*kmPerDeg > bestKm {
+			break
+		}
+		for i, y := range [2]int{y0 - dy, y0 + dy} {
+			if y < 0 || y >= height || (dy == 0 && i == 1) {
+				continue
+			}
+			rowLat := 90 - (float64(y)+0.5)*deg
+			// Longitude span within reach at this row's latitude.
+			cos := math.Cos(math.Max(math.Abs(rowLat)-deg, 0) * math.Pi / 180)
+			cols := width / 2
+			if cos > 0 {
+				if c := bestKm/(kmPerDeg*cos)/deg + 1; c < float64(cols) {
+					cols = int(c)
+				}
+			}
+			for dx := -cols; dx <= cols; dx++ {
+				x := ((x0+dx)%width + width) % width
+				z := l.pixelName(x, y)
+				if z == "" {
+					continue
+				}
+				d := haversineKm(lat, long, rowLat, (float64(x)+0.5)*deg-180)
+				if d <= bestKm {
+					best, bestKm = z, d
+				}
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// dominantSamples bounds the number of rows and columns of pixels
+// DominantZone samples.
+const dominantSamples = 256
+
+// DominantZone returns the timezone covering the largest part of the
+// circle of radius radiusKm kilometers around the given latitude and
+// longitude, and the fraction of the circle's area it covers, such as
+// to find the zone that best represents a neighborhood when a point
+// lies just across a border. The area without a timezone, such as the
+// sea, counts toward the circle's area but is never the result.
+//
+// The circle is measured along the Earth's surface, by the distance to
+// the center of each pixel of the tables, and pixels are weighted by
+// their area. For large circles, only a grid of about 256 by 256 of
+// the pixels is sampled. On the Antarctic continent, pixels without a
+// zone get LookupZoneName's Antarctic one. If no pixel's center is
+// within the circle, as for a tiny radius, the result is the zone at
+// the coordinate, as from LookupZoneName, covering all of it.
+// DominantZone returns the empty string and 0 if there is no timezone
+// in the circle.
+func DominantZone(lat, long float64, radiusKm float64) (zone string, fraction float64) {
+	l := defaultLookuper()
+	if l.degPixels == -1 || !(radiusKm > 0) {
+		return "", 0
+	}
+	lat, long = NormalizeCoordinate(lat, long)
+	x0, y0 := l.pixelOf(lat, long)
+	width, height := 360*l.degPixels, 180*l.degPixels
+	deg := 1 / float64(l.degPixels) // pixel size in degrees
+	kmPerDeg := earthRadiusKm * math.Pi / 180
+
+	rows := int(radiusKm/kmPerDeg/deg) + 1
+	step := 1
+	if 2*rows > dominantSamples {
+		step = (2*rows + dominantSamples - 1) / dominantSamples
+	}
+	area := map[string]float64{}
+	var total float64
+	for dy := -rows; dy <= rows; dy += step {
+		y := y0 + dy
+		if y < 0 || y >= height {
+			continue
+		}
+		rowLat := 90 - (float64(y)+0.5)*deg
+		// Longitude span within reach at this row's latitude.
+		cols := width / 2
+		cos := math.Cos(math.Max(math.Abs(rowLat)-deg, 0) * math.Pi / 180)
+		if cos > 0 {
+			if c := radiusKm/(kmPerDeg*cos)/deg + 1; c < float64(cols) {
+				cols = int(c)
+			}
+		}
+		colStep := step
+		if 2*cols > dominantSamples*step {
+			colStep = (2*cols + dominantSamples - 1) / dominantSamples
+		}
+		// The area the row's samples stand for.
+		weight := math.Cos(rowLat*math.Pi/180) * float64(colStep)
+		for dx := -cols / colStep * colStep; dx <= cols; dx += colStep {
+			x := ((x0+dx)%width + width) % width
+			pixLong := (float64(x)+0.5)*deg - 180
+			if haversineKm(lat, long, rowLat, pixLong) > radiusKm {
+				continue
+			}
+			total += weight
+			z := l.lookupPixel(x, y)
+			if z == "" && l.fallback != nil {
+				z = l.fallback(rowLat, pixLong)
+			}
+			if z != "" {
+				area[z] += weight
+			}
+		}
+	}
+	if total == 0 {
+		if zone := l.LookupName(lat, long); zone != "" {
+			return zone, 1
+		}
+		return "", 0
+	}
+	var best float64
+	for z, a := range area {
+		if a > best || a == best && z < zone {
+			zone, best = z, a
+		}
+	}
+	return zone, best / total
+}
+
+// LookupZoneNameNautical is like LookupZoneName, but where there is
+// no timezone (for instance, out at sea) it returns the nautical
+// timezone for the longitude instead: one of the 25 "Etc/GMT" zones,
+// each of which covers 15 degrees of longitude centered on a multiple
+// of 15 degrees, except for the 7.5 degree wide zones on either side
+// of the antimeridian.
+//
+// Note that the signs of the "Etc/GMT" names are inverted from the
+// usual sense: east of Greenwich, where clocks are ahead of UTC, the
+// zones are "Etc/GMT-1" through "Etc/GMT-12", and to the west they
+// are "Etc/GMT+1" through "Etc/GMT+12".
+func LookupZoneNameNautical(lat, long float64) string {
+	if zone := LookupZoneName(lat, long); zone != "" {
+		return zone
+	}
+	return nauticalZoneName(long)
+}
+
+// nauticalZoneName returns the nautical timezone for the given
+// longitude.
+func nauticalZoneName(long float64) string {
+	if long != long {
+		return ""
+	}
+	hours := nauticalHours(long)
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("Etc/GMT-%d", hours)
+	case hours < 0:
+		return fmt.Sprintf("Etc/GMT+%d", -hours)
+	}
+	return "Etc/GMT"
+}
+
+// nauticalHours returns the offset from UTC, in hours east, of the
+// nautical timezone for the given longitude: -12 to 12.
+func nauticalHours(long float64) int {
+	return int(math.Floor((wrapLong(long) + 7.5) / 15))
+}
+
+// LookupOffsetBestEffort returns the offset from UTC, in seconds east,
+// at the given latitude and longitude at time t. Unlike LookupZone, it
+// always returns a plausible offset, falling back in turn to:
+//
+//  1. the offset at t of the zone LookupZoneName returns, if any;
+//  2. if that zone couldn't be loaded, for lack of timezone data, its
+//     standard offset from LookupStandardOffset, ignoring daylight
+//     saving time, if the offsets were generated;
+//  3. otherwise, as out at sea, the whole-hour nautical offset for the
+//     longitude, as for LookupZoneNameNautical.
+//
+// Nautical time is only a convention, which ships may not keep, but
+// it's within an hour or so of local solar time, unlike the zero
+// offset of assuming UTC where there's no zone. It returns 0 for NaN
+// coordinates.
+func LookupOffsetBestEffort(lat, long float64, t time.Time) int {
+	if lat != lat || long != long {
+		return 0
+	}
+	if zone := LookupZoneName(lat, long); zone != "" {
+		if loc, err := defaultLookuper().loadLocation(zone); err == nil {
+			_, off := t.In(loc).Zone()
+			return off
+		}
+		if off, ok := standardOffsets[zone]; ok {
+			return int(off)
+		}
+	}
+	return nauticalHours(long) * 3600
+}
+
+// maxCandidates is the most zones LookupZoneCandidates returns.
+const maxCandidates = 5
+
+// LookupZoneCandidates returns the distinct timezones near the given
+// latitude and longitude, nearest first, for offering choices close
+// to a border. It considers every pixel of the smallest-size tile (a
+// quarter degree square) containing the coordinate and of the 8 tiles
+// around it, ordering zones by their closest pixel. At most 5 zones
+// are returned. The slice is empty if there are none.
+//
+// Its first element is usually what LookupZoneName returns, unless
+// the coordinate itself has no timezone.
+func LookupZoneCandidates(lat, long float64) []string {
+	l := defaultLookuper()
+	if l.degPixels == -1 {
+		return nil
+	}
+	x, y := l.pixelOf(lat, long)
+	width, height := 360*l.degPixels, 180*l.degPixels
+	tx, ty := x&^7, y&^7
+
+	dist := map[string]int{}
+	for py := ty - 8; py < ty+16; py++ {
+		if py < 0 || py >= height {
+			continue
+		}
+		for px := tx - 8; px < tx+16; px++ {
+			zone := l.lookupPixel((px+width)%width, py)
+			if zone == "" {
+				continue
+			}
+			dx, dy := px-x, py-y
+			if d, ok := dist[zone]; !ok || dx*dx+dy*dy < d {
+				dist[zone] = dx*dx + dy*dy
+			}
+		}
+	}
+	zones := make([]string, 0, len(dist))
+	for zone := range dist {
+		zones = append(zones, zone)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if di, dj := dist[zones[i]], dist[zones[j]]; di != dj {
+			return di < dj
+		}
+		return zones[i] < zones[j]
+	})
+	if len(zones) > maxCandidates {
+		zones = zones[:maxCandidates]
+	}
+	return zones
+}
+
+// LookupZoneNames returns the timezone names at each of the given
+// (latitude, longitude) pairs. The returned slice is the same length
+// as coords and each element is what LookupZoneName would return for
+// the corresponding coordinate.
+//
+// For batches of nearby points in order, such as GPS tracks, it is
+// faster than calling LookupZoneName for each point: consecutive
+// points on the same pixel are only resolved once, and consecutive
+// points in the same tile reuse that tile instead of searching for it
+// again.
+func LookupZoneNames(coords [][2]float64) []string {
+	return defaultLookuper().LookupNames(coords)
+}
+
+// LookupZoneNamesFunc is like LookupZoneNames, but rather than
+// returning a slice of names, it calls fn with the index in coords and
+// the name of each coordinate, in order, so callers streaming the
+// names elsewhere needn't allocate a slice for them. It uses the same
+// optimizations for nearby points as LookupZoneNames. fn is called
+// synchronously, and coords must not be modified until
+// LookupZoneNamesFunc returns.
+func LookupZoneNamesFunc(coords [][2]float64, fn func(i int, zone string)) {
+	defaultLookuper().LookupNamesFunc(coords, fn)
+}
+
+// LookupZoneNamesContext is like LookupZoneNames, but checks
+// periodically whether ctx is done and, if so, stops early. It then
+// returns ctx.Err() and the names of the coordinates resolved so far:
+// the returned slice is truncated, so its length is the number of
+// leading coordinates resolved, and each of its elements is valid.
+// Otherwise it returns the names of all coordinates and a nil error.
+func LookupZoneNamesContext(ctx context.Context, coords [][2]float64) ([]string, error) {
+	return defaultLookuper().LookupNamesContext(ctx, coords)
+}
+
+// ZoneContains reports whether the timezone at the given latitude and
+// longitude is zone; that is, whether LookupZoneName(lat, long) ==
+// zone. It's useful for checking that a user's configured timezone is
+// plausible for their location.
+//
+// Because the tables aren't exact near borders, callers checking a
+// claimed zone may want to tolerate a mismatch close to a border; see
+// LookupZoneNameConfidence.
+func ZoneContains(zone string, lat, long float64) bool {
+	return LookupZoneName(lat, long) == zone
+}
+
+// CoordinatesInZone is the bulk form of ZoneContains. It returns,
+// for each (latitude, longitude) pair in coords, whether its timezone
+// is zone.
+func CoordinatesInZone(zone string, coords [][2]float64) []bool {
+	in := make([]bool, len(coords))
+	for i, name := range LookupZoneNames(coords) {
+		in[i] = name == zone
+	}
+	return in
+}
+
+// A ZoneChange is a point along a path where its timezone changes; see
+// ZoneChanges.
+type ZoneChange struct {
+	Index int    // index in the path of the first point in Zone
+	Zone  string // the timezone from that point on
+}
+
+// ZoneChanges returns where the timezone changes along path, an
+// ordered list of (latitude, longitude) pairs such as a GPS track: the
+// first point with a timezone and its zone, then each point whose zone
+// differs from the previous one's. Points without a timezone, such as
+// at sea, don't change it: the path is taken to stay in the previous
+// point's zone until it reaches another. So a path with no timezone
+// anywhere has no changes, and one crossing a lake back into the same
+// zone has none there. It looks the points up as LookupZoneNames does.
+func ZoneChanges(path [][2]float64) []ZoneChange {
+	var changes []ZoneChange
+	cur := ""
+	LookupZoneNamesFunc(path, func(i int, zone string) {
+		if zone != "" && zone != cur {
+			changes = append(changes, ZoneChange{Index: i, Zone: zone})
+			cur = zone
+		}
+	})
+	return changes
+}
+
+// ZoneNames returns the sorted names of all the timezones in the
+// compiled-in tables: every non-empty name LookupZoneName can return.
+// The returned slice is a new copy on each call.
+func ZoneNames() []string {
+	return defaultLookuper().zoneNames()
+}
+
+// zoneNames implements ZoneNames: l's names, plus those of the
+// Antarctic stations if l falls back to them.
+func (l *Lookuper) zoneNames() []string {
+	names := l.Names()
+	if len(names) == 0 || l.fallback == nil {
+		return names
+	}
+	for _, name := range antarcticZoneNames() {
+		if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupZone returns the timezone at the given latitude and longitude.
+// If no timezone is found (for instance, in the ocean), the returned
+// Location and error are both nil.
+//
+// The zone names in the compiled-in tables were all valid when they
+// were generated, but the system's zoneinfo database may be older and
+// lack some of them. If the zone was found but its timezone data
+// couldn't be loaded, the error is a *ZoneLoadError naming the zone,
+// which LookupZoneName still returns.
+func LookupZone(lat, long float64) (*time.Location, error) {
+	return defaultLookuper().Location(lat, long)
+}
+
+// A ZoneLoadError is returned by LookupZone and Lookuper.Location when
+// they find a zone whose timezone data can't be loaded by
+// time.LoadLocation.
+type ZoneLoadError struct {
+	Zone string // the zone's name, such as "America/Ciudad_Juarez"
+	Err  error  // the error from time.LoadLocation
+}
+
+func (e *ZoneLoadError) Error() string {
+	return fmt.Sprintf("latlong: loading zone %q: %v", e.Zone, e.Err)
+}
+
+// timeLoadLocation is time.LoadLocation, except in tests simulating a
+// zoneinfo database without some zones. (Setting $ZONEINFO can't do
+// that, as the time package falls back to the system's database and
+// only reads $ZONEINFO once anyway.)
+var timeLoadLocation = time.LoadLocation
+
+// LookupOffset returns the offset from UTC, in seconds east, in effect
+// at time t at the given latitude and longitude. The time is needed
+// to account for daylight saving time.
+//
+// If no timezone is found, or if its timezone data is unavailable
+// (for instance, on systems without a zoneinfo database), ok is false.
+func LookupOffset(lat, long float64, t time.Time) (offsetSeconds int, ok bool) {
+	loc, err := LookupZone(lat, long)
+	if loc == nil || err != nil {
+		return 0, false
+	}
+	_, offsetSeconds = t.In(loc).Zone()
+	return offsetSeconds, true
+}
+
+// LookupUTCOffsetHours is like LookupOffset, but returns the offset in
+// hours, for grouping coordinates by offset ("UTC-5") regardless of
+// their zone. Offsets that aren't whole hours are fractional, such as
+// 5.5 for India and 5.75 for Nepal.
+//
+// As with LookupOffset, ok is false if no timezone is found or its
+// timezone data is unavailable.
+func LookupUTCOffsetHours(lat, long float64, t time.Time) (hours float64, ok bool) {
+	offsetSeconds, ok := LookupOffset(lat, long, t)
+	if !ok {
+		return 0, false
+	}
+	return float64(offsetSeconds) / 3600, true
+}
+
+// Warm unpacks the compiled-in timezone tables, which otherwise happens
+// on the first lookup. See Lookuper.Warm.
+func Warm() {
+	defaultLookuper().Warm()
+}
+
+// Validate checks that the compiled-in timezone tables are intact. See
+// Lookuper.Validate.
+func Validate() error {
+	return defaultLookuper().Validate()
+}
+
+// lookupPixel returns the timezone name at pixel (x, y) of the
+// compiled-in tables.
+func lookupPixel(x, y int) string {
+	return defaultLookuper().lookupPixel(x, y)
+}
+
+var (
+	compiledOnce sync.Once
+	compiled     *Lookuper
+)
+
+// defaultLookuper returns the Lookuper for the compiled-in timezone
+// tables, which the package-level functions use.
+func defaultLookuper() *Lookuper {
+	compiledOnce.Do(func() {
+		compiled = newCompiledLookuper()
+	})
+	return compiled
+}
+
+// NewLookuperFromCompiled returns a new Lookuper for the compiled-in
+// timezone tables, separate from the one the package-level functions
+// use: its overrides, released tables, and cached locations are its
+// own. With ReadLookuper, this lets a program compare the compiled-in
+// tables against others, such as a newer dataset, in one process.
+//
+// The tables are unpacked and checked before it returns.
+func NewLookuperFromCompiled() (*Lookuper, error) {
+	if degPixels == -1 {
+		return nil, errors.New("latlong: tables not generated yet")
+	}
+	l := newCompiledLookuper()
+	if _, err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// newCompiledLookuper returns a new Lookuper for the compiled-in
+// tables. Its tables are unpacked on first use.
+func newCompiledLookuper() *Lookuper {
+	l := &Lookuper{
+		degPixels: degPixels,
+		leafData:  base64Gzip(uniqueLeavesPacked),
+		numLeaves: len(leaf),
+		year:      int32(dataYear),
+	}
+	if !partialTables {
+		// Tables built for only some zones resolve nothing else,
+		// including Antarctica.
+		l.fallback = antarcticZone
+	}
+	l.levelData = make([]func() io.Reader, len(zoomLevels))
+	for i, zl := range zoomLevels {
+		if zl != nil {
+			l.levelData[i] = base64Gzip(zl.gzipData)
+		}
+	}
+	return l
+}
+
+// base64Gzip returns a func returning readers of the compiled-in,
+// base64-encoded gzip data s.
+func base64Gzip(s string) func() io.Reader {
+	return func() io.Reader {
+		return base64.NewDecoder(base64.StdEncoding, strings.NewReader(s))
+	}
+}
+
+// A Lookuper maps latitudes and longitudes to names using tile tables
+// in the format this package's generator produces. The package-level
+// functions such as LookupZoneName use a Lookuper for the compiled-in
+// timezone tables, but a Lookuper may be built from tables for any
+// kind of region with NewLookuper.
+//
+// A Lookuper is safe for concurrent use by multiple goroutines.
+type Lookuper struct {
+	degPixels int                // pixels per degree
+	levelData []func() io.Reader // gzip of each level's tile index, by size shift; see compact.go
+	leafData  func() io.Reader   // gzip of the packed leaves
+	numLeaves int                // expected number of leaves, or 0 if unknown
+	year      int32              // year whose borders the tables have, or 0; see Year. Accessed atomically.
+
+	// fallback, if non-nil, names the region at coordinates the
+	// tables don't resolve. The compiled-in tables use it for
+	// Antarctica; see antarcticZone.
+	fallback func(lat, long float64) string
+
+	unpackMu    sync.Mutex      // serializes unpacking
+	unpackErr   error           // sticky error from unpacking; guarded by unpackMu
+	degraded    *unpackedTables // on unpackErr, the tables without their corrupt parts; guarded by unpackMu
+	corruptOnce sync.Once       // for mustLoad's warning about corrupt tables
+	tables      atomic.Value    // *unpackedTables, nil until unpacked or after Release
+	unmap       func() error    // for OpenMapped, until Close; guarded by unpackMu
+	shards      [][]byte        // tables files added by LoadShard; guarded by unpackMu
+
+	locs sync.Map // zone name -> *time.Location
+
+	overrideMu sync.Mutex   // serializes Override
+	overrides  atomic.Value // []override, copied on write
+}
+
+// NewLookuper returns a Lookuper for the given tables, as produced by
+// this package's generator. The tables have degPixels pixels per
+// degree. The levels are the gzip-compressed tile indexes of each zoom
+// level, from 8 pixel square tiles at index 0 up to 256 pixel square
+// tiles at index 5, and leaves is the gzip-compressed list of leaves
+// they refer to, which begins with the names themselves.
+//
+// The tables are unpacked and checked before NewLookuper returns. The
+// Lookuper retains the provided slices, which must not be modified.
+func NewLookuper(degPixels int, levels [6][]byte, leaves []byte) (*Lookuper, error) {
+	if degPixels <= 0 {
+		return nil, fmt.Errorf("latlong: invalid degPixels %d", degPixels)
+	}
+	l := &Lookuper{
+		degPixels: degPixels,
+		levelData: levelReaders(levels[:]),
+		leafData:  bytesReader(leaves),
+	}
+	if _, err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// bytesReader returns a func returning readers of b.
+func bytesReader(b []byte) func() io.Reader {
+	return func() io.Reader { return bytes.NewReader(b) }
+}
+
+// levelReaders returns funcs returning readers of each of levels.
+func levelReaders(levels [][]byte) []func() io.Reader {
+	rs := make([]func() io.Reader, len(levels))
+	for i, b := range levels {
+		rs[i] = bytesReader(b)
+	}
+	return rs
+}
+
+// Warm unpacks l's tables now rather than on its first lookup, for
+// callers that would rather pay that cost (a few milliseconds) up
+// front. It is safe to call more than once and concurrently with
+// lookups; the tables are only unpacked once, unless released by
+// Release.
+//
+// Unpacked, the compiled-in timezone tables occupy about 900 KB of
+// heap, versus about 300 KB for their compressed form, which is part
+// of the binary rather than the heap.
+func (l *Lookuper) Warm() {
+	if l.degPixels == -1 {
+		return
+	}
+	l.mustLoad()
+}
+
+// Validate unpacks l's tables, if they haven't been already, and
+// checks that they're self-consistent, returning an error describing
+// the first problem found. Unpacking already checks that every tile
+// and leaf refers to a leaf that exists; Validate also checks that
+// each zoom level's tile keys are sorted without duplicates, so the
+// binary search of lookups finds them, that they have that level's
+// size, and that they're on the map. Corrupt tables would otherwise
+// silently resolve coordinates to the wrong zones, so programs that
+// can't tolerate that may call Validate at startup.
+func (l *Lookuper) Validate() error {
+	if l.degPixels == -1 {
+		return errors.New("latlong: tables not generated")
+	}
+	t, err := l.load()
+	if err != nil {
+		return err
+	}
+	width, height := 360*l.degPixels, 180*l.degPixels
+	for i, zl := range t.levels {
+		if len(zl.idxs) != len(zl.keys) {
+			return fmt.Errorf("latlong: zoom level %d: %d tiles but %d leaf indexes", i, len(zl.keys), len(zl.idxs))
+		}
+		for j, tk := range zl.keys {
+			switch shift := uint(i) + 3; {
+			case tk.size() != uint8(i):
+				return fmt.Errorf("latlong: zoom level %d: tile %x has size %d", i, tk, tk.size())
+			case j > 0 && tk == zl.keys[j-1]:
+				return fmt.Errorf("latlong: zoom level %d: tile %x appears twice", i, tk)
+			case j > 0 && tk < zl.keys[j-1]:
+				return fmt.Errorf("latlong: zoom level %d: tile %x is after %x; keys must be sorted", i, tk, zl.keys[j-1])
+			case int(tk.x())<<shift >= width || int(tk.y())<<shift >= height:
+				return fmt.Errorf("latlong: zoom level %d: tile %x at (%d, %d) is off the map", i, tk, tk.x(), tk.y())
+			}
+		}
+	}
+	return nil
+}
+
+// Release drops l's unpacked tables, so the garbage collector can
+// reclaim their memory. They're unpacked again on the next lookup, so
+// for long-running programs that only look up coordinates
+// occasionally, Release trades memory between bursts of lookups for a
+// few milliseconds of latency at the start of each. It is safe to call
+// concurrently with lookups: lookups in progress finish with the
+// tables they started with.
+func (l *Lookuper) Release() {
+	l.unpackMu.Lock()
+	defer l.unpackMu.Unlock()
+	l.tables.Store((*unpackedTables)(nil))
+}
+
+// LookupName returns the name of the region at the given latitude and
+// longitude, or the empty string if there is none.
+func (l *Lookuper) LookupName(lat, long float64) string {
+	if zone, ok := l.override(lat, long); ok {
+		return zone
+	}
+	zone := l.lookupPixel(l.pixelOf(lat, long))
+	if zone == "" && l.fallback != nil {
+		return l.fallback(lat, long)
+	}
+	return zone
+}
+
+// SameZone reports whether the two coordinates resolve to the same
+// region. Coordinates without a region, such as two points in the
+// ocean, are never in the same one, since an unknown region isn't
+// equal to anything.
+func (l *Lookuper) SameZone(lat1, long1, lat2, long2 float64) bool {
+	zone := l.LookupName(lat1, long1)
+	return zone != "" && zone == l.LookupName(lat2, long2)
+}
+
+// LookupNames returns the names of the regions at each of the given
+// (latitude, longitude) pairs. See LookupZoneNames.
+func (l *Lookuper) LookupNames(coords [][2]float64) []string {
+	names, _ := l.lookupNames(context.Background(), coords)
+	return names
+}
+
+// Names returns the sorted, distinct names of l's regions. The
+// returned slice is a new copy on each call.
+func (l *Lookuper) Names() []string {
+	if l.degPixels == -1 {
+		return nil
+	}
+	var names []string
+	for _, z := range l.mustLoad().leaf {
+		if z, ok := z.(staticZone); ok {
+			names = append(names, string(z))
+		}
+	}
+	sort.Strings(names)
+	// Generated tables have each name once, but dedup anyway.
+	out := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// LookupNamesContext is like LookupNames but stops early if ctx is
+// done. See LookupZoneNamesContext.
+func (l *Lookuper) LookupNamesContext(ctx context.Context, coords [][2]float64) ([]string, error) {
+	return l.lookupNames(ctx, coords)
+}
+
+// ctxCheckInterval is how many coordinates lookupNames resolves
+// between checks of its context.
+const ctxCheckInterval = 1024
+
+// namesFuncWindow is how many coordinates LookupNamesFunc resolves
+// at a time.
+const namesFuncWindow = 256
+
+// LookupNamesFunc calls fn with the name of the region at each of the
+// given (latitude, longitude) pairs. See LookupZoneNamesFunc.
+func (l *Lookuper) LookupNamesFunc(coords [][2]float64, fn func(i int, zone string)) {
+	var buf [namesFuncWindow]string
+	for off := 0; off < len(coords); off += len(buf) {
+		window := coords[off:]
+		if len(window) > len(buf) {
+			window = window[:len(buf)]
+		}
+		names := buf[:len(window)]
+		l.lookupInto(context.Background(), window, names)
+		for i, name := range names {
+			fn(off+i, name)
+		}
+	}
+}
+
+// lookupNames implements LookupNames and LookupNamesContext. If ctx
+// is done, it returns the names resolved so far and ctx.Err().
+func (l *Lookuper) lookupNames(ctx context.Context, coords [][2]float64) ([]string, error) {
+	names := make([]string, len(coords))
+	n, err := l.lookupInto(ctx, coords, names)
+	return names[:n], err
+}
+
+// lookupInto stores the name of each coordinate in names, which must
+// be as long as coords. If ctx is done, it stops early and returns the
+// number of coordinates resolved and ctx.Err().
+func (l *Lookuper) lookupInto(ctx context.Context, coords [][2]float64, names []string) (n int, err error) {
+	if len(coords) == 0 {
+		return 0, nil
+	}
+	if l.degPixels == -1 {
+		for i, c := range coords {
+			if i%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return i, err
+				}
+			}
+			names[i] = l.LookupName(c[0], c[1])
+		}
+		return len(coords), nil
+	}
+	t := l.mustLoad()
+
+	var (
+		lastX, lastY = -1, -1
+		zone         string
+		zl           zoneLooker // or nil if last pixel had no tile
+		tk           tileKey
+	)
+	_, hasOverrides := l.overrides.Load().([]override)
+	for i, c := range coords {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return i, err
+			}
+		}
+		if hasOverrides {
+			if zone, ok := l.override(c[0], c[1]); ok {
+				names[i] = zone
+				continue
+			}
+		}
+		x, y := l.pixelOf(c[0], c[1])
+		if x != lastX || y != lastY {
+			lastX, lastY = x, y
+			// With overlapping tiles, a smaller tile inside the
+			// last one may win, so search again.
+			if zl == nil || t.overlap || pixelTileKey(tk.size(), x, y) != tk {
+				zl, tk = t.lookupLeaf(x, y)
+			}
+			zone = ""
+			if zl != nil {
+				zone, _ = zl.LookupZone(t.leaf, x, y, tk)
+			}
+		}
+		names[i] = zone
+		if zone == "" && l.fallback != nil {
+			names[i] = l.fallback(c[0], c[1])
+		}
+	}
+	return len(coords), nil
+}
+
+// Location returns the timezone at the given latitude and longitude,
+// for Lookupers whose names are timezone names. Like LookupZone, it
+// returns a nil Location and error if no timezone is found, and a
+// *ZoneLoadError if the zone was found but time.LoadLocation couldn't
+// load it.
+//
+// Loaded Locations are cached, so repeated calls don't reload the
+// zoneinfo database. Zones that fail to load aren't cached and are
+// tried again on the next call.
+func (l *Lookuper) Location(lat, long float64) (*time.Location, error) {
+	name := l.LookupName(lat, long)
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := l.loadLocation(name)
+	if err != nil {
+		return nil, &ZoneLoadError{Zone: name, Err: err}
+	}
+	return loc, nil
+}
+
+// loadLocation is like time.LoadLocation but caches successfully
+// loaded Locations.
+func (l *Lookuper) loadLocation(name string) (*time.Location, error) {
+	if loc, ok := l.locs.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := timeLoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	l.locs.Store(name, loc)
+	return loc, nil
+}
+
+// pixelOf returns the pixel containing the given latitude and
+// longitude. It uses the same mapping as the generator: x is
+// (long+180)*degPixels and y is (90-lat)*degPixels, after
+// NormalizeCoordinate, so 180 is the same as -180.
+func (l *Lookuper) pixelOf(lat, long float64) (x, y int) {
+	lat, long = NormalizeCoordinate(lat, long)
+	x = int((long + 180) * float64(l.degPixels))
+	y = int((90 - lat) * float64(l.degPixels))
+	// Clamp, for NaNs, the south pole, and rounding just below 180
+	// degrees.
 	if x < 0 {
 		x = 0
-	} else if x >= 360*degPixels {
-		x = 360*degPixels - 1
+	} else if x >= 360*l.degPixels {
+		x = 360*l.degPixels - 1
 	}
 	if y < 0 {
 		y = 0
-	} else if y >= 180*degPixels {
-		y = 180*degPixels - 1
+	} else if y >= 180*l.degPixels {
+		y = 180*l.degPixels - 1
 	}
-	return lookupPixel(x, y)
+	return x, y
 }
 
-func lookupPixel(x, y int) string {
-	if degPixels == -1 {
+func (l *Lookuper) lookupPixel(x, y int) string {
+	if l.degPixels == -1 {
 		return "tables not generated yet"
 	}
-	unpackOnce.Do(unpackTables)
+	t := l.mustLoad()
 
-	for level := 5; level >= 0; level-- {
-		shift := 3 + uint8(level)
-		xt := uint16(x >> shift)
-		yt := uint16(y >> shift)
-		tk := newTileKey(uint8(level), xt, yt)
-		zone, ok := zoomLevels[level].LookupZone(x, y, tk)
-		if ok {
-			return zone
-		}
+	if zl, tk := t.lookupLeaf(x, y); zl != nil {
+		zone, _ := zl.LookupZone(t.leaf, x, y, tk)
+		return zone
 	}
 	return ""
 }
 
-var unpackOnce sync.Once
+// pixelName is lookupPixel with LookupName's fallback at the pixel's
+// center, for searching the pixels around a coordinate.
+func (l *Lookuper) pixelName(x, y int) string {
+	zone := l.lookupPixel(x, y)
+	if zone == "" && l.fallback != nil {
+		deg := float64(l.degPixels)
+		return l.fallback(90-(float64(y)+0.5)/deg, (float64(x)+0.5)/deg-180)
+	}
+	return zone
+}
+
+// lookupLeaf returns the zoneLooker for the tile containing pixel
+// (x, y) and that tile's key. It returns a nil zoneLooker if no tile
+// contains the pixel.
+//
+// If tiles of several sizes contain the pixel, the smallest, most
+// precise one wins: tables with overlapping tiles are searched from
+// the smallest tiles up. The generator never emits overlapping tiles,
+// though, so for tables without any (such as the compiled-in ones),
+// the order doesn't matter and the largest tiles, which answer most
+// lookups, are searched first.
+func (t *unpackedTables) lookupLeaf(x, y int) (zoneLooker, tileKey) {
+	return t.searchLeaf(x, y, t.overlap)
+}
+
+// searchLeaf is lookupLeaf, searching the tables from the smallest
+// tiles up if smallestFirst or else from the largest down.
+func (t *unpackedTables) searchLeaf(x, y int, smallestFirst bool) (zoneLooker, tileKey) {
+	if smallestFirst {
+		for level := 0; level < len(t.levels); level++ {
+			tk := pixelTileKey(uint8(level), x, y)
+			if idx, ok := t.levels[level].index(tk); ok {
+				return t.leaf[idx], tk
+			}
+		}
+		return nil, 0
+	}
+	for level := len(t.levels) - 1; level >= 0; level-- {
+		tk := pixelTileKey(uint8(level), x, y)
+		if idx, ok := t.levels[level].index(tk); ok {
+			return t.leaf[idx], tk
+		}
+	}
+	return nil, 0
+}
 
-func unpackTables() {
-	for _, zl := range zoomLevels {
-		zr, err := gzip.NewReader(
-			base64.NewDecoder(base64.StdEncoding,
-				strings.NewReader(zl.gzipData)))
-		check(err)
-		slurp, err := ioutil.ReadAll(zr)
-		check(err)
-		if len(slurp)%6 != 0 {
-			panic("bogus encoded tileLooker length")
+// wrapLong wraps long into [-180, 180).
+func wrapLong(long float64) float64 {
+	if long < -180 || long >= 180 {
+		long = math.Mod(long+180, 360)
+		if long < 0 {
+			long += 360
 		}
-		zl.tiles = make([]tileLooker, len(slurp)/6)
-		for i := range zl.tiles {
-			idx := i * 6
-			zl.tiles[i] = tileLooker{
-				tileKey(binary.BigEndian.Uint32(slurp[idx : idx+4])),
-				binary.BigEndian.Uint16(slurp[idx+4 : idx+6]),
+		long -= 180
+	}
+	return long
+}
+
+// latLongToTileKey returns the key of the tile of the given size
+// containing the given latitude and longitude. Like pixelOf, it wraps
+// longitudes around the antimeridian and clamps latitudes to the
+// poles.
+func (l *Lookuper) latLongToTileKey(sizeShift uint8, lat, long float64) tileKey {
+	x, y := l.pixelOf(lat, long)
+	return pixelTileKey(sizeShift, x, y)
+}
+
+// pixelTileKey returns the key of the tile of the given size
+// containing pixel (x, y).
+func pixelTileKey(size uint8, x, y int) tileKey {
+	shift := 3 + size
+	return newTileKey(size, uint16(x>>shift), uint16(y>>shift))
+}
+
+// unpackedTables are a Lookuper's tables, unpacked.
+type unpackedTables struct {
+	levels  []zoomLevel // by size shift
+	leaf    []zoneLooker
+	overlap bool // whether tiles of different sizes overlap
+}
+
+// load returns l's unpacked tables, unpacking them if they haven't
+// been already or were released. Once unpacking fails, it always
+// returns that error.
+func (l *Lookuper) load() (*unpackedTables, error) {
+	if t, _ := l.tables.Load().(*unpackedTables); t != nil {
+		return t, nil
+	}
+	l.unpackMu.Lock()
+	defer l.unpackMu.Unlock()
+	if t, _ := l.tables.Load().(*unpackedTables); t != nil {
+		return t, nil
+	}
+	if l.unpackErr != nil {
+		return nil, l.unpackErr
+	}
+	t, err := l.unpack()
+	if err != nil {
+		l.unpackErr = err
+		l.degraded = t
+		return nil, err
+	}
+	l.tables.Store(t)
+	return t, nil
+}
+
+// mustLoad is like load, but rather than failing if the tables are
+// corrupt, it logs a warning, once, and returns what of them could be
+// unpacked: the corrupt zoom levels are left empty, so coordinates
+// they cover resolve to no region, rather than a bad build crashing
+// the program on its first lookup. Programs that would rather catch
+// that at startup can call Validate. It panics if l is closed.
+func (l *Lookuper) mustLoad() *unpackedTables {
+	t, err := l.load()
+	if err == nil {
+		return t
+	}
+	if err == errClosed {
+		panic(err)
+	}
+	l.corruptOnce.Do(func() {
+		log.Printf("%v; ignoring the corrupt tables", err)
+	})
+	l.unpackMu.Lock()
+	defer l.unpackMu.Unlock()
+	return l.degraded
+}
+
+// unpack decompresses and checks l's tables. If they're corrupt, it
+// returns the first problem found along with the tables without the
+// corrupt parts: corrupt zoom levels are left empty, as are all of
+// them if the leaves are corrupt.
+func (l *Lookuper) unpack() (*unpackedTables, error) {
+	buf, _ := unpackBufs.Get().(*bytes.Buffer)
+	if buf == nil {
+		buf = new(bytes.Buffer)
+	}
+	defer unpackBufs.Put(buf)
+
+	t := &unpackedTables{levels: make([]zoomLevel, len(l.levelData))}
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for i := range t.levels {
+		if l.levelData[i] == nil {
+			continue
+		}
+		zl := &t.levels[i]
+		var err error
+		if zl.keys, zl.idxs, err = unpackLevel(l.levelData[i](), buf); err != nil {
+			fail(fmt.Errorf("latlong: zoom level %d: %v", i, err))
+			*zl = zoomLevel{}
+			continue
+		}
+		zl.indexBytes = buf.Len()
+	}
+
+	leaf, err := readLeaves(l.leafData(), l.numLeaves)
+	if err != nil {
+		return new(unpackedTables), err
+	}
+
+	// Check all indexes up front, so bad tables can't cause
+	// out-of-range panics during lookups.
+	inRange := func(idx uint16) bool { return int(idx) < len(leaf) }
+	for i := range t.levels {
+		zl := &t.levels[i]
+		for j, idx := range zl.idxs {
+			if !inRange(idx) {
+				fail(fmt.Errorf("latlong: zoom level %d: tile %x has leaf index %d out of range", i, zl.keys[j], idx))
+				*zl = zoomLevel{}
+				break
 			}
 		}
 	}
+	// Bitmaps and pixmaps must only refer to zones, not to each
+	// other (or themselves), which lookups would recurse through.
+	isZone := func(idx uint16) bool {
+		if !inRange(idx) {
+			return false
+		}
+		_, ok := leaf[idx].(staticZone)
+		return ok
+	}
+	for i, z := range leaf {
+		switch z := z.(type) {
+		case oneBitTile:
+			if !isZone(z.idx[0]) || !isZone(z.idx[1]) {
+				return new(unpackedTables), fmt.Errorf("latlong: leaf %d: index isn't of a zone", i)
+			}
+		case pixmap:
+			for j := 0; j < len(z); j += 2 {
+				idx := uint16(z[j])<<8 + uint16(z[j+1])
+				if idx != oceanIndex && !isZone(idx) {
+					return new(unpackedTables), fmt.Errorf("latlong: leaf %d: index isn't of a zone", i)
+				}
+			}
+		}
+	}
+	t.leaf = leaf
+	t.overlap = t.tilesOverlap(l.degPixels)
+	for _, b := range l.shards {
+		st, err := l.unpackShard(b)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		m, err := mergeTables(t, st, l.degPixels)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		t = m
+	}
+	return t, firstErr
+}
+
+// unpackLevel decompresses and decodes the zoom level tile index read
+// from r, in either format (see compact.go), using buf as scratch
+// space.
+func unpackLevel(r io.Reader, buf *bytes.Buffer) (keys []tileKey, idxs []uint16, err error) {
+	zr, err := getGzipReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf.Reset()
+	_, err = buf.ReadFrom(zr)
+	gzipReaders.Put(zr)
+	if err != nil {
+		return nil, nil, err
+	}
+	slurp := buf.Bytes()
+	if isLevelV2(slurp) {
+		return decodeLevelV2(slurp)
+	}
+	if len(slurp)%6 != 0 {
+		return nil, nil, errors.New("bogus encoded tile index length")
+	}
+	n := len(slurp) / 6
+	keys = make([]tileKey, n)
+	idxs = make([]uint16, n)
+	for i := range keys {
+		rec := slurp[i*6:]
+		keys[i] = tileKey(binary.BigEndian.Uint32(rec[:4]))
+		idxs[i] = binary.BigEndian.Uint16(rec[4:6])
+	}
+	return keys, idxs, nil
+}
+
+// tilesOverlap reports whether any pixel is in tiles of more than one
+// size. It marks the 8 pixel cells each tile covers, largest tiles
+// first, and stops at the first cell marked twice.
+func (t *unpackedTables) tilesOverlap(degPixels int) bool {
+	w, h := 360*degPixels/8, 180*degPixels/8
+	marked := make([]uint64, (w*h+63)/64)
+	for level := len(t.levels) - 1; level >= 0; level-- {
+		n := 1 << uint(level) // cells per tile side
+		for _, tk := range t.levels[level].keys {
+			x0, y0 := int(tk.x())*n, int(tk.y())*n
+			for y := y0; y < y0+n && y < h; y++ {
+				for x := x0; x < x0+n && x < w; x++ {
+					i := y*w + x
+					if marked[i/64]&(1<<uint(i%64)) != 0 {
+						return true
+					}
+					marked[i/64] |= 1 << uint(i%64)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Unpacking tables needs a gzip.Reader per compressed blob (about 40
+// KB each) and a buffer for each zoom level's decompressed tile index.
+// They're pooled so programs creating many Lookupers don't make that
+// much garbage each time. The pools cut BenchmarkUnpack's allocation
+// from about 2.0 MB to 1.1 MB.
+var (
+	gzipReaders sync.Pool // of *gzip.Reader
+	unpackBufs  sync.Pool // of *bytes.Buffer
+)
+
+// getGzipReader returns a gzip.Reader reading from r, from gzipReaders
+// if it has one. The caller should put it back when done with it.
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	if zr, ok := gzipReaders.Get().(*gzip.Reader); ok {
+		if err := zr.Reset(r); err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+	return gzip.NewReader(r)
+}
 
-	zr, err := gzip.NewReader(
-		base64.NewDecoder(base64.StdEncoding,
-			strings.NewReader(uniqueLeavesPacked)))
-	check(err)
+// readLeaves reads the packed leaves from the gzip data in r. If n is
+// non-zero, it's the expected number of leaves.
+func readLeaves(r io.Reader, n int) ([]zoneLooker, error) {
+	zr, err := getGzipReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("latlong: leaves: %v", err)
+	}
+	defer gzipReaders.Put(zr)
 	br := bufio.NewReader(zr)
+	leaf := make([]zoneLooker, 0, n)
 	var buf [128]byte
-	for i := range leaf {
+	for {
 		t, err := br.ReadByte()
-		check(err)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("latlong: leaves: %v", err)
+		}
 		switch t {
 		default:
-			panic("unknown leaf type: " + fmt.Sprintf("%q", t))
+			return nil, fmt.Errorf("latlong: unknown leaf type: %q", t)
 		case 'S': // static zone
 			v, err := br.ReadBytes(0) // null-terminated
-			check(err)
-			leaf[i] = staticZone(string(v[:len(v)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("latlong: leaves: %v", err)
+			}
+			leaf = append(leaf, staticZone(string(v[:len(v)-1])))
 		case '2': // two-timezone 1bpp bitmap (pass.bitmapPixmapBytes)
-			_, err := io.ReadFull(br, buf[:12])
-			check(err)
+			if _, err := io.ReadFull(br, buf[:12]); err != nil {
+				return nil, fmt.Errorf("latlong: leaves: %v", err)
+			}
 			t := oneBitTile{
 				idx: [2]uint16{
 					binary.BigEndian.Uint16(buf[0:2]),
@@ -138,14 +1480,18 @@ func unpackTables() {
 					}
 				}
 			}
-			leaf[i] = t
+			leaf = append(leaf, t)
 		case 'P': // multi-timezone 4bpp bitmap
-			_, err := io.ReadFull(br, buf[:128])
-			check(err)
-			leaf[i] = pixmap(buf[:128])
+			if _, err := io.ReadFull(br, buf[:128]); err != nil {
+				return nil, fmt.Errorf("latlong: leaves: %v", err)
+			}
+			leaf = append(leaf, pixmap(buf[:128]))
 		}
 	}
-
+	if n != 0 && len(leaf) != n {
+		return nil, fmt.Errorf("latlong: got %d leaves; want %d", len(leaf), n)
+	}
+	return leaf, nil
 }
 
 func check(err error) {
@@ -154,13 +1500,15 @@ func check(err error) {
 	}
 }
 
+// A zoneLooker resolves a pixel within a tile. Its leaf indexes, if
+// any, refer to leaf, the Lookuper's full list of leaves.
 type zoneLooker interface {
-	LookupZone(x, y int, tk tileKey) (zone string, ok bool)
+	LookupZone(leaf []zoneLooker, x, y int, tk tileKey) (zone string, ok bool)
 }
 
 type staticZone string
 
-func (z staticZone) LookupZone(x, y int, tk tileKey) (zone string, ok bool) {
+func (z staticZone) LookupZone(leaf []zoneLooker, x, y int, tk tileKey) (zone string, ok bool) {
 	return string(z), true
 }
 
@@ -171,6 +1519,11 @@ func (z staticZone) LookupZone(x, y int, tk tileKey) (zone string, ok bool) {
 // bit 28: unused
 // bit 31,30,29: tile size
 // ssss
+//
+// Positions are in tiles, not pixels, so even at the smallest size
+// (8 pixels) 14 bits covers a world image up to 131072 pixels wide: a
+// generation scale of up to about 364 pixels per degree, well past
+// the default of 32.
 type tileKey uint32
 
 // size is 0, 1, 2, or 3
@@ -192,28 +1545,28 @@ func (v tileKey) y() uint16 {
 	return uint16((v >> 14) & (1<<14 - 1))
 }
 
-type tileLooker struct {
-	tile tileKey
-	idx  uint16 // index into leaf
-}
-
+// A zoomLevel is the index of one level's tiles. The keys and their
+// leaf indexes are kept in parallel slices, rather than a slice of
+// structs, to avoid two bytes of padding per tile and to keep the
+// binary search over keys compact.
 type zoomLevel struct {
-	gzipData string       // compressed [tilekey][uint16_idx], repeated
-	tiles    []tileLooker // lazily populated
+	gzipData string    // compiled-in tables only: base64 of the compressed tile index; see compact.go
+	keys     []tileKey // sorted; populated by Lookuper.unpack
+	idxs     []uint16  // index into leaf of the tile keys[i]
+
+	indexBytes int // decompressed size of the tile index keys and idxs were decoded from, for Stats
 }
 
-func (zl *zoomLevel) LookupZone(x, y int, tk tileKey) (zone string, ok bool) {
-	pos := sort.Search(len(zl.tiles), func(i int) bool {
-		return zl.tiles[i].tile >= tk
+// index returns the leaf index for the tile tk, if present at this
+// zoom level.
+func (zl *zoomLevel) index(tk tileKey) (idx uint16, ok bool) {
+	pos := sort.Search(len(zl.keys), func(i int) bool {
+		return zl.keys[i] >= tk
 	})
-	if pos >= len(zl.tiles) {
-		return
-	}
-	tl := zl.tiles[pos]
-	if tl.tile != tk {
+	if pos >= len(zl.keys) || zl.keys[pos] != tk {
 		return
 	}
-	return leaf[tl.idx].LookupZone(x, y, tk)
+	return zl.idxs[pos], true
 }
 
 // A oneBitTile represents a fully opaque 8x8 grid tile that only has
@@ -224,19 +1577,19 @@ type oneBitTile struct {
 	rows [8]uint8  // [y], then 1<<x.
 }
 
-func (t oneBitTile) LookupZone(x, y int, tk tileKey) (zone string, ok bool) {
+func (t oneBitTile) LookupZone(leaf []zoneLooker, x, y int, tk tileKey) (zone string, ok bool) {
 	idx := t.idx[0]
 	if t.rows[y&7]&(1<<(uint(x&7))) != 0 {
 		idx = t.idx[1]
 	}
-	return leaf[idx].LookupZone(x, y, tk)
+	return leaf[idx].LookupZone(leaf, x, y, tk)
 }
 
 // pixmap packs 8x8 row-order big ending uint16 indexes into
 // zoneLookers. Each string is 128 bytes long.
 type pixmap string
 
-func (p pixmap) LookupZone(x, y int, tk tileKey) (zone string, ok bool) {
+func (p pixmap) LookupZone(leaf []zoneLooker, x, y int, tk tileKey) (zone string, ok bool) {
 	xx := x & 7
 	yy := y & 7
 	i := 2 * (yy*8 + xx)
@@ -244,7 +1597,7 @@ func (p pixmap) LookupZone(x, y int, tk tileKey) (zone string, ok bool) {
 	if idx == oceanIndex {
 		return "", true
 	}
-	return leaf[idx].LookupZone(x, y, tk)
+	return leaf[idx].LookupZone(leaf, x, y, tk)
 }
 
 // The oceanIndex is a magic index into zoneLooker which says that
@@ -16,7 +16,25 @@ limitations under the License.
 
 package latlong
 
-import "testing"
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestLookupLatLong(t *testing.T) {
 	cases := []struct {
@@ -24,11 +42,848 @@ func TestLookupLatLong(t *testing.T) {
 		want      string
 	}{
 		{37.7833, -122.4167, "America/Los_Angeles"},
+		{40.7128, -74.0060, "America/New_York"},
+
+		// Pacific Ocean:
+		{0, -140, ""},
 	}
 	for _, tt := range cases {
 		if got := LookupZoneName(tt.lat, tt.long); got != tt.want {
 			t.Errorf("LookupZoneName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
 		}
+		// Repeated lookups must agree.
+		for i := 0; i < 3; i++ {
+			if got := LookupZoneName(tt.lat, tt.long); got != tt.want {
+				t.Errorf("LookupZoneName(%v, %v) call %d = %q; want %q", tt.lat, tt.long, i, got, tt.want)
+			}
+		}
+	}
+}
+
+// Tests that coordinates on and beyond the poles and antimeridian
+// don't panic and wrap where they should.
+// TestLookupKnownCities pins the zones of well-known places, to catch
+// regressions when the tables are regenerated.
+func TestLookupKnownCities(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{51.5074, -0.1278, "Europe/London"},                    // London
+		{48.8566, 2.3522, "Europe/Paris"},                      // Paris
+		{52.5200, 13.4050, "Europe/Berlin"},                    // Berlin
+		{41.9028, 12.4964, "Europe/Rome"},                      // Rome
+		{40.4168, -3.7038, "Europe/Madrid"},                    // Madrid
+		{55.7558, 37.6173, "Europe/Moscow"},                    // Moscow
+		{59.3293, 18.0686, "Europe/Stockholm"},                 // Stockholm
+		{38.7223, -9.1393, "Europe/Lisbon"},                    // Lisbon
+		{37.9838, 23.7275, "Europe/Athens"},                    // Athens
+		{41.0082, 28.9784, "Europe/Istanbul"},                  // Istanbul
+		{50.4501, 30.5234, "Europe/Kiev"},                      // Kyiv
+		{64.1466, -21.9426, "Atlantic/Reykjavik"},              // Reykjavik
+		{35.6762, 139.6503, "Asia/Tokyo"},                      // Tokyo
+		{39.9042, 116.4074, "Asia/Shanghai"},                   // Beijing
+		{31.2304, 121.4737, "Asia/Shanghai"},                   // Shanghai
+		{22.3193, 114.1694, "Asia/Hong_Kong"},                  // Hong Kong
+		{37.5665, 126.9780, "Asia/Seoul"},                      // Seoul
+		{1.3521, 103.8198, "Asia/Singapore"},                   // Singapore
+		{13.7563, 100.5018, "Asia/Bangkok"},                    // Bangkok
+		{28.6139, 77.2090, "Asia/Kolkata"},                     // Delhi
+		{19.0760, 72.8777, "Asia/Kolkata"},                     // Mumbai
+		{27.7172, 85.3240, "Asia/Kathmandu"},                   // Kathmandu
+		{25.2048, 55.2708, "Asia/Dubai"},                       // Dubai
+		{35.6892, 51.3890, "Asia/Tehran"},                      // Tehran
+		{31.7683, 35.2137, "Asia/Jerusalem"},                   // Jerusalem
+		{-6.2088, 106.8456, "Asia/Jakarta"},                    // Jakarta
+		{14.5995, 120.9842, "Asia/Manila"},                     // Manila
+		{43.2220, 76.8512, "Asia/Almaty"},                      // Almaty
+		{30.0444, 31.2357, "Africa/Cairo"},                     // Cairo
+		{6.5244, 3.3792, "Africa/Lagos"},                       // Lagos
+		{-1.2921, 36.8219, "Africa/Nairobi"},                   // Nairobi
+		{-33.9249, 18.4241, "Africa/Johannesburg"},             // Cape Town
+		{-26.2041, 28.0473, "Africa/Johannesburg"},             // Johannesburg
+		{33.5731, -7.5898, "Africa/Casablanca"},                // Casablanca
+		{9.0320, 38.7469, "Africa/Addis_Ababa"},                // Addis Ababa
+		{-33.8688, 151.2093, "Australia/Sydney"},               // Sydney
+		{-37.8136, 144.9631, "Australia/Melbourne"},            // Melbourne
+		{-31.9505, 115.8605, "Australia/Perth"},                // Perth
+		{-12.4634, 130.8456, "Australia/Darwin"},               // Darwin
+		{-27.4698, 153.0251, "Australia/Brisbane"},             // Brisbane
+		{-36.8485, 174.7633, "Pacific/Auckland"},               // Auckland
+		{21.3069, -157.8583, "Pacific/Honolulu"},               // Honolulu
+		{61.2181, -149.9003, "America/Anchorage"},              // Anchorage
+		{41.8781, -87.6298, "America/Chicago"},                 // Chicago
+		{39.7392, -104.9903, "America/Denver"},                 // Denver
+		{33.4484, -112.0740, "America/Phoenix"},                // Phoenix
+		{43.6532, -79.3832, "America/Toronto"},                 // Toronto
+		{49.2827, -123.1207, "America/Vancouver"},              // Vancouver
+		{19.4326, -99.1332, "America/Mexico_City"},             // Mexico City
+		{-23.5505, -46.6333, "America/Sao_Paulo"},              // São Paulo
+		{-34.6037, -58.3816, "America/Argentina/Buenos_Aires"}, // Buenos Aires
+		{-12.0464, -77.0428, "America/Lima"},                   // Lima
+		{4.7110, -74.0721, "America/Bogota"},                   // Bogota
+		{-33.4489, -70.6693, "America/Santiago"},               // Santiago
+		{23.1136, -82.3666, "America/Havana"},                  // Havana
+
+		// Near borders:
+		{31.7619, -106.4850, "America/Denver"},      // El Paso
+		{32.5149, -117.0382, "America/Tijuana"},     // Tijuana
+		{32.7157, -117.1611, "America/Los_Angeles"}, // San Diego
+		{46.2044, 6.1432, "Europe/Zurich"},          // Geneva
+		{48.5734, 7.7521, "Europe/Paris"},           // Strasbourg
+		{42.7325, -84.5555, "America/Detroit"},      // Lansing
+		{47.5596, 7.5886, "Europe/Zurich"},          // Basel
+		{41.609, -101.4219, "America/Denver"},       // Nebraska panhandle, west of the Mountain/Central line
+		{41.609, -101.3594, "America/Chicago"},      // Nebraska panhandle, east of the Mountain/Central line
+
+		// Open ocean:
+		{-40, -20, ""}, // South Atlantic
+		{-20, 75, ""},  // Indian Ocean
+	}
+	for _, tt := range tests {
+		if got := LookupZoneName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupZoneName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+func TestLookupAntarctica(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{-77.846, 166.676, "Antarctica/McMurdo"}, // McMurdo Station
+		{-90, 0, "Antarctica/McMurdo"},           // South Pole
+		{-89.5, -100, "Antarctica/McMurdo"},
+		{-78, 110, "Antarctica/Vostok"},
+		{-64.77, -64.05, "Antarctica/Palmer"},
+		{-75, -10, "Antarctica/Troll"},
+		{-54.62, 158.86, "Antarctica/Macquarie"}, // in the tables
+		{-59.9, 100, ""},                         // north of 60°S
+		{-62, -120, ""},                          // Southern Ocean, off Marie Byrd Land
+		{-65, 0, ""},                             // Southern Ocean, off Queen Maud Land
+		{-72, 168, "Antarctica/McMurdo"},         // Victoria Land
+		{-66.7, 140, "Antarctica/DumontDUrville"},
+		{-67.6, 62.9, "Antarctica/Mawson"},
+		{-90, 187, "Antarctica/McMurdo"}, // longitude wraps
+	}
+	for _, tt := range tests {
+		if got := LookupZoneName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupZoneName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	names := LookupZoneNames([][2]float64{{-90, 0}, {-90, 0}, {-78, 110}})
+	if want := []string{"Antarctica/McMurdo", "Antarctica/McMurdo", "Antarctica/Vostok"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("LookupZoneNames = %q; want %q", names, want)
+	}
+	for _, name := range antarcticZoneNames() {
+		if _, err := time.LoadLocation(name); err != nil {
+			t.Errorf("LoadLocation(%q): %v", name, err)
+		}
+	}
+}
+
+func TestLookupZoneNameStrict(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      string
+		err       error
+	}{
+		{40.7128, -74.0060, "America/New_York", nil},
+		{0, -140, "", nil}, // ocean
+		{90, 180, "", nil},
+		{-90, -180, "Antarctica/McMurdo", nil},
+		{90.1, 0, "", ErrLatitudeRange},
+		{-91, 0, "", ErrLatitudeRange},
+		{math.NaN(), 0, "", ErrLatitudeRange},
+		{0, 180.5, "", ErrLongitudeRange},
+		{0, -200, "", ErrLongitudeRange},
+		{0, math.Inf(1), "", ErrLongitudeRange},
+		{0, math.NaN(), "", ErrLongitudeRange},
+	}
+	for _, tt := range tests {
+		got, err := LookupZoneNameStrict(tt.lat, tt.long)
+		if got != tt.want || err != tt.err {
+			t.Errorf("LookupZoneNameStrict(%v, %v) = %q, %v; want %q, %v", tt.lat, tt.long, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestNormalizeCoordinate(t *testing.T) {
+	tests := []struct {
+		lat, long         float64
+		wantLat, wantLong float64
+	}{
+		{40, -74, 40, -74},
+		{40, 286, 40, -74},
+		{51.5, 350, 51.5, -10},
+		{0, 190, 0, -170},
+		{0, 180, 0, -180},
+		{0, 360, 0, 0},
+		{0, -540, 0, -180},
+		{95, 0, 90, 0},
+		{-100, 720.5, -90, 0.5},
+	}
+	for _, tt := range tests {
+		if lat, long := NormalizeCoordinate(tt.lat, tt.long); lat != tt.wantLat || long != tt.wantLong {
+			t.Errorf("NormalizeCoordinate(%v, %v) = (%v, %v); want (%v, %v)", tt.lat, tt.long, lat, long, tt.wantLat, tt.wantLong)
+		}
+	}
+	if lat, long := NormalizeCoordinate(math.NaN(), math.NaN()); !math.IsNaN(lat) || !math.IsNaN(long) {
+		t.Errorf("NormalizeCoordinate(NaN, NaN) = (%v, %v); want NaNs", lat, long)
+	}
+
+	// Lookups of 0-360 longitudes are the same as of their
+	// -180-180 equivalents.
+	for _, c := range [][2]float64{
+		{40.7128, 360 - 74.0060}, // New York
+		{51.5, 350},              // Atlantic, west of Ireland
+		{-17.7, 190},             // Fiji's eastern islands
+		{35.6762, 139.6503},      // Tokyo
+	} {
+		lat, long := NormalizeCoordinate(c[0], c[1])
+		if got, want := LookupZoneName(c[0], c[1]), LookupZoneName(lat, long); got != want {
+			t.Errorf("LookupZoneName(%v, %v) = %q; want %q, as for (%v, %v)", c[0], c[1], got, want, lat, long)
+		}
+	}
+	if got := LookupZoneName(40.7128, 360-74.0060); got != "America/New_York" {
+		t.Errorf("LookupZoneName(New York, long 0-360) = %q; want America/New_York", got)
+	}
+}
+
+func TestLookupEdges(t *testing.T) {
+	cases := []struct {
+		lat, long float64
+		want      string
+	}{
+		{90, 180, ""},
+		{-90, -180, "Antarctica/McMurdo"},
+		{0, 180, "Pacific/Enderbury"},
+		{0, -180, "Pacific/Enderbury"},
+		{91, 181, ""},
+		{-91, -181, "Antarctica/McMurdo"},
+
+		// Fiji straddles the antimeridian:
+		{-16.5, 180, LookupZoneName(-16.5, -180)},
+		{-16.5, 179.9, "Pacific/Fiji"},
+		{-16.5, -180.1, "Pacific/Fiji"},
+		{-16.5, 539.9, "Pacific/Fiji"},
+	}
+	for _, tt := range cases {
+		if got := LookupZoneName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupZoneName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.MaxFloat64, -math.MaxFloat64} {
+		LookupZoneName(f, 0)
+		LookupZoneName(0, f)
+		LookupZoneName(f, f)
+	}
+}
+
+func TestLookupZone(t *testing.T) {
+	loc, err := LookupZone(40.7128, -74.0060)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc == nil {
+		t.Fatal("LookupZone returned nil Location for New York")
+	}
+	if got, want := loc.String(), "America/New_York"; got != want {
+		t.Errorf("LookupZone = %q; want %q", got, want)
+	}
+
+	loc, err = LookupZone(0, -140)
+	if loc != nil || err != nil {
+		t.Errorf("LookupZone in ocean = %v, %v; want nil, nil", loc, err)
+	}
+}
+
+func TestLookupZoneMissingZoneinfo(t *testing.T) {
+	// Simulate an old zoneinfo database without Chicago, making sure
+	// it isn't already cached.
+	l := defaultLookuper()
+	defer func(load func(string) (*time.Location, error)) {
+		timeLoadLocation = load
+		l.locs.Delete("America/Chicago")
+	}(timeLoadLocation)
+	l.locs.Delete("America/Chicago")
+	timeLoadLocation = func(name string) (*time.Location, error) {
+		if name == "America/Chicago" {
+			return nil, errors.New("unknown time zone " + name)
+		}
+		return time.LoadLocation(name)
+	}
+
+	const lat, long = 41.8781, -87.6298 // Chicago
+	if got := LookupZoneName(lat, long); got != "America/Chicago" {
+		t.Fatalf("LookupZoneName = %q; want America/Chicago", got)
+	}
+	loc, err := LookupZone(lat, long)
+	zerr, ok := err.(*ZoneLoadError)
+	if loc != nil || !ok || zerr.Zone != "America/Chicago" {
+		t.Fatalf("LookupZone = %v, %v; want nil, a ZoneLoadError for America/Chicago", loc, err)
+	}
+	if loc, err := newCompiledLookuper().Location(lat, long); loc != nil || err == nil {
+		t.Errorf("Lookuper.Location = %v, %v; want nil, a ZoneLoadError", loc, err)
+	}
+	if _, ok := LookupOffset(lat, long, time.Now()); ok {
+		t.Error("LookupOffset succeeded")
+	}
+	if loc, err := LookupZone(40.7128, -74.0060); loc == nil || err != nil {
+		t.Errorf("LookupZone(New York) = %v, %v", loc, err)
+	}
+}
+
+func TestLookupZoneNameConfidence(t *testing.T) {
+	cases := []struct {
+		lat, long float64
+		zone      string
+		deg       float64
+	}{
+		// Nebraska panhandle border tile:
+		{41.609, -101.4219, "America/Denver", 0.25},
+		// Middle of Brazil:
+		{-10, -55, "America/Cuiaba", 4},
+		// Pacific, with no tile at all:
+		{0, -140, "", 0},
+		// Antarctica, from the fallback rather than a tile:
+		{-80, 0, "Antarctica/Troll", 0},
+	}
+	for _, tt := range cases {
+		zone, deg := LookupZoneNameConfidence(tt.lat, tt.long)
+		if zone != tt.zone || deg != tt.deg {
+			t.Errorf("LookupZoneNameConfidence(%v, %v) = %q, %v; want %q, %v", tt.lat, tt.long, zone, deg, tt.zone, tt.deg)
+		}
+	}
+}
+
+func TestLookupZoneNames(t *testing.T) {
+	coords := [][2]float64{
+		{37.7833, -122.4167},
+		{40.7128, -74.0060},
+		{0, -140},
+		{37.7833, -122.4167},
+		{40.7129, -74.0061}, // same pixel as above
+		{51.5074, -0.1278},
+	}
+	got := LookupZoneNames(coords)
+	if len(got) != len(coords) {
+		t.Fatalf("len = %d; want %d", len(got), len(coords))
+	}
+	for i, c := range coords {
+		if want := LookupZoneName(c[0], c[1]); got[i] != want {
+			t.Errorf("LookupZoneNames[%d] (%v) = %q; want %q", i, c, got[i], want)
+		}
+	}
+	if got := LookupZoneNames(nil); len(got) != 0 {
+		t.Errorf("LookupZoneNames(nil) = %q; want empty", got)
+	}
+}
+
+func TestLookupZoneNamesFunc(t *testing.T) {
+	coords := trackCoords(1000)
+	coords = append(coords, [2]float64{0, -140}, [2]float64{-80, 0}) // ocean, Antarctica
+	want := LookupZoneNames(coords)
+	next := 0
+	LookupZoneNamesFunc(coords, func(i int, zone string) {
+		if i != next {
+			t.Fatalf("called with index %d; want %d", i, next)
+		}
+		if zone != want[i] {
+			t.Errorf("coordinate %d (%v) = %q; want %q", i, coords[i], zone, want[i])
+		}
+		next++
+	})
+	if next != len(coords) {
+		t.Errorf("called %d times; want %d", next, len(coords))
+	}
+	LookupZoneNamesFunc(nil, func(i int, zone string) {
+		t.Errorf("called for empty coords")
+	})
+}
+
+func TestLookupZoneNamesContext(t *testing.T) {
+	coords := trackCoords(10 * ctxCheckInterval)
+	want := LookupZoneNames(coords)
+
+	names, err := LookupZoneNamesContext(context.Background(), coords)
+	if err != nil || len(names) != len(coords) {
+		t.Fatalf("got %d names, %v; want %d, nil", len(names), err, len(coords))
+	}
+	for i := range names {
+		if names[i] != want[i] {
+			t.Fatalf("names[%d] = %q; want %q", i, names[i], want[i])
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	names, err = LookupZoneNamesContext(ctx, coords)
+	if err != context.Canceled || len(names) != 0 {
+		t.Errorf("canceled: got %d names, %v; want 0, %v", len(names), err, context.Canceled)
+	}
+
+	// Cancel partway through.
+	names, err = LookupZoneNamesContext(&countdownContext{Context: context.Background(), n: 3}, coords)
+	if err != context.Canceled {
+		t.Fatalf("err = %v; want %v", err, context.Canceled)
+	}
+	if len(names) != 3*ctxCheckInterval {
+		t.Errorf("got %d names; want %d", len(names), 3*ctxCheckInterval)
+	}
+	for i := range names {
+		if names[i] != want[i] {
+			t.Fatalf("names[%d] = %q; want %q", i, names[i], want[i])
+		}
+	}
+}
+
+// A countdownContext is canceled once its Err method has been called
+// n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestNearestZoneName(t *testing.T) {
+	cases := []struct {
+		lat, long float64
+		want      string
+	}{
+		// On land, same as LookupZoneName:
+		{40.7128, -74.0060, "America/New_York"},
+
+		// Just past the edge of the Oregon coast tiles:
+		{45, -128.25, "America/Los_Angeles"},
+
+		// Mid-Pacific, far from anything:
+		{0, -140, ""},
+
+		// Just east of the antimeridian, where the nearest zone
+		// is across it, off New Zealand's subantarctic islands:
+		{-55, -179.9, "Pacific/Auckland"},
+
+		// Antarctica, from the fallback, and just off its coast:
+		{-80, 0, "Antarctica/Troll"},
+		{-69.3, 5, "Antarctica/Troll"},
+	}
+	for _, tt := range cases {
+		if got := NearestZoneName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("NearestZoneName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	for _, c := range [][2]float64{{45, -128.25}, {-55, -179.9}, {-69.3, 5}} {
+		if LookupZoneName(c[0], c[1]) != "" {
+			t.Errorf("test point %v unexpectedly has a zone; pick another", c)
+		}
+	}
+}
+
+func TestLookupZoneCandidates(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      []string
+	}{
+		// Lake Constance, where Germany, Austria, and Switzerland meet.
+		{47.6, 9.6, []string{"Europe/Berlin", "Europe/Vienna", "Europe/Zurich"}},
+		{47.5, 9.7, []string{"Europe/Vienna", "Europe/Berlin", "Europe/Zurich", "Europe/Vaduz"}},
+		{41.609, -101.4219, []string{"America/Denver", "America/Chicago"}},
+		{0, 180, []string{"Pacific/Enderbury", "Pacific/Tarawa"}}, // wraps
+		{0, -30, []string{}},
+	}
+	for _, tt := range tests {
+		got := LookupZoneCandidates(tt.lat, tt.long)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LookupZoneCandidates(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+func TestDominantZone(t *testing.T) {
+	tests := []struct {
+		lat, long, radiusKm float64
+		want                string
+		minFrac, maxFrac    float64
+	}{
+		// Geneva is Swiss, but mostly surrounded by France.
+		{46.2, 6.15, 20, "Europe/Paris", 0.6, 0.95},
+		// Offshore of Nice, where the circle is part sea.
+		{43.4, 7.3, 30, "Europe/Paris", 0.1, 0.5},
+		// Deep inland, the circle is all one zone.
+		{39, -98, 50, "America/Chicago", 1, 1},
+		// A tiny circle, within one pixel.
+		{40.7128, -74.0060, 0.01, "America/New_York", 1, 1},
+		// The Antarctic fallback applies.
+		{-89, 0, 100, "Antarctica/McMurdo", 1, 1},
+		// Open ocean.
+		{0, -140, 50, "", 0, 0},
+	}
+	for _, tt := range tests {
+		zone, frac := DominantZone(tt.lat, tt.long, tt.radiusKm)
+		if zone != tt.want || frac < tt.minFrac || frac > tt.maxFrac {
+			t.Errorf("DominantZone(%v, %v, %v) = %q, %v; want %q, in [%v, %v]",
+				tt.lat, tt.long, tt.radiusKm, zone, frac, tt.want, tt.minFrac, tt.maxFrac)
+		}
+	}
+	if zone, frac := DominantZone(40, -100, 0); zone != "" || frac != 0 {
+		t.Errorf("DominantZone with radius 0 = %q, %v; want nothing", zone, frac)
+	}
+	// Large circles are sampled rather than scanned.
+	zone, frac := DominantZone(40, -100, 3000)
+	if zone != "America/Chicago" || frac < 0.05 || frac > 0.3 {
+		t.Errorf("DominantZone(central US, 3000 km) = %q, %v; want America/Chicago, about 0.12", zone, frac)
+	}
+}
+
+func TestNearestZoneNameWithin(t *testing.T) {
+	tests := []struct {
+		lat, long, maxKm float64
+		want             string
+	}{
+		{40.7128, -74.0060, 10, "America/New_York"}, // on land
+		{45, -128.25, 300, "America/Los_Angeles"},   // off Oregon
+		{0, -30, 300, ""},                           // mid-Atlantic
+
+		// In the Arctic Ocean north of Franz Josef Land, the
+		// nearest land zone is Greenland's, about 423 km away
+		// across the pole. Russia's islands are nearer in degrees,
+		// which is what NearestZoneName measures.
+		{86, 60, 1000, "America/Godthab"},
+		{86, 60, 400, ""},
+	}
+	for _, tt := range tests {
+		got, ok := NearestZoneNameWithin(tt.lat, tt.long, tt.maxKm)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("NearestZoneNameWithin(%v, %v, %v) = %q, %v; want %q", tt.lat, tt.long, tt.maxKm, got, ok, tt.want)
+		}
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	// London to New York is about 5570 km.
+	if d := haversineKm(51.5074, -0.1278, 40.7128, -74.0060); math.Abs(d-5570) > 10 {
+		t.Errorf("London to New York = %v km; want about 5570", d)
+	}
+	// A degree of latitude is about 111.2 km anywhere.
+	if d := haversineKm(89, 0, 90, 123); math.Abs(d-111.2) > 0.1 {
+		t.Errorf("one degree from the pole = %v km; want about 111.2", d)
+	}
+}
+
+func TestLookupZoneNameNautical(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{40.7128, -74.0060, "America/New_York"}, // land
+		{0, 0, "Etc/GMT"},
+		{0, 7.4, "Etc/GMT"},
+		{0, -7.4, "Etc/GMT"},
+		{0, 7.5, "Etc/GMT-1"},
+		{0, -7.6, "Etc/GMT+1"},
+		{-30, 75, "Etc/GMT-5"},  // Indian Ocean, UTC+5
+		{0, -30, "Etc/GMT+2"},   // Atlantic, UTC-2
+		{30, -140, "Etc/GMT+9"}, // Pacific, UTC-9
+		{-60, 172.6, "Etc/GMT-12"},
+		{-60, -172.6, "Etc/GMT+12"},
+		{-60, 187.4, "Etc/GMT+12"}, // wraps to -172.6
+		{-62, -120, "Etc/GMT+8"},   // Southern Ocean, not the nearest station's zone
+		{-65, 0, "Etc/GMT"},
+	}
+	for _, tt := range tests {
+		if got := LookupZoneNameNautical(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupZoneNameNautical(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	// Every nautical zone should be loadable and, for each whole
+	// hour, have the matching offset.
+	for long := -180.0; long < 180; long += 15 {
+		name := nauticalZoneName(long)
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("no zoneinfo: %v", err)
+		}
+		_, off := time.Date(2024, 1, 1, 0, 0, 0, 0, loc).Zone()
+		want := int(long/15) * 3600
+		if long == -180 {
+			want = -12 * 3600
+		}
+		if off != want {
+			t.Errorf("%s (long %v) has offset %d; want %d", name, long, off, want)
+		}
+	}
+}
+
+func BenchmarkLookupZoneName(b *testing.B) {
+	b.Run("Cold", func(b *testing.B) {
+		// Each lookup unpacks a fresh copy of the tables.
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			newCompiledLookuper().LookupName(40.7128, -74.0060)
+		}
+	})
+	b.Run("Warm", func(b *testing.B) {
+		LookupZoneName(0, 0) // unpack tables
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			LookupZoneName(40.7128, -74.0060)
+		}
+	})
+}
+
+// randomCoords returns n uniformly random coordinates.
+func randomCoords(n int) [][2]float64 {
+	r := rand.New(rand.NewSource(1))
+	coords := make([][2]float64, n)
+	for i := range coords {
+		coords[i] = [2]float64{r.Float64()*180 - 90, r.Float64()*360 - 180}
+	}
+	return coords
+}
+
+// Tests that lookups don't allocate once the tables are unpacked,
+// whatever kind of tile answers them.
+func TestLookupZoneNameAllocs(t *testing.T) {
+	coords := append(randomCoords(1000),
+		[2]float64{40.7128, -74.0060}, // static tile
+		[2]float64{41.609, -101.4219}, // 2-zone bitmap
+		[2]float64{49.6, 6.1},         // pixmap
+		[2]float64{0, -30},            // ocean
+		[2]float64{-80, 0},            // Antarctic fallback
+		[2]float64{95, 400},           // clamped and wrapped
+	)
+	LookupZoneName(0, 0) // unpack tables
+	if n := testing.AllocsPerRun(10, func() {
+		for _, c := range coords {
+			LookupZoneName(c[0], c[1])
+		}
+	}); n != 0 {
+		t.Errorf("%v allocs per %d lookups; want 0", n, len(coords))
+	}
+}
+
+func BenchmarkLookupZoneNameRandom(b *testing.B) {
+	coords := randomCoords(4096)
+	LookupZoneName(0, 0) // unpack tables
+	if n := testing.AllocsPerRun(1, func() {
+		for _, c := range coords {
+			LookupZoneName(c[0], c[1])
+		}
+	}); n != 0 {
+		b.Fatalf("%v allocs per %d lookups; want 0", n, len(coords))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := coords[i%len(coords)]
+		LookupZoneName(c[0], c[1])
+	}
+}
+
+func TestZoneChanges(t *testing.T) {
+	// West to east across Nebraska, from Mountain into Central
+	// time, in about 1 km steps.
+	var path [][2]float64
+	for long := -104.0; long <= -99; long += 0.0125 {
+		path = append(path, [2]float64{41, long})
+	}
+	changes := ZoneChanges(path)
+	if len(changes) < 2 || changes[0] != (ZoneChange{0, "America/Denver"}) || changes[len(changes)-1].Zone != "America/Chicago" {
+		t.Fatalf("ZoneChanges across Nebraska = %+v; want from America/Denver at 0 to America/Chicago", changes)
+	}
+	// Each change should be where the point's zone differs from
+	// the previous point's.
+	for _, c := range changes[1:] {
+		i := c.Index
+		if got, prev := LookupZoneName(path[i][0], path[i][1]), LookupZoneName(path[i-1][0], path[i-1][1]); got != c.Zone || prev == c.Zone {
+			t.Errorf("change %+v: zone %q after %q", c, got, prev)
+		}
+	}
+
+	// Points at sea, at the start and in the middle, don't change
+	// the zone.
+	nyc, atlantic := [2]float64{40.7128, -74.0060}, [2]float64{35, -40}
+	got := ZoneChanges([][2]float64{atlantic, nyc, atlantic, nyc, {41.8781, -87.6298}})
+	want := []ZoneChange{{1, "America/New_York"}, {4, "America/Chicago"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ZoneChanges with sea = %+v; want %+v", got, want)
+	}
+	if got := ZoneChanges([][2]float64{atlantic}); got != nil {
+		t.Errorf("ZoneChanges at sea = %+v; want none", got)
+	}
+}
+
+func TestZoneContains(t *testing.T) {
+	coords := [][2]float64{
+		{40.7128, -74.0060},  // New York
+		{37.7833, -122.4167}, // San Francisco
+		{0, -140},            // ocean
+	}
+	want := []bool{true, false, false}
+	got := CoordinatesInZone("America/New_York", coords)
+	for i, c := range coords {
+		if got[i] != want[i] {
+			t.Errorf("CoordinatesInZone[%d] (%v) = %v; want %v", i, c, got[i], want[i])
+		}
+		if in := ZoneContains("America/New_York", c[0], c[1]); in != want[i] {
+			t.Errorf("ZoneContains(America/New_York, %v, %v) = %v; want %v", c[0], c[1], in, want[i])
+		}
+	}
+}
+
+// trackCoords returns n points of a random walk starting in Oregon,
+// resembling a GPS track.
+func trackCoords(n int) [][2]float64 {
+	r := rand.New(rand.NewSource(1))
+	coords := make([][2]float64, n)
+	lat, long := 44.0, -121.0
+	for i := range coords {
+		lat += (r.Float64() - 0.5) * 0.001
+		long += (r.Float64() - 0.5) * 0.001
+		coords[i] = [2]float64{lat, long}
+	}
+	return coords
+}
+
+func BenchmarkLookupZoneNamesTrack(b *testing.B) {
+	coords := trackCoords(10000)
+	LookupZoneName(0, 0) // unpack tables
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		LookupZoneNames(coords)
+	}
+}
+
+func BenchmarkLookupZoneNameTrack(b *testing.B) {
+	coords := trackCoords(10000)
+	LookupZoneName(0, 0) // unpack tables
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, c := range coords {
+			LookupZoneName(c[0], c[1])
+		}
+	}
+}
+
+func TestLookuperLocation(t *testing.T) {
+	l := newCompiledLookuper()
+	for i := 0; i < 2; i++ { // uncached, then cached
+		loc, err := l.Location(40.7128, -74.0060)
+		if err != nil || loc == nil || loc.String() != "America/New_York" {
+			t.Errorf("Location(New York) = %v, %v; want America/New_York, nil", loc, err)
+		}
+	}
+	if loc, err := l.Location(0, -140); loc != nil || err != nil {
+		t.Errorf("Location in ocean = %v, %v; want nil, nil", loc, err)
+	}
+	if _, err := l.loadLocation("Nowhere/Atlantis"); err == nil {
+		t.Error("loading bogus zone succeeded")
+	}
+	if _, ok := l.locs.Load("Nowhere/Atlantis"); ok {
+		t.Error("failed zone load was cached")
+	}
+}
+
+// BenchmarkUnpack measures unpacking the compiled-in tables. Its
+// bytes/op is dominated by the heap the unpacked tables occupy.
+func BenchmarkUnpack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := newCompiledLookuper()
+		l.Warm()
+	}
+}
+
+func BenchmarkLocationCached(b *testing.B) {
+	l := newCompiledLookuper()
+	l.Location(40.7128, -74.0060)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Location(40.7128, -74.0060)
+	}
+}
+
+func BenchmarkLocationLoadLocation(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		time.LoadLocation(LookupZoneName(40.7128, -74.0060))
+	}
+}
+
+func TestLookupOffset(t *testing.T) {
+	// Daylight saving time began in America/New_York at 2am EST on
+	// March 10, 2024 (07:00 UTC).
+	dst := time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)
+	cases := []struct {
+		t    time.Time
+		want int
+	}{
+		{dst.Add(-time.Second), -5 * 3600},
+		{dst, -4 * 3600},
+		{dst.Add(time.Hour), -4 * 3600},
+	}
+	for _, tt := range cases {
+		got, ok := LookupOffset(40.7128, -74.0060, tt.t)
+		if !ok || got != tt.want {
+			t.Errorf("LookupOffset(New York, %v) = %d, %v; want %d, true", tt.t, got, ok, tt.want)
+		}
+	}
+	if got, ok := LookupOffset(0, -140, dst); ok {
+		t.Errorf("LookupOffset in ocean = %d, true; want false", got)
+	}
+}
+
+func TestIsBorderCoordinate(t *testing.T) {
+	tests := []struct {
+		lat, long, eps float64
+		want           bool
+	}{
+		{41.0, -101.4, 0.2, true},   // Nebraska, beside the Mountain/Central border
+		{41.0, -101.4, 0, false},    // no perturbation
+		{47.56, 7.59, 0.05, true},   // Basel
+		{39.0, -98.0, 0.5, false},   // central Kansas
+		{46.8, 8.2, -0.1, false},    // central Switzerland
+		{-10.0, -55.0, 0.25, false}, // central Brazil
+		{0, -140, 1, false},         // mid-Pacific, with no zone anywhere near
+	}
+	for _, tt := range tests {
+		if got := IsBorderCoordinate(tt.lat, tt.long, tt.eps); got != tt.want {
+			t.Errorf("IsBorderCoordinate(%v, %v, %v) = %v; want %v", tt.lat, tt.long, tt.eps, got, tt.want)
+		}
+	}
+}
+
+func TestLookupUTCOffsetHours(t *testing.T) {
+	jan := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		lat, long float64
+		want      float64
+	}{
+		{40.7128, -74.0060, -5},   // New York, in EST
+		{28.6139, 77.2090, 5.5},   // New Delhi
+		{27.7172, 85.3240, 5.75},  // Kathmandu
+		{-12.4634, 130.8456, 9.5}, // Darwin
+		{51.5074, -0.1278, 0},     // London
+	}
+	for _, tt := range tests {
+		got, ok := LookupUTCOffsetHours(tt.lat, tt.long, jan)
+		if !ok || got != tt.want {
+			t.Errorf("LookupUTCOffsetHours(%v, %v) = %v, %v; want %v, true", tt.lat, tt.long, got, ok, tt.want)
+		}
+	}
+	if got, ok := LookupUTCOffsetHours(0, -140, jan); ok {
+		t.Errorf("LookupUTCOffsetHours in ocean = %v, true; want false", got)
 	}
 }
 
@@ -79,6 +934,527 @@ func TestLookupPixel(t *testing.T) {
 	}
 }
 
+// Tests that concurrent first lookups unpack the tables safely. Run
+// with -race.
+func TestConcurrentUnpack(t *testing.T) {
+	l := newCompiledLookuper()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, want := l.LookupName(40.7128, -74.0060), "America/New_York"; got != want {
+				t.Errorf("LookupName = %q; want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Tests that lookups running concurrently with Release keep resolving
+// correctly, unpacking the tables again as needed.
+func TestReleaseConcurrent(t *testing.T) {
+	l := newCompiledLookuper()
+	l.Warm()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if got, want := l.LookupName(40.7128, -74.0060), "America/New_York"; got != want {
+					t.Errorf("LookupName = %q; want %q", got, want)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			l.Release()
+		}()
+	}
+	wg.Wait()
+
+	l.Release()
+	if tab, _ := l.tables.Load().(*unpackedTables); tab != nil {
+		t.Error("tables still unpacked after Release")
+	}
+	if got, want := l.LookupName(51.5074, -0.1278), "Europe/London"; got != want {
+		t.Errorf("after Release, LookupName = %q; want %q", got, want)
+	}
+}
+
+// Tests the concurrency guarantees of the package doc: many goroutines
+// mixing lookups of all kinds, Warm, and Release on one Lookuper, and
+// creating and using others, all from cold. Run with -race.
+func TestConcurrentMixed(t *testing.T) {
+	l := newCompiledLookuper()
+	coords := trackCoords(50)
+	want := make([]string, len(coords))
+	for i, c := range coords {
+		want[i] = newCompiledLookuper().LookupName(c[0], c[1])
+	}
+	goroutines := 200
+	if testing.Short() {
+		goroutines = 20
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			c := coords[g%len(coords)]
+			switch g % 6 {
+			case 0:
+				if got := l.LookupName(c[0], c[1]); got != want[g%len(coords)] {
+					t.Errorf("LookupName(%v, %v) = %q; want %q", c[0], c[1], got, want[g%len(coords)])
+				}
+			case 1:
+				if got := l.LookupNames(coords); !reflect.DeepEqual(got, want) {
+					t.Errorf("LookupNames = %q; want %q", got, want)
+				}
+			case 2:
+				if loc, err := l.Location(40.7128, -74.0060); err != nil || loc.String() != "America/New_York" {
+					t.Errorf("Location(New York) = %v, %v", loc, err)
+				}
+				if loc, err := LookupZone(40.7128, -74.0060); err != nil || loc.String() != "America/New_York" {
+					t.Errorf("LookupZone(New York) = %v, %v", loc, err)
+				}
+			case 3:
+				l.Warm()
+				Warm()
+			case 4:
+				l.Release()
+			case 5:
+				l2, err := NewLookuperFromCompiled()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := l2.LookupName(c[0], c[1]); got != want[g%len(coords)] {
+					t.Errorf("new Lookuper: LookupName(%v, %v) = %q; want %q", c[0], c[1], got, want[g%len(coords)])
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// compiledTables returns the compiled-in tables in the form taken by
+// NewLookuper.
+func compiledTables(t testing.TB) (levels [6][]byte, leaves []byte) {
+	var err error
+	for i, zl := range zoomLevels {
+		levels[i], err = base64.StdEncoding.DecodeString(zl.gzipData)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	leaves, err = base64.StdEncoding.DecodeString(uniqueLeavesPacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestNewLookuper(t *testing.T) {
+	levels, leaves := compiledTables(t)
+	l, err := NewLookuper(degPixels, levels, leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range trackCoords(1000) {
+		if got, want := l.LookupName(c[0], c[1]), LookupZoneName(c[0], c[1]); got != want {
+			t.Errorf("LookupName(%v, %v) = %q; want %q", c[0], c[1], got, want)
+		}
+	}
+	if got, want := l.LookupName(40.7128, -74.0060), "America/New_York"; got != want {
+		t.Errorf("LookupName(New York) = %q; want %q", got, want)
+	}
+
+	if _, err := NewLookuper(0, levels, leaves); err == nil {
+		t.Error("NewLookuper with zero degPixels succeeded")
+	}
+	bad := levels
+	bad[0] = leaves
+	if _, err := NewLookuper(degPixels, bad, leaves); err == nil {
+		t.Error("NewLookuper with corrupt zoom level succeeded")
+	}
+	if _, err := NewLookuper(degPixels, levels, leaves[:len(leaves)/2]); err == nil {
+		t.Error("NewLookuper with truncated leaves succeeded")
+	}
+	if _, err := NewLookuper(degPixels, levels, nil); err == nil {
+		t.Error("NewLookuper with no leaves succeeded")
+	}
+}
+
+func TestDataVersion(t *testing.T) {
+	v := DataVersion()
+	if dataVersion == "" {
+		if v != "unknown" {
+			t.Errorf("DataVersion() = %q; want unknown", v)
+		}
+		return
+	}
+	if want := fmt.Sprintf(", scale %d, ", degPixels); !strings.Contains(v, want) {
+		t.Errorf("DataVersion() = %q; want it to contain %q", v, want)
+	}
+}
+
+// Tests that when tiles of different sizes overlap, the smallest
+// wins.
+func TestValidate(t *testing.T) {
+	if err := Validate(); err != nil {
+		t.Fatalf("compiled-in tables: %v", err)
+	}
+
+	index := func(tks ...tileKey) []byte {
+		var b []byte
+		for _, tk := range tks {
+			var rec [6]byte
+			binary.BigEndian.PutUint32(rec[:], uint32(tk))
+			b = append(b, rec[:]...)
+		}
+		return gzipBytes(b)
+	}
+	tests := []struct {
+		level int
+		keys  []tileKey
+		want  string
+	}{
+		{3, []tileKey{newTileKey(3, 1, 1), newTileKey(3, 2, 1)}, ""},
+		{3, []tileKey{newTileKey(3, 2, 1), newTileKey(3, 1, 1)}, "keys must be sorted"},
+		{3, []tileKey{newTileKey(3, 1, 1), newTileKey(3, 1, 1)}, "appears twice"},
+		{3, []tileKey{newTileKey(2, 1, 1)}, "has size 2"},
+		{3, []tileKey{newTileKey(3, 180, 1)}, "off the map"},
+		{3, []tileKey{newTileKey(3, 1, 90)}, "off the map"},
+	}
+	for _, tt := range tests {
+		var levels [6][]byte
+		for i := range levels {
+			levels[i] = gzipBytes(nil)
+		}
+		levels[tt.level] = index(tt.keys...)
+		l, err := NewLookuper(32, levels, gzipBytes([]byte("SA\x00")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = l.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("keys %x: %v", tt.keys, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("keys %x: got error %v; want %q", tt.keys, err, tt.want)
+		}
+	}
+}
+
+// Tests that lookups with corrupt tables log a warning and resolve
+// what they can, rather than panicking.
+func TestCorruptTables(t *testing.T) {
+	if degPixels == -1 {
+		t.Skip("data not generated yet")
+	}
+	levels, leaves := compiledTables(t)
+	truncate := func(b []byte) []byte { return b[:len(b)/2] }
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	// A Lookuper like NewLookuper's, but not yet unpacked.
+	newLookuper := func(levels [6][]byte, leaves []byte) *Lookuper {
+		return &Lookuper{
+			degPixels: degPixels,
+			levelData: levelReaders(levels[:]),
+			leafData:  bytesReader(leaves),
+		}
+	}
+
+	const corrupt = 5
+	bad := levels
+	bad[corrupt] = truncate(levels[corrupt])
+	if _, err := NewLookuper(degPixels, bad, leaves); err == nil || !strings.Contains(err.Error(), "zoom level 5") {
+		t.Errorf("NewLookuper with truncated level: err = %v; want zoom level 5 error", err)
+	}
+	l := newLookuper(bad, leaves)
+	if err := l.Validate(); err == nil {
+		t.Error("Validate of truncated level = nil; want error")
+	}
+	good := defaultLookuper()
+	tab := good.mustLoad()
+	inLevel, elsewhere := 0, 0
+	for _, c := range randomCoords(2000) {
+		x, y := good.pixelOf(c[0], c[1])
+		want := good.lookupPixel(x, y)
+		if _, ok := tab.levels[corrupt].index(pixelTileKey(corrupt, x, y)); ok {
+			want = ""
+			inLevel++
+		} else if want != "" {
+			elsewhere++
+		}
+		if got := l.LookupName(c[0], c[1]); got != want {
+			t.Errorf("at %v: got %q; want %q", c, got, want)
+		}
+	}
+	if inLevel == 0 || elsewhere == 0 {
+		t.Errorf("%d coordinates in the corrupt level and %d resolved elsewhere; want some of each", inLevel, elsewhere)
+	}
+	if n := strings.Count(logBuf.String(), "zoom level 5"); n != 1 {
+		t.Errorf("logged %d warnings; want 1. Log:\n%s", n, logBuf.String())
+	}
+
+	l = newLookuper(levels, truncate(leaves))
+	for _, c := range randomCoords(100) {
+		if got := l.LookupName(c[0], c[1]); got != "" {
+			t.Fatalf("with truncated leaves, at %v: got %q; want no zone", c, got)
+		}
+	}
+
+	// Bitmaps and pixmaps referring to themselves, rather than to
+	// zones, would make lookups recurse forever.
+	var selfRef [6][]byte
+	for i := range selfRef {
+		selfRef[i] = gzipBytes(nil)
+	}
+	selfRef[0] = gzipBytes([]byte{0, 0, 0, 0, 0, 0}) // key 0 is leaf 0
+	pix := append([]byte{'P'}, make([]byte, 128)...)
+	for name, leaf := range map[string][]byte{
+		"bitmap": append([]byte("2\x00\x00\x00\x00"), make([]byte, 8)...),
+		"pixmap": pix,
+	} {
+		if _, err := NewLookuper(32, selfRef, gzipBytes(leaf)); err == nil {
+			t.Errorf("NewLookuper with self-referencing %s succeeded", name)
+		}
+		l := &Lookuper{degPixels: 32, levelData: levelReaders(selfRef[:]), leafData: bytesReader(gzipBytes(leaf))}
+		if err := l.Validate(); err == nil {
+			t.Errorf("Validate with self-referencing %s = nil; want error", name)
+		}
+		if got := l.LookupName(89.99, -179.99); got != "" {
+			t.Errorf("with self-referencing %s: got %q; want no zone", name, got)
+		}
+	}
+}
+
+func TestLookupSmallestTileFirst(t *testing.T) {
+	gz := func(b []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		return buf.Bytes()
+	}
+	index := func(tk tileKey, idx uint16) []byte {
+		b := make([]byte, 6)
+		binary.BigEndian.PutUint32(b, uint32(tk))
+		binary.BigEndian.PutUint16(b[4:], idx)
+		return gz(b)
+	}
+	var levels [6][]byte
+	for i := range levels {
+		levels[i] = gz(nil)
+	}
+	levels[5] = index(newTileKey(5, 0, 0), 0) // zone A: lat 82 to 90, long -180 to -172
+	levels[0] = index(newTileKey(0, 1, 1), 1) // zone B: lat 89.5 to 89.75, long -179.75 to -179.5
+	l, err := NewLookuper(32, levels, gz([]byte("SA\x00SB\x00")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{89.6, -179.6, "B"},
+		{89.9, -179.9, "A"},
+		{85, -175, "A"},
+		{80, -175, ""},
+	}
+	for _, tt := range tests {
+		if got := l.LookupName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+
+	// Batches reusing the last tile for nearby points must still find
+	// smaller tiles inside it.
+	coords := make([][2]float64, len(tests))
+	for i, tt := range tests {
+		coords[i] = [2]float64{tt.lat, tt.long}
+	}
+	for i, got := range l.LookupNames(coords) {
+		if want := tests[i].want; got != want {
+			t.Errorf("LookupNames[%d] at %v = %q; want %q", i, coords[i], got, want)
+		}
+	}
+	if got := l.LookupNames([][2]float64{{89.9, -179.9}, {89.6, -179.6}}); got[1] != "B" {
+		t.Errorf("LookupNames after a point in the big tile = %q; want B", got[1])
+	}
+
+	// The generated tables have no overlapping tiles.
+	if defaultLookuper().mustLoad().overlap {
+		t.Error("compiled-in tables have overlapping tiles")
+	}
+}
+
+func TestZoneNames(t *testing.T) {
+	names := ZoneNames()
+	// The generator logged "Num zones = 417" for the compiled-in
+	// tables. Newer tables record their count in DataVersion.
+	want := 417
+	if dataVersion != "" {
+		var source, date string
+		var scale int
+		if _, err := fmt.Sscanf(dataVersion, "%s %s scale %d, %d zones", &source, &date, &scale, &want); err != nil {
+			t.Fatalf("parsing DataVersion %q: %v", dataVersion, err)
+		}
+	}
+	if n := len(defaultLookuper().Names()); n != want {
+		t.Errorf("got %d zone names in the tables; want %d", n, want)
+	}
+	// Plus the Antarctic stations' zones, none of which are in the
+	// tables.
+	if want += len(antarcticZoneNames()); len(names) != want {
+		t.Errorf("got %d zone names; want %d", len(names), want)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Fatalf("names not sorted and distinct: %q before %q", names[i-1], names[i])
+		}
+	}
+	for _, name := range []string{"America/New_York", "Europe/Berlin", "Asia/Tokyo", "Antarctica/McMurdo"} {
+		if j := sort.SearchStrings(names, name); j == len(names) || names[j] != name {
+			t.Errorf("missing %q", name)
+		}
+	}
+	names[0] = "mutated"
+	if ZoneNames()[0] == "mutated" {
+		t.Error("ZoneNames returned internal state")
+	}
+}
+
+func TestZoneNamesPartialTables(t *testing.T) {
+	// Tables generated for only some zones have no Antarctic
+	// fallback, so ZoneNames mustn't list the stations' zones.
+	defer func(partial bool) { partialTables = partial }(partialTables)
+	partialTables = true
+	l := newCompiledLookuper()
+	if got, want := l.zoneNames(), l.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("zoneNames = %d names; want the tables' %d", len(got), len(want))
+	}
+	if got := l.LookupName(-80, 0); got != "" {
+		t.Errorf("LookupName(-80, 0) = %q; want no zone", got)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	l := newCompiledLookuper()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			l.Warm()
+		}()
+		go func() {
+			defer wg.Done()
+			l.LookupName(40.7128, -74.0060)
+		}()
+	}
+	wg.Wait()
+	l.Warm()
+	if tab := l.mustLoad(); len(tab.leaf) == 0 || len(tab.levels[0].keys) == 0 {
+		t.Fatal("tables not unpacked after Warm")
+	}
+	Warm()
+}
+
+func TestZoomLevelSearch(t *testing.T) {
+	l := defaultLookuper()
+	for level, zl := range l.mustLoad().levels {
+		n := len(zl.keys)
+		if n == 0 {
+			continue
+		}
+		if len(zl.idxs) != n {
+			t.Fatalf("level %d: %d keys but %d leaf indexes", level, n, len(zl.idxs))
+		}
+		for i := 1; i < n; i++ {
+			if zl.keys[i-1] >= zl.keys[i] {
+				t.Fatalf("level %d: tiles not sorted at %d: %x >= %x", level, i, zl.keys[i-1], zl.keys[i])
+			}
+		}
+		for _, i := range []int{0, n / 2, n - 2, n - 1} {
+			if i < 0 {
+				continue
+			}
+			idx, ok := zl.index(zl.keys[i])
+			if !ok {
+				t.Errorf("level %d: tile %d (%x) not found", level, i, zl.keys[i])
+				continue
+			}
+			if idx != zl.idxs[i] {
+				t.Errorf("level %d: tile %d resolved to leaf %d; want %d", level, i, idx, zl.idxs[i])
+			}
+		}
+		last := zl.keys[n-1]
+		if _, ok := zl.index(last + 1); ok {
+			t.Errorf("level %d: found tile past the end", level)
+		}
+	}
+}
+
+// Tests that no 2x2 block of solid tiles of one zone could be merged
+// into one tile of the next size up. The generator's size passes run
+// largest first and only split tiles that aren't all one zone, so
+// there should be none; if there are, the passes have regressed.
+func TestNoMergeableTiles(t *testing.T) {
+	tab := defaultLookuper().mustLoad()
+	for level := 0; level < len(tab.levels)-1; level++ {
+		zl := &tab.levels[level]
+		for i, tk := range zl.keys {
+			z, ok := tab.leaf[zl.idxs[i]].(staticZone)
+			if !ok || tk.x()%2 != 0 || tk.y()%2 != 0 {
+				continue
+			}
+			same := 0
+			for _, d := range [][2]uint16{{1, 0}, {0, 1}, {1, 1}} {
+				idx, ok := zl.index(newTileKey(uint8(level), tk.x()+d[0], tk.y()+d[1]))
+				if ok && tab.leaf[idx] == zoneLooker(z) {
+					same++
+				}
+			}
+			if same == 3 {
+				t.Errorf("level %d: tiles at (%d, %d) are a 2x2 block of %q", level, tk.x(), tk.y(), z)
+			}
+		}
+	}
+}
+
+// Tests a two-zone border tile at the Mountain/Central line in the
+// Nebraska panhandle, which is stored as a oneBitTile.
+func TestOneBitTileBorder(t *testing.T) {
+	const lat = 41.609
+	cases := []struct {
+		long float64
+		want string
+	}{
+		{-101.4219, "America/Denver"},
+		{-101.3594, "America/Chicago"},
+	}
+	l := defaultLookuper()
+	for _, tt := range cases {
+		if got := LookupZoneName(lat, tt.long); got != tt.want {
+			t.Errorf("LookupZoneName(%v, %v) = %q; want %q", lat, tt.long, got, tt.want)
+		}
+		x, y := l.pixelOf(lat, tt.long)
+		if zl, _ := l.mustLoad().lookupLeaf(x, y); zl == nil {
+			t.Errorf("(%v, %v): no tile", lat, tt.long)
+		} else if _, ok := zl.(oneBitTile); !ok {
+			t.Errorf("(%v, %v): tile is %T; want oneBitTile", lat, tt.long, zl)
+		}
+	}
+}
+
 func TestNewTileKey(t *testing.T) {
 	cases := []struct {
 		size, x, y int
@@ -105,3 +1481,144 @@ func TestNewTileKey(t *testing.T) {
 		}
 	}
 }
+
+func TestLatLongToTileKey(t *testing.T) {
+	l := defaultLookuper()
+	cases := []struct {
+		lat, long float64
+		x, y      int // pixel
+	}{
+		{0, 0, 180 * 32, 90 * 32},
+		{37.7833, -122.4167, 1842, 1670},
+		{-33.8688, 151.2093, 10598, 3963},
+
+		// Latitudes clamp to the poles and longitudes wrap
+		// around the antimeridian:
+		{90, -180, 0, 0},
+		{-90, 0, 180 * 32, 180*32 - 1},
+		{0, 180, 0, 90 * 32},
+		{-90, 180, 0, 180*32 - 1},
+		{0, 179.999999999999, 360*32 - 1, 90 * 32},
+		{95, -190, 350 * 32, 0},
+		{0, 540, 0, 90 * 32},
+	}
+	for _, tt := range cases {
+		for size := uint8(0); size < 6; size++ {
+			tk := l.latLongToTileKey(size, tt.lat, tt.long)
+			if tk.size() != size {
+				t.Errorf("(%v, %v) size %d: size() = %d", tt.lat, tt.long, size, tk.size())
+			}
+			shift := 3 + size
+			if got, want := int(tk.x()), tt.x>>shift; got != want {
+				t.Errorf("(%v, %v) size %d: x() = %d; want %d", tt.lat, tt.long, size, got, want)
+			}
+			if got, want := int(tk.y()), tt.y>>shift; got != want {
+				t.Errorf("(%v, %v) size %d: y() = %d; want %d", tt.lat, tt.long, size, got, want)
+			}
+			if tk != newTileKey(size, tk.x(), tk.y()) {
+				t.Errorf("(%v, %v) size %d: key doesn't round-trip", tt.lat, tt.long, size)
+			}
+		}
+	}
+}
+
+func TestSameZone(t *testing.T) {
+	tests := []struct {
+		lat1, long1, lat2, long2 float64
+		want                     bool
+	}{
+		{40.7128, -74.0060, 42.3601, -71.0589, true},  // New York, Boston
+		{40.7128, -74.0060, 41.8781, -87.6298, false}, // New York, Chicago
+		{48.8566, 2.3522, 48.8566, 2.3522, true},      // Paris, itself
+		{0, -140, 0, -140, false},                     // ocean, itself
+		{0, -140, 40.7128, -74.0060, false},           // ocean, New York
+		{40.7128, -74.0060, 40.7128, 285.9940, true},  // wrapped longitude
+	}
+	for _, tt := range tests {
+		if got := SameZone(tt.lat1, tt.long1, tt.lat2, tt.long2); got != tt.want {
+			t.Errorf("SameZone(%v, %v, %v, %v) = %v; want %v", tt.lat1, tt.long1, tt.lat2, tt.long2, got, tt.want)
+		}
+	}
+}
+
+func TestLookupOffsetBestEffort(t *testing.T) {
+	july := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		lat, long float64
+		want      int
+	}{
+		{40.7128, -74.0060, -4 * 3600}, // New York, in EDT
+		{0, -140, -9 * 3600},           // mid-Pacific
+		{30, 175, 12 * 3600},           // west of the antimeridian
+		{20, -178, -12 * 3600},         // east of it
+		{0, -7.4, 0},                   // Gulf of Guinea
+		{-62, -120, -8 * 3600},         // Southern Ocean
+		{0, math.NaN(), 0},
+		{math.NaN(), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := LookupOffsetBestEffort(tt.lat, tt.long, july); got != tt.want {
+			t.Errorf("LookupOffsetBestEffort(%v, %v) = %d; want %d", tt.lat, tt.long, got, tt.want)
+		}
+	}
+
+	// Without timezone data, land falls back to the standard offset.
+	if standardOffsets == nil {
+		return
+	}
+	l := defaultLookuper()
+	defer func(load func(string) (*time.Location, error)) {
+		timeLoadLocation = load
+		l.locs.Delete("America/Chicago")
+	}(timeLoadLocation)
+	l.locs.Delete("America/Chicago")
+	timeLoadLocation = func(name string) (*time.Location, error) {
+		return nil, errors.New("no tzdata")
+	}
+	if got, want := LookupOffsetBestEffort(41.8781, -87.6298, july), -6*3600; got != want {
+		t.Errorf("LookupOffsetBestEffort(Chicago) without tzdata = %d; want %d", got, want)
+	}
+}
+
+// Tests zones with extreme or unusual offsets, checking that the
+// Locations LookupZone returns for them give those offsets. Zones
+// without daylight saving time are checked now; the others at a fixed
+// time in their standard time.
+func TestLookupZoneUnusualOffsets(t *testing.T) {
+	july := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+	january := time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)
+	const h, m = 3600, 60
+	tests := []struct {
+		lat, long float64
+		zone      string
+		when      time.Time // or zero for now
+		offset    int
+	}{
+		{1.87, -157.4, "Pacific/Kiritimati", time.Time{}, 14 * h},
+		{27.7172, 85.324, "Asia/Kathmandu", time.Time{}, 5*h + 45*m},
+		{-9.8, -139.03, "Pacific/Marquesas", time.Time{}, -(9*h + 30*m)},
+		{-31.68, 128.88, "Australia/Eucla", time.Time{}, 8*h + 45*m},
+		{-43.95, -176.55, "Pacific/Chatham", july, 12*h + 45*m},
+		{-31.55, 159.08, "Australia/Lord_Howe", july, 10*h + 30*m},
+		{47.56, -52.71, "America/St_Johns", january, -(3*h + 30*m)},
+		{35.6892, 51.389, "Asia/Tehran", july, 3*h + 30*m},
+	}
+	for _, tt := range tests {
+		loc, err := LookupZone(tt.lat, tt.long)
+		if err != nil || loc == nil {
+			t.Errorf("LookupZone(%v, %v) = %v, %v", tt.lat, tt.long, loc, err)
+			continue
+		}
+		if loc.String() != tt.zone {
+			t.Errorf("LookupZone(%v, %v) = %v; want %v", tt.lat, tt.long, loc, tt.zone)
+			continue
+		}
+		when := tt.when
+		if when.IsZero() {
+			when = time.Now()
+		}
+		if name, off := when.In(loc).Zone(); off != tt.offset {
+			t.Errorf("%v at %v: offset %d (%s); want %d", tt.zone, when, off, name, tt.offset)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package latlongexif looks up the timezone where a photo was taken,
+// from the GPS coordinates in its EXIF metadata.
+//
+// It reads the EXIF itself, from JPEG files or bare TIFF data, so
+// neither it nor package latlong needs an EXIF library.
+package latlongexif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/bradfitz/latlong"
+)
+
+// ErrNoGPS is returned for images without GPS coordinates in their
+// EXIF, including images with no EXIF at all.
+var ErrNoGPS = errors.New("latlongexif: no GPS coordinates in EXIF")
+
+// LookupZoneName returns the timezone at the GPS coordinates in the
+// EXIF of the JPEG image or TIFF data read from r, as
+// latlong.LookupZoneName does. It returns ErrNoGPS if there are none,
+// and other errors if r can't be read or its EXIF is malformed. As for
+// latlong.LookupZoneName, a photo taken where there's no timezone,
+// such as at sea, has an empty zone and a nil error.
+func LookupZoneName(r io.Reader) (string, error) {
+	lat, long, err := LatLong(r)
+	if err != nil {
+		return "", err
+	}
+	return latlong.LookupZoneNameStrict(lat, long)
+}
+
+// LatLong returns the GPS latitude and longitude in the EXIF of the
+// JPEG image or TIFF data read from r, in decimal degrees, south and
+// west negative. It returns ErrNoGPS if there are none.
+func LatLong(r io.Reader) (lat, long float64, err error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return 0, 0, fmt.Errorf("latlongexif: reading image: %v", err)
+	}
+	var tiff []byte
+	switch string(magic) {
+	case "\xff\xd8":
+		tiff, err = jpegEXIF(br)
+	case "II", "MM":
+		tiff, err = ioutil.ReadAll(br)
+	default:
+		return 0, 0, errors.New("latlongexif: not a JPEG image or TIFF data")
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return gpsLatLong(tiff)
+}
+
+// exifHeader begins the APP1 segment of a JPEG holding its EXIF.
+const exifHeader = "Exif\x00\x00"
+
+// jpegEXIF returns the TIFF data of the EXIF segment of the JPEG read
+// from br. EXIF comes before the image data, so it doesn't read that.
+func jpegEXIF(br *bufio.Reader) ([]byte, error) {
+	br.Discard(2) // SOI
+	var hdr [4]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:2]); err != nil {
+			return nil, fmt.Errorf("latlongexif: reading JPEG: %v", err)
+		}
+		if hdr[0] != 0xff {
+			return nil, errors.New("latlongexif: malformed JPEG")
+		}
+		marker := hdr[1]
+		switch {
+		case marker == 0xff: // fill byte
+			br.UnreadByte()
+			continue
+		case marker == 0xd9 || marker == 0xda: // EOI, SOS: no EXIF before the image data
+			return nil, ErrNoGPS
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd7: // no length
+			continue
+		}
+		if _, err := io.ReadFull(br, hdr[2:]); err != nil {
+			return nil, fmt.Errorf("latlongexif: reading JPEG: %v", err)
+		}
+		n := int(binary.BigEndian.Uint16(hdr[2:])) - 2
+		if n < 0 {
+			return nil, errors.New("latlongexif: malformed JPEG")
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(br, seg); err != nil {
+			return nil, fmt.Errorf("latlongexif: reading JPEG: %v", err)
+		}
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte(exifHeader)) {
+			return seg[len(exifHeader):], nil
+		}
+	}
+}
+
+// TIFF tags and types used for GPS coordinates.
+const (
+	tagGPSIFD     = 0x8825
+	tagLatRef     = 1
+	tagLat        = 2
+	tagLongRef    = 3
+	tagLong       = 4
+	typeASCII     = 2
+	typeLong      = 4
+	typeRational  = 5
+	ifdEntryBytes = 12
+)
+
+var errMalformed = errors.New("latlongexif: malformed EXIF")
+
+// A tiffReader reads TIFF data, in its byte order.
+type tiffReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// uint32At returns the uint32 at offset off, or false if it's out of
+// range.
+func (t tiffReader) uint32At(off uint32) (uint32, bool) {
+	if uint64(off)+4 > uint64(len(t.b)) {
+		return 0, false
+	}
+	return t.order.Uint32(t.b[off:]), true
+}
+
+// ifd calls fn for each entry of the IFD at offset off, with the
+// entry's tag, type, count, and the offset of its value field.
+func (t tiffReader) ifd(off uint32, fn func(tag, typ uint16, count, valOff uint32)) error {
+	if uint64(off)+2 > uint64(len(t.b)) {
+		return errMalformed
+	}
+	n := uint32(t.order.Uint16(t.b[off:]))
+	off += 2
+	if uint64(off)+uint64(n)*ifdEntryBytes > uint64(len(t.b)) {
+		return errMalformed
+	}
+	for i := uint32(0); i < n; i++ {
+		e := t.b[off+i*ifdEntryBytes:]
+		fn(t.order.Uint16(e), t.order.Uint16(e[2:]), t.order.Uint32(e[4:]), off+i*ifdEntryBytes+8)
+	}
+	return nil
+}
+
+// degrees returns the degrees, minutes, and seconds RATIONAL triple at
+// the offset stored at valOff, in decimal degrees.
+func (t tiffReader) degrees(valOff uint32) (float64, bool) {
+	off, ok := t.uint32At(valOff)
+	if !ok {
+		return 0, false
+	}
+	var v float64
+	for i, unit := range [3]float64{1, 60, 3600} {
+		num, ok1 := t.uint32At(off + uint32(i)*8)
+		den, ok2 := t.uint32At(off + uint32(i)*8 + 4)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		if den == 0 {
+			if num == 0 { // unknown, as some cameras write for seconds
+				continue
+			}
+			return 0, false
+		}
+		v += float64(num) / float64(den) / unit
+	}
+	return v, true
+}
+
+// gpsLatLong returns the GPS coordinates in the EXIF TIFF data b.
+func gpsLatLong(b []byte) (lat, long float64, err error) {
+	if len(b) < 8 {
+		return 0, 0, errMalformed
+	}
+	t := tiffReader{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return 0, 0, errMalformed
+	}
+	if t.order.Uint16(b[2:]) != 42 {
+		return 0, 0, errMalformed
+	}
+
+	var gpsOff uint32
+	var hasGPS bool
+	if err := t.ifd(t.order.Uint32(b[4:]), func(tag, typ uint16, count, valOff uint32) {
+		if tag == tagGPSIFD && typ == typeLong && count == 1 {
+			gpsOff, hasGPS = t.order.Uint32(b[valOff:]), true
+		}
+	}); err != nil {
+		return 0, 0, err
+	}
+	if !hasGPS {
+		return 0, 0, ErrNoGPS
+	}
+
+	hasLat, hasLong, ok := false, false, true
+	latRef, longRef := byte('N'), byte('E')
+	if err := t.ifd(gpsOff, func(tag, typ uint16, count, valOff uint32) {
+		switch {
+		case tag == tagLatRef && typ == typeASCII && count >= 1:
+			latRef = b[valOff]
+		case tag == tagLongRef && typ == typeASCII && count >= 1:
+			longRef = b[valOff]
+		case tag == tagLat && typ == typeRational && count == 3:
+			var ok1 bool
+			lat, ok1 = t.degrees(valOff)
+			ok, hasLat = ok && ok1, true
+		case tag == tagLong && typ == typeRational && count == 3:
+			var ok1 bool
+			long, ok1 = t.degrees(valOff)
+			ok, hasLong = ok && ok1, true
+		}
+	}); err != nil {
+		return 0, 0, err
+	}
+	if !hasLat || !hasLong {
+		return 0, 0, ErrNoGPS
+	}
+	if !ok {
+		return 0, 0, errMalformed
+	}
+	switch latRef {
+	case 'S':
+		lat = -lat
+	case 'N':
+	default:
+		return 0, 0, errMalformed
+	}
+	switch longRef {
+	case 'W':
+		long = -long
+	case 'E':
+	default:
+		return 0, 0, errMalformed
+	}
+	return lat, long, nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlongexif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// gpsTIFF returns EXIF TIFF data in the given byte order with a GPS
+// IFD holding the given entries, or none if gps is nil.
+func gpsTIFF(order binary.ByteOrder, gps []gpsEntry) []byte {
+	var b bytes.Buffer
+	w := func(v interface{}) { binary.Write(&b, order, v) }
+	if order == binary.LittleEndian {
+		b.WriteString("II")
+	} else {
+		b.WriteString("MM")
+	}
+	w(uint16(42))
+	w(uint32(8)) // IFD0
+	if gps == nil {
+		w(uint16(0))
+		w(uint32(0))
+		return b.Bytes()
+	}
+	w(uint16(1))
+	w(uint16(tagGPSIFD))
+	w(uint16(typeLong))
+	w(uint32(1))
+	gpsOff := uint32(8 + 2 + ifdEntryBytes + 4)
+	w(gpsOff)
+	w(uint32(0)) // no next IFD
+
+	// The GPS IFD, followed by its rationals.
+	dataOff := gpsOff + 2 + uint32(len(gps))*ifdEntryBytes + 4
+	var data bytes.Buffer
+	w(uint16(len(gps)))
+	for _, e := range gps {
+		w(e.tag)
+		if e.ref != 0 {
+			w(uint16(typeASCII))
+			w(uint32(2))
+			b.Write([]byte{e.ref, 0, 0, 0})
+			continue
+		}
+		w(uint16(typeRational))
+		w(uint32(3))
+		w(dataOff + uint32(data.Len()))
+		binary.Write(&data, order, e.dms)
+	}
+	w(uint32(0))
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+type gpsEntry struct {
+	tag uint16
+	ref byte      // for the Ref tags
+	dms [6]uint32 // otherwise, degrees, minutes, and seconds as numerator, denominator pairs
+}
+
+// newYork are GPS entries for 40°42'46.08"N 74°0'21.6"W.
+var newYork = []gpsEntry{
+	{tag: tagLatRef, ref: 'N'},
+	{tag: tagLat, dms: [6]uint32{40, 1, 42, 1, 4608, 100}},
+	{tag: tagLongRef, ref: 'W'},
+	{tag: tagLong, dms: [6]uint32{74, 1, 0, 1, 216, 10}},
+}
+
+// jpegWithEXIF returns a small JPEG image with the EXIF TIFF data tiff.
+func jpegWithEXIF(t *testing.T, tiff []byte) []byte {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	b.Write(img.Bytes()[:2]) // SOI
+	if tiff != nil {
+		seg := append([]byte(exifHeader), tiff...)
+		b.Write([]byte{0xff, 0xe1})
+		binary.Write(&b, binary.BigEndian, uint16(len(seg)+2))
+		b.Write(seg)
+	}
+	b.Write(img.Bytes()[2:])
+	return b.Bytes()
+}
+
+func TestLookupZoneName(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		tiff := gpsTIFF(order, newYork)
+		for name, data := range map[string][]byte{"JPEG": jpegWithEXIF(t, tiff), "TIFF": tiff} {
+			lat, long, err := LatLong(bytes.NewReader(data))
+			if err != nil {
+				t.Errorf("%v %s: LatLong: %v", order, name, err)
+				continue
+			}
+			if lat < 40.7127 || lat > 40.7129 || long < -74.0061 || long > -74.0059 {
+				t.Errorf("%v %s: LatLong = %v, %v; want 40.7128, -74.0060", order, name, lat, long)
+			}
+			zone, err := LookupZoneName(bytes.NewReader(data))
+			if zone != "America/New_York" || err != nil {
+				t.Errorf("%v %s: LookupZoneName = %q, %v; want America/New_York", order, name, zone, err)
+			}
+		}
+	}
+}
+
+func TestNoGPS(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"no EXIF":    jpegWithEXIF(t, nil),
+		"no GPS IFD": jpegWithEXIF(t, gpsTIFF(binary.BigEndian, nil)),
+		"no lat":     jpegWithEXIF(t, gpsTIFF(binary.BigEndian, newYork[2:])),
+	} {
+		if zone, err := LookupZoneName(bytes.NewReader(data)); err != ErrNoGPS {
+			t.Errorf("%s: LookupZoneName = %q, %v; want ErrNoGPS", name, zone, err)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	badRef := append([]gpsEntry{{tag: tagLatRef, ref: 'X'}}, newYork[1:]...)
+	zeroDen := append([]gpsEntry{{tag: tagLat, dms: [6]uint32{40, 0, 0, 1, 0, 1}}}, newYork[2:]...)
+	tiff := gpsTIFF(binary.LittleEndian, newYork)
+	for name, data := range map[string][]byte{
+		"empty":       nil,
+		"not image":   []byte("GIF89a"),
+		"truncated":   jpegWithEXIF(t, tiff)[:30],
+		"short TIFF":  tiff[:6],
+		"bad offset":  tiff[:len(tiff)-20],
+		"bad ref":     gpsTIFF(binary.LittleEndian, badRef),
+		"zero denom":  gpsTIFF(binary.LittleEndian, zeroDen),
+		"not 42 TIFF": append([]byte("II\x2b\x00"), tiff[4:]...),
+	} {
+		zone, err := LookupZoneName(bytes.NewReader(data))
+		if err == nil || err == ErrNoGPS || !strings.HasPrefix(err.Error(), "latlongexif: ") {
+			t.Errorf("%s: LookupZoneName = %q, %v; want a latlongexif error other than ErrNoGPS", name, zone, err)
+		}
+	}
+}
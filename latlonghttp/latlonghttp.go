@@ -0,0 +1,107 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package latlonghttp serves timezone lookups over HTTP.
+//
+// For example, with a Handler registered at /tz, a request for
+//
+//	GET /tz?lat=40.7128&lng=-74.0060
+//
+// responds with
+//
+//	{"zone":"America/New_York"}
+package latlonghttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bradfitz/latlong"
+)
+
+// Handler is an http.Handler that looks up the zone at the
+// coordinate given by a GET request's "lat" and "lng" query
+// parameters, in decimal degrees.
+//
+// It responds with a JSON object whose "zone" field is the zone name.
+// If there's no zone at the coordinate, the status is 404 Not Found
+// and the zone is empty. Missing, malformed, or out of range
+// parameters get a 400 Bad Request with an "error" field instead.
+type Handler struct {
+	// Lookuper, if non-nil, is used instead of the compiled-in
+	// timezone tables.
+	Lookuper *latlong.Lookuper
+}
+
+type response struct {
+	Zone  *string `json:"zone,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+	q := r.URL.Query()
+	lat, err := parseParam(q.Get("lat"), "lat", 90)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+	long, err := parseParam(q.Get("lng"), "lng", 180)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	var zone string
+	if h.Lookuper != nil {
+		zone = h.Lookuper.LookupName(lat, long)
+	} else {
+		zone = latlong.LookupZoneName(lat, long)
+	}
+	code := http.StatusOK
+	if zone == "" {
+		code = http.StatusNotFound
+	}
+	writeJSON(w, code, response{Zone: &zone})
+}
+
+// parseParam parses the query parameter name, with value v, as a
+// number of degrees in the range [-max, max].
+func parseParam(v, name string, max float64) (float64, error) {
+	if v == "" {
+		return 0, fmt.Errorf("missing %q parameter", name)
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed %q parameter %q", name, v)
+	}
+	if !(f >= -max && f <= max) { // also catches NaN
+		return 0, fmt.Errorf("%q parameter %v out of range [-%v, %v]", name, f, max, max)
+	}
+	return f, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
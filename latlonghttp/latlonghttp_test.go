@@ -0,0 +1,86 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlonghttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/tz", Handler{})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cases := []struct {
+		query    string
+		wantCode int
+		wantZone string
+		wantErr  bool
+	}{
+		{"lat=40.7128&lng=-74.0060", 200, "America/New_York", false},
+		{"lat=0&lng=-140", 404, "", false},
+		{"lat=abc&lng=-74", 400, "", true},
+		{"lat=40.7", 400, "", true},
+		{"lat=91&lng=0", 400, "", true},
+		{"lat=0&lng=-180.5", 400, "", true},
+		{"lat=NaN&lng=0", 400, "", true},
+	}
+	for _, tt := range cases {
+		res, err := http.Get(ts.URL + "/tz?" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Zone  *string `json:"zone"`
+			Error string  `json:"error"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if err != nil {
+			t.Errorf("%s: decoding response: %v", tt.query, err)
+			continue
+		}
+		if res.StatusCode != tt.wantCode {
+			t.Errorf("%s: status = %d; want %d", tt.query, res.StatusCode, tt.wantCode)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q", tt.query, ct)
+		}
+		if tt.wantErr {
+			if body.Error == "" {
+				t.Errorf("%s: no error in response", tt.query)
+			}
+			continue
+		}
+		if body.Zone == nil || *body.Zone != tt.wantZone {
+			t.Errorf("%s: zone = %v; want %q", tt.query, body.Zone, tt.wantZone)
+		}
+	}
+
+	res, err := http.Post(ts.URL+"/tz?lat=0&lng=0", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d; want %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+// tzLinks maps each tz database link's name to the zone it's an alias
+// of. Populated by z_gen_links.go.
+var tzLinks map[string]string
+
+// CanonicalZone returns the canonical name of zone in the tz database:
+// if zone is a link, an alias kept for backward compatibility such as
+// "US/Eastern" or "Asia/Calcutta", the zone it links to, such as
+// "America/New_York" or "Asia/Kolkata". Other names, including those of
+// unknown zones, are returned unchanged.
+//
+// The links are those of the tz database release the package was
+// generated with; see z_gen_links.go. Zones that release merged into
+// others are links too, so the result is a zone with the same clocks
+// since 1970 but not necessarily in the same country: "Europe/Vatican"
+// links to "Europe/Rome".
+func CanonicalZone(zone string) string {
+	if target, ok := tzLinks[zone]; ok {
+		return target
+	}
+	return zone
+}
+
+// LookupZoneNameCanonical is like LookupZoneName, but returns the
+// canonical name of the zone, as CanonicalZone does. The source shapes
+// use the names current when they were drawn, some of which have since
+// become links, such as "Europe/Kiev", now "Europe/Kyiv"; canonical
+// names match those of current time zone databases.
+func LookupZoneNameCanonical(lat, long float64) string {
+	return CanonicalZone(LookupZoneName(lat, long))
+}
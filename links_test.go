@@ -0,0 +1,62 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanonicalZone(t *testing.T) {
+	for zone, want := range map[string]string{
+		"US/Eastern":       "America/New_York",
+		"US/Pacific":       "America/Los_Angeles",
+		"Asia/Calcutta":    "Asia/Kolkata",
+		"Europe/Kiev":      "Europe/Kyiv",
+		"America/Godthab":  "America/Nuuk",
+		"America/New_York": "America/New_York",
+		"Nowhere/Special":  "Nowhere/Special",
+		"":                 "",
+	} {
+		if got := CanonicalZone(zone); got != want {
+			t.Errorf("CanonicalZone(%q) = %q; want %q", zone, got, want)
+		}
+	}
+	for link, target := range tzLinks {
+		if _, ok := tzLinks[target]; ok {
+			t.Errorf("%q links to %q, which is a link itself", link, target)
+		}
+		if _, err := time.LoadLocation(target); err != nil {
+			t.Errorf("%q links to %q: %v", link, target, err)
+		}
+	}
+}
+
+func TestLookupZoneNameCanonical(t *testing.T) {
+	if got := LookupZoneName(50.45, 30.52); got != "Europe/Kiev" {
+		t.Skipf("Kyiv is %q in the compiled-in tables; test assumes the old Europe/Kiev", got)
+	}
+	if got, want := LookupZoneNameCanonical(50.45, 30.52), "Europe/Kyiv"; got != want {
+		t.Errorf("LookupZoneNameCanonical(Kyiv) = %q; want %q", got, want)
+	}
+	if got, want := LookupZoneNameCanonical(40.7128, -74.0060), "America/New_York"; got != want {
+		t.Errorf("LookupZoneNameCanonical(New York) = %q; want %q", got, want)
+	}
+	if got := LookupZoneNameCanonical(0, -140); got != "" {
+		t.Errorf("LookupZoneNameCanonical(ocean) = %q; want empty", got)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+// A LookupMode picks a point on the speed and accuracy tradeoff for
+// LookupZoneNameMode.
+type LookupMode int
+
+const (
+	// LookupDefault resolves coordinates as LookupZoneName does.
+	LookupDefault LookupMode = iota
+
+	// LookupFast returns the zone of the biggest tile of the tables
+	// containing the coordinate, searching from the biggest tiles
+	// down and stopping at the first found. It skips overrides (see
+	// Override) and the Antarctic fallback, so it returns what the
+	// tables alone say. It's a little faster than LookupDefault,
+	// most of all for lookups that LookupZoneName would answer with
+	// an override; both take well under a microsecond.
+	LookupFast
+
+	// LookupPrecise searches the tables from the smallest tiles up,
+	// so the most precise of any overlapping tiles wins, and tests
+	// coordinates in tiles of more than one zone, or in the smallest
+	// tiles, against the border polygons, as LookupZoneNameAccurate
+	// does. Those polygons aren't part of this package as
+	// distributed; without z_gen_borders.go (see the README), the
+	// polygons are skipped and LookupPrecise only differs from
+	// LookupDefault for tables with overlapping tiles. Away from
+	// borders it's about as fast as LookupDefault; near them, with
+	// the polygons, finding and testing those of the zones nearby
+	// takes tens of microseconds.
+	LookupPrecise
+)
+
+// LookupZoneNameMode is like LookupZoneName, but resolves the
+// coordinate the way mode says, so one program can use LookupFast
+// for bulk lookups and LookupPrecise for interactive ones. Unknown
+// modes are treated as LookupDefault.
+func LookupZoneNameMode(lat, long float64, mode LookupMode) string {
+	l := defaultLookuper()
+	switch mode {
+	case LookupFast:
+		if l.degPixels == -1 {
+			return l.LookupName(lat, long)
+		}
+		x, y := l.pixelOf(lat, long)
+		return l.searchPixel(x, y, false)
+	case LookupPrecise:
+		return l.lookupPrecise(loadBorders(), lat, long)
+	}
+	return l.LookupName(lat, long)
+}
+
+// searchPixel returns the zone at pixel (x, y) of l's tables,
+// searching them from the smallest tiles up if smallestFirst or else
+// from the biggest down.
+func (l *Lookuper) searchPixel(x, y int, smallestFirst bool) string {
+	t := l.mustLoad()
+	if zl, tk := t.searchLeaf(x, y, smallestFirst); zl != nil {
+		zone, _ := zl.LookupZone(t.leaf, x, y, tk)
+		return zone
+	}
+	return ""
+}
+
+// lookupPrecise is LookupZoneNameMode's LookupPrecise, with the border
+// polygons polys.
+func (l *Lookuper) lookupPrecise(polys map[string][]override, lat, long float64) string {
+	if zone, ok := l.override(lat, long); ok {
+		return zone
+	}
+	if l.degPixels == -1 {
+		return l.LookupName(lat, long)
+	}
+	x, y := l.pixelOf(lat, long)
+	t := l.mustLoad()
+	zone := ""
+	if zl, tk := t.searchLeaf(x, y, true); zl != nil {
+		zone, _ = zl.LookupZone(t.leaf, x, y, tk)
+		if _, solid := zl.(staticZone); !solid || tk.size() == 0 {
+			if pz, ok := accurateZone(polys, lat, long); ok {
+				return pz
+			}
+		}
+	}
+	if zone == "" && l.fallback != nil {
+		return l.fallback(lat, long)
+	}
+	return zone
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import "testing"
+
+func TestLookupZoneNameMode(t *testing.T) {
+	// Away from borders, in tiles bigger than the smallest, the modes
+	// agree.
+	checked := 0
+	for _, c := range randomCoords(5000) {
+		lat, long := c[0], c[1]
+		if lat < -60 {
+			continue // LookupFast skips the Antarctic fallback
+		}
+		if _, size := LookupZoneNameConfidence(lat, long); size <= 0.25 {
+			continue
+		}
+		checked++
+		want := LookupZoneName(lat, long)
+		for _, mode := range []LookupMode{LookupDefault, LookupFast, LookupPrecise} {
+			if got := LookupZoneNameMode(lat, long, mode); got != want {
+				t.Errorf("LookupZoneNameMode(%v, %v, %v) = %q; want %q", lat, long, mode, got, want)
+			}
+		}
+	}
+	if checked < 1000 {
+		t.Errorf("only checked %d coordinates away from borders", checked)
+	}
+
+	if got := LookupZoneNameMode(-80, 0, LookupFast); got != "" {
+		t.Errorf("LookupFast in Antarctica = %q; want none", got)
+	}
+	if got, want := LookupZoneNameMode(-80, 0, LookupPrecise), LookupZoneName(-80, 0); got != want {
+		t.Errorf("LookupPrecise in Antarctica = %q; want %q", got, want)
+	}
+
+	// Near borders, they may differ. Pretend central Basel is in
+	// Germany, as in TestLookupZoneNameAccurate, passing the polygon
+	// to lookupPrecise rather than replacing the compiled-in ones.
+	polys, err := decodeBorders(encodeBorders(
+		[]string{"Europe/Berlin"},
+		[][][2]float64{{{47.55, 7.58}, {47.55, 7.60}, {47.57, 7.60}, {47.57, 7.58}, {47.55, 7.58}}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := defaultLookuper()
+	tests := []struct {
+		lat, long float64
+		mode      LookupMode
+		want      string
+	}{
+		{47.56, 7.59, LookupFast, "Europe/Zurich"},
+		{47.56, 7.59, LookupDefault, "Europe/Zurich"},
+		{47.56, 7.59, LookupPrecise, "Europe/Berlin"},
+		{47.55, 7.65, LookupPrecise, "Europe/Zurich"}, // outside the polygon
+	}
+	for _, tt := range tests {
+		var got string
+		if tt.mode == LookupPrecise {
+			got = l.lookupPrecise(polys, tt.lat, tt.long)
+		} else {
+			got = LookupZoneNameMode(tt.lat, tt.long, tt.mode)
+		}
+		if got != tt.want {
+			t.Errorf("mode %v at (%v, %v) = %q; want %q", tt.mode, tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkLookupZoneNameModeFast(b *testing.B) {
+	benchmarkLookupZoneNameMode(b, LookupFast)
+}
+
+func BenchmarkLookupZoneNameModePrecise(b *testing.B) {
+	benchmarkLookupZoneNameMode(b, LookupPrecise)
+}
+
+func benchmarkLookupZoneNameMode(b *testing.B, mode LookupMode) {
+	coords := randomCoords(1024)
+	Warm()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := coords[i%len(coords)]
+		LookupZoneNameMode(c[0], c[1], mode)
+	}
+}
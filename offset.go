@@ -0,0 +1,54 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+// Populated by z_gen_offsets.go, if generated with --generate_offsets.
+var (
+	standardOffsets   map[string]int32 // zone name to seconds east of UTC
+	standardOffsetsAt string           // reference instant, RFC 3339
+)
+
+// LookupStandardOffset returns the standard-time UTC offset, in
+// seconds east of UTC, of the timezone at the given latitude and
+// longitude in the compiled-in tables. See Lookuper.LookupStandardOffset.
+func LookupStandardOffset(lat, long float64) (seconds int, ok bool) {
+	return defaultLookuper().LookupStandardOffset(lat, long)
+}
+
+// LookupStandardOffset returns the standard-time UTC offset, in
+// seconds east of UTC, of the zone LookupName returns for the given
+// latitude and longitude. It's meant for programs without a timezone
+// database, where LookupZone can't load locations: the offsets are
+// precomputed by the generator (see its --generate_offsets flag) and
+// compiled in, so it needs no tzdata at all.
+//
+// The offsets are each zone's standard time at the single reference
+// instant the generator was run for. They ignore daylight saving time
+// entirely, and are wrong for any instant at which a zone's standard
+// offset differs from the reference instant's, such as after a
+// government changes it. Use LookupZone where accuracy matters.
+//
+// The ok result is false if the coordinate has no zone, or its zone
+// has no precomputed offset, as for zones added with Override.
+func (l *Lookuper) LookupStandardOffset(lat, long float64) (seconds int, ok bool) {
+	zone := l.LookupName(lat, long)
+	if zone == "" {
+		return 0, false
+	}
+	off, ok := standardOffsets[zone]
+	return int(off), ok
+}
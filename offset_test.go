@@ -0,0 +1,53 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import "testing"
+
+func TestLookupStandardOffset(t *testing.T) {
+	if standardOffsets == nil {
+		t.Skip("offsets not generated yet")
+	}
+	tests := []struct {
+		lat, long float64
+		want      int
+		ok        bool
+	}{
+		{40.7128, -74.0060, -5 * 3600, true},    // New York, ignoring EDT
+		{-33.8688, 151.2093, 10 * 3600, true},   // Sydney, ignoring AEDT
+		{28.6139, 77.2090, 5*3600 + 1800, true}, // New Delhi
+		{53.3498, -6.2603, 0, true},             // Dublin
+		{0, -140, 0, false},                     // Pacific Ocean
+	}
+	for _, tt := range tests {
+		got, ok := LookupStandardOffset(tt.lat, tt.long)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("LookupStandardOffset(%v, %v) = %d, %v; want %d, %v", tt.lat, tt.long, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStandardOffsetsCoverZones(t *testing.T) {
+	if standardOffsets == nil {
+		t.Skip("offsets not generated yet")
+	}
+	for _, zone := range ZoneNames() {
+		if _, ok := standardOffsets[zone]; !ok {
+			t.Errorf("no standard offset for %q", zone)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import "math"
+
+// An override is a polygon whose coordinates resolve to zone,
+// regardless of the tables.
+type override struct {
+	zone string
+	poly [][2]float64 // (lat, long) vertices
+
+	minLat, minLong, maxLat, maxLong float64 // bounding box
+}
+
+// Override adds an override to the compiled-in timezone tables. See
+// Lookuper.Override.
+func Override(zone string, polygon [][2]float64) {
+	defaultLookuper().Override(zone, polygon)
+}
+
+// Override makes coordinates inside polygon resolve to zone, for
+// patching a region whose boundary has changed since l's tables were
+// generated. The polygon is a list of at least three (latitude,
+// longitude) vertices, with longitudes in [-180, 180]; it's closed
+// implicitly and must not cross the antimeridian.
+//
+// Overrides are checked before the tables, in the order they were
+// added, and the first one containing a coordinate wins. They affect
+// LookupName, LookupNames, LookupTile, and the functions built on
+// them, such as LookupZoneName and Location for the compiled-in
+// tables. Overrides
+// are held in memory only, so they must be added again each time the
+// program starts. Override is safe to call concurrently with lookups.
+func (l *Lookuper) Override(zone string, polygon [][2]float64) {
+	o, ok := newOverride(zone, append([][2]float64(nil), polygon...))
+	if !ok {
+		return
+	}
+	l.overrideMu.Lock()
+	defer l.overrideMu.Unlock()
+	old, _ := l.overrides.Load().([]override)
+	l.overrides.Store(append(old[:len(old):len(old)], o))
+}
+
+// newOverride returns an override of poly, which it retains, to zone.
+// It returns false if poly has fewer than three vertices.
+func newOverride(zone string, poly [][2]float64) (o override, ok bool) {
+	if len(poly) < 3 {
+		return override{}, false
+	}
+	o = override{
+		zone:    zone,
+		poly:    poly,
+		minLat:  math.Inf(1),
+		minLong: math.Inf(1),
+		maxLat:  math.Inf(-1),
+		maxLong: math.Inf(-1),
+	}
+	for _, v := range poly {
+		o.minLat, o.maxLat = math.Min(o.minLat, v[0]), math.Max(o.maxLat, v[0])
+		o.minLong, o.maxLong = math.Min(o.minLong, v[1]), math.Max(o.maxLong, v[1])
+	}
+	return o, true
+}
+
+// override returns the zone of the first override containing the
+// given coordinate, if any.
+func (l *Lookuper) override(lat, long float64) (zone string, ok bool) {
+	ovs, _ := l.overrides.Load().([]override)
+	if len(ovs) == 0 {
+		return "", false
+	}
+	long = wrapLong(long)
+	for i := range ovs {
+		if ovs[i].contains(lat, long) {
+			return ovs[i].zone, true
+		}
+	}
+	return "", false
+}
+
+// overridesTile reports whether any override's bounding box overlaps
+// the tile with key k, so that not all of the tile's coordinates
+// need resolve to its zone.
+func (l *Lookuper) overridesTile(k tileKey) bool {
+	ovs, _ := l.overrides.Load().([]override)
+	if len(ovs) == 0 {
+		return false
+	}
+	deg := float64(int(8)<<k.size()) / float64(l.degPixels)
+	west, north := float64(k.x())*deg-180, 90-float64(k.y())*deg
+	for i := range ovs {
+		o := &ovs[i]
+		if o.minLat <= north && o.maxLat >= north-deg && o.minLong <= west+deg && o.maxLong >= west {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether the coordinate is inside o's polygon, by
+// counting how many of its edges a ray from it crosses. The polygon
+// may be several closed rings one after another, such as an outer ring
+// and its holes: the edges joining them cancel out.
+func (o *override) contains(lat, long float64) bool {
+	if lat < o.minLat || lat > o.maxLat || long < o.minLong || long > o.maxLong {
+		return false
+	}
+	in := false
+	for i, j := 0, len(o.poly)-1; i < len(o.poly); j, i = i, i+1 {
+		a, b := o.poly[i], o.poly[j]
+		if (a[0] > lat) != (b[0] > lat) &&
+			long < (b[1]-a[1])*(lat-a[0])/(b[0]-a[0])+a[1] {
+			in = !in
+		}
+	}
+	return in
+}
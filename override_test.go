@@ -0,0 +1,103 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOverride(t *testing.T) {
+	l := newCompiledLookuper()
+	const lat, long = 41.609, -101.4219 // Nebraska panhandle, in Denver's zone
+	if got := l.LookupName(lat, long); got != "America/Denver" {
+		t.Fatalf("before override: %q; want America/Denver", got)
+	}
+
+	// A triangle around the point, then a box around both, which
+	// loses to the triangle since it's added later.
+	l.Override("America/Chicago", [][2]float64{{41, -102}, {42, -102}, {41.5, -101}})
+	l.Override("Test/Box", [][2]float64{{40, -103}, {43, -103}, {43, -100}, {40, -100}})
+
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{lat, long, "America/Chicago"},
+		{41.9, -101.2, "Test/Box"},             // outside the triangle
+		{39.7392, -104.9903, "America/Denver"}, // Denver, outside both
+		{40.5, -460.5, "Test/Box"},             // longitude wrapped
+	}
+	for _, tt := range tests {
+		if got := l.LookupName(tt.lat, tt.long); got != tt.want {
+			t.Errorf("LookupName(%v, %v) = %q; want %q", tt.lat, tt.long, got, tt.want)
+		}
+	}
+	var coords [][2]float64
+	var want []string
+	for _, tt := range tests {
+		coords = append(coords, [2]float64{tt.lat, tt.long})
+		want = append(want, tt.want)
+	}
+	if got := l.LookupNames(coords); !reflect.DeepEqual(got, want) {
+		t.Errorf("LookupNames = %q; want %q", got, want)
+	}
+
+	// The compiled-in tables' default Lookuper is unaffected.
+	if got := LookupZoneName(lat, long); got != "America/Denver" {
+		t.Errorf("LookupZoneName = %q; want America/Denver", got)
+	}
+}
+
+func TestOverrideLookupTile(t *testing.T) {
+	l := newCompiledLookuper()
+	const lat, long = -10, -55 // a solid America/Cuiaba tile
+	tk, zone, ok := l.LookupTile(lat, long)
+	if zone != "America/Cuiaba" || !ok {
+		t.Fatalf("before override: %+v, %q, %v; want a solid America/Cuiaba tile", tk, zone, ok)
+	}
+	l.Override("Test/Box", [][2]float64{{-10.5, -55.5}, {-9.5, -55.5}, {-9.5, -54.5}, {-10.5, -54.5}})
+
+	if tk, zone, ok := l.LookupTile(lat, long); tk != (TileKey{}) || zone != "Test/Box" || ok {
+		t.Errorf("in override: got %+v, %q, %v; want the override's zone and no tile", tk, zone, ok)
+	}
+	// Elsewhere in the same tile, the tile's zone can't be memoized.
+	deg := float64(tk.Size) / 32
+	lat2, long2 := 90-float64(tk.Y)*deg-0.01, float64(tk.X)*deg-180+0.01
+	if got, zone, ok := l.LookupTile(lat2, long2); got != tk || zone != "America/Cuiaba" || ok {
+		t.Errorf("elsewhere in the tile at (%v, %v): got %+v, %q, %v; want %+v, America/Cuiaba, false", lat2, long2, got, zone, ok, tk)
+	}
+	// Other solid tiles are unaffected.
+	if _, zone, ok := l.LookupTile(-20, -45); zone == "" || !ok {
+		t.Errorf("far from the override: got %q, %v; want a solid tile", zone, ok)
+	}
+}
+
+func TestOverrideConcurrent(t *testing.T) {
+	l := newCompiledLookuper()
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			l.Override("Test/Zone", [][2]float64{{0, 0}, {1, 0}, {1, 1}})
+		}
+		close(done)
+	}()
+	for _, c := range trackCoords(1000) {
+		l.LookupName(c[0], c[1])
+	}
+	<-done
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// Shards are tables files, in the format ReadLookuper reads, with only
+// the tiles of one cell of a coarse grid of the map, as written by the
+// generator's --shards_dir flag, for programs that only need some
+// regions' tables. ShardFile names the shard covering a coordinate.
+// The first shard a program needs is read with ReadLookuper, and the
+// rest are added with LoadShard.
+
+// ShardFile returns the name of the file the generator writes the shard
+// covering the given latitude and longitude to, when its grid cells
+// are degrees square. Cells are named for their northwest corner, such
+// as "N50W080.tables" for the 40 degree cell from 50°N to 10°N and
+// 80°W to 40°W. Longitudes wrap around the antimeridian.
+func ShardFile(lat, long float64, degrees int) string {
+	lat, long = NormalizeCoordinate(lat, long)
+	col := int(math.Floor((long + 180) / float64(degrees)))
+	row := int(math.Floor((90 - lat) / float64(degrees)))
+	if row > 0 && 90-row*degrees <= -90 {
+		row-- // the south pole is in the last row
+	}
+	return shardName(row, col, degrees)
+}
+
+// shardName returns the name of the shard of the grid cell in the
+// given row and column, from the top left, of degrees square cells.
+func shardName(row, col, degrees int) string {
+	lat, long := 90-row*degrees, -180+col*degrees
+	ns, ew := "N", "E"
+	if lat < 0 {
+		ns, lat = "S", -lat
+	}
+	if long < 0 {
+		ew, long = "W", -long
+	}
+	return fmt.Sprintf("%s%02d%s%03d.tables", ns, lat, ew, long)
+}
+
+// LoadShard adds the tiles of the shard read from r to l, so lookups
+// in the shard's cell resolve to its regions. The shard must have the
+// same pixels per degree as l. Where the shard has a tile l already
+// has, the shard's replaces it. It's safe to call concurrently with
+// lookups, which see the shard once LoadShard returns, but it makes
+// l's tables unpack again if they've been released.
+func (l *Lookuper) LoadShard(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	st, err := l.unpackShard(b)
+	if err != nil {
+		return err
+	}
+
+	l.unpackMu.Lock()
+	defer l.unpackMu.Unlock()
+	if l.unpackErr != nil {
+		return l.unpackErr
+	}
+	if t, _ := l.tables.Load().(*unpackedTables); t != nil {
+		merged, err := mergeTables(t, st, l.degPixels)
+		if err != nil {
+			return err
+		}
+		l.tables.Store(merged)
+	}
+	l.shards = append(l.shards, b)
+	return nil
+}
+
+// unpackShard parses and unpacks the shard tables file b, which must
+// have l's pixels per degree.
+func (l *Lookuper) unpackShard(b []byte) (*unpackedTables, error) {
+	degPixels, levels, leaves, err := parseTables(b)
+	if err != nil {
+		return nil, err
+	}
+	if degPixels != l.degPixels {
+		return nil, fmt.Errorf("latlong: shard has %d pixels per degree; want %d", degPixels, l.degPixels)
+	}
+	sl := &Lookuper{
+		degPixels: degPixels,
+		levelData: levelReaders(levels[:]),
+		leafData:  bytesReader(leaves),
+	}
+	return sl.unpack()
+}
+
+var errTooManyShardLeaves = errors.New("latlong: too many leaves in the loaded shards")
+
+// mergeTables returns the tables with the tiles of both a and b, b's
+// replacing a's where both have a tile. Neither is modified, since
+// lookups may be using them.
+func mergeTables(a, b *unpackedTables, degPixels int) (*unpackedTables, error) {
+	if len(a.leaf)+len(b.leaf) >= int(oceanIndex) {
+		return nil, errTooManyShardLeaves
+	}
+	off := uint16(len(a.leaf))
+	m := &unpackedTables{leaf: make([]zoneLooker, 0, len(a.leaf)+len(b.leaf))}
+	m.leaf = append(m.leaf, a.leaf...)
+	for _, z := range b.leaf {
+		switch z := z.(type) {
+		case oneBitTile:
+			z.idx[0] += off
+			z.idx[1] += off
+			m.leaf = append(m.leaf, z)
+		case pixmap:
+			p := []byte(z)
+			for j := 0; j < len(p); j += 2 {
+				if idx := binary.BigEndian.Uint16(p[j:]); idx != oceanIndex {
+					binary.BigEndian.PutUint16(p[j:], idx+off)
+				}
+			}
+			m.leaf = append(m.leaf, pixmap(p))
+		default:
+			m.leaf = append(m.leaf, z)
+		}
+	}
+	n := len(a.levels)
+	if len(b.levels) > n {
+		n = len(b.levels)
+	}
+	m.levels = make([]zoomLevel, n)
+	for i := range m.levels {
+		var za, zb zoomLevel
+		if i < len(a.levels) {
+			za = a.levels[i]
+		}
+		if i < len(b.levels) {
+			zb = b.levels[i]
+		}
+		zm := &m.levels[i]
+		zm.indexBytes = za.indexBytes + zb.indexBytes
+		n := len(za.keys) + len(zb.keys)
+		zm.keys, zm.idxs = make([]tileKey, 0, n), make([]uint16, 0, n)
+		j, k := 0, 0
+		for j < len(za.keys) || k < len(zb.keys) {
+			switch {
+			case k == len(zb.keys) || j < len(za.keys) && za.keys[j] < zb.keys[k]:
+				zm.keys, zm.idxs = append(zm.keys, za.keys[j]), append(zm.idxs, za.idxs[j])
+				j++
+			default:
+				if j < len(za.keys) && za.keys[j] == zb.keys[k] {
+					j++ // replaced by b's
+				}
+				zm.keys, zm.idxs = append(zm.keys, zb.keys[k]), append(zm.idxs, zb.idxs[k]+off)
+				k++
+			}
+		}
+	}
+	m.overlap = m.tilesOverlap(degPixels)
+	return m, nil
+}
+
+// splitShards splits the given tables into shards of degrees square
+// grid cells, for the generator's --shards_dir flag, returning the
+// contents of each non-empty shard's tables file by its ShardFile name.
+// Each cell must be a whole number of the largest, 256 pixel, tiles.
+func splitShards(degPixels int, levels [6][]byte, leaves []byte, degrees int) (map[string][]byte, error) {
+	cellPixels := degrees * degPixels
+	if degrees <= 0 || cellPixels%256 != 0 {
+		return nil, fmt.Errorf("latlong: %d degree shards aren't a whole number of 256 pixel tiles at %d pixels per degree", degrees, degPixels)
+	}
+	l, err := NewLookuper(degPixels, levels, leaves)
+	if err != nil {
+		return nil, err
+	}
+	t := l.mustLoad()
+
+	// A shard is being built for each cell with tiles.
+	type shard struct {
+		keys  [6][]tileKey
+		idxs  [6][]uint16
+		index map[uint16]uint16 // t.leaf index -> shard leaf index
+		leaf  []zoneLooker      // in t.leaf's order of first use
+	}
+	shards := map[[2]int]*shard{} // by row, column
+	var cells [][2]int
+	// add returns s's index for t.leaf[idx], adding it, and any
+	// leaves it refers to, if needed.
+	var add func(s *shard, idx uint16) uint16
+	add = func(s *shard, idx uint16) uint16 {
+		if si, ok := s.index[idx]; ok {
+			return si
+		}
+		z := t.leaf[idx]
+		switch zt := z.(type) {
+		case oneBitTile:
+			zt.idx[0], zt.idx[1] = add(s, zt.idx[0]), add(s, zt.idx[1])
+			z = zt
+		case pixmap:
+			p := []byte(zt)
+			for j := 0; j < len(p); j += 2 {
+				if i := binary.BigEndian.Uint16(p[j:]); i != oceanIndex {
+					binary.BigEndian.PutUint16(p[j:], add(s, i))
+				}
+			}
+			z = pixmap(p)
+		}
+		si := uint16(len(s.leaf))
+		s.index[idx] = si
+		s.leaf = append(s.leaf, z)
+		return si
+	}
+	for level, zl := range t.levels {
+		size := 8 << uint(level)
+		for i, tk := range zl.keys {
+			cell := [2]int{int(tk.y()) * size / cellPixels, int(tk.x()) * size / cellPixels}
+			s := shards[cell]
+			if s == nil {
+				s = &shard{index: map[uint16]uint16{}}
+				shards[cell] = s
+				cells = append(cells, cell)
+			}
+			s.keys[level] = append(s.keys[level], tk)
+			s.idxs[level] = append(s.idxs[level], add(s, zl.idxs[i]))
+		}
+	}
+
+	files := map[string][]byte{}
+	for _, cell := range cells {
+		s := shards[cell]
+		var sl [6][]byte
+		for level := range sl {
+			var index bytes.Buffer
+			for i, tk := range s.keys[level] {
+				binary.Write(&index, binary.BigEndian, tk)
+				binary.Write(&index, binary.BigEndian, s.idxs[level][i])
+			}
+			sl[level] = gzipBlob(index.Bytes())
+		}
+		var packed bytes.Buffer
+		for _, z := range s.leaf {
+			packed.Write(packLeaf(z))
+		}
+		var buf bytes.Buffer
+		if err := writeTables(&buf, degPixels, sl, gzipBlob(packed.Bytes())); err != nil {
+			return nil, err
+		}
+		files[shardName(cell[0], cell[1], degrees)] = buf.Bytes()
+	}
+	return files, nil
+}
+
+// packLeaf returns z in the packed leaves' format, which readLeaves
+// reads.
+func packLeaf(z zoneLooker) []byte {
+	switch z := z.(type) {
+	case staticZone:
+		return append(append([]byte{'S'}, z...), 0)
+	case oneBitTile:
+		b := make([]byte, 13)
+		b[0] = '2'
+		binary.BigEndian.PutUint16(b[1:], z.idx[0])
+		binary.BigEndian.PutUint16(b[3:], z.idx[1])
+		var bits uint64
+		for y, row := range z.rows {
+			bits |= uint64(row) << uint(y*8)
+		}
+		binary.BigEndian.PutUint64(b[5:], bits)
+		return b
+	case pixmap:
+		return append([]byte{'P'}, z...)
+	}
+	panic(fmt.Sprintf("unknown leaf type %T", z))
+}
+
+// gzipBlob returns b gzip-compressed.
+func gzipBlob(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestShardFile(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		degrees   int
+		want      string
+	}{
+		{40.7128, -74.0060, 40, "N50W100.tables"},
+		{90, -180, 40, "N90W180.tables"},
+		{-90, 179.9, 40, "S70E140.tables"},
+		{-90, 0, 30, "S60E000.tables"}, // the south pole is in the last row
+		{-33.9, 18.4, 30, "S30E000.tables"},
+		{10, 180, 30, "N30W180.tables"}, // wraps
+	}
+	for _, tt := range tests {
+		if got := ShardFile(tt.lat, tt.long, tt.degrees); got != tt.want {
+			t.Errorf("ShardFile(%v, %v, %d) = %q; want %q", tt.lat, tt.long, tt.degrees, got, tt.want)
+		}
+	}
+}
+
+func TestLoadShard(t *testing.T) {
+	levels, leaves := compiledTables(t)
+	full, err := NewLookuper(degPixels, levels, leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const degrees = 40
+	shards, err := splitShards(degPixels, levels, leaves, degrees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) < 20 {
+		t.Fatalf("got %d shards; want most of the 45 cells", len(shards))
+	}
+
+	nyc := ShardFile(40.7128, -74.0060, degrees)
+	l, err := ReadLookuper(bytes.NewReader(shards[nyc]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coords := append(trackCoords(500), randomCoords(2000)...)
+	for _, c := range coords {
+		want := ""
+		if ShardFile(c[0], c[1], degrees) == nyc {
+			want = full.LookupName(c[0], c[1])
+		}
+		if got := l.LookupName(c[0], c[1]); got != want {
+			t.Errorf("with only shard %s, LookupName(%v, %v) = %q; want %q", nyc, c[0], c[1], got, want)
+		}
+	}
+
+	if shards["N10E060.tables"] == nil {
+		t.Fatal("no shard for India")
+	}
+	for name, b := range shards {
+		if name == nyc {
+			continue
+		}
+		if name == "N10E060.tables" {
+			// Test adding shards to released tables too.
+			l.Release()
+		}
+		if err := l.LoadShard(bytes.NewReader(b)); err != nil {
+			t.Fatalf("LoadShard(%s): %v", name, err)
+		}
+	}
+	for _, c := range coords {
+		if got, want := l.LookupName(c[0], c[1]), full.LookupName(c[0], c[1]); got != want {
+			t.Errorf("with all shards, LookupName(%v, %v) = %q; want %q", c[0], c[1], got, want)
+		}
+	}
+	if err := l.Validate(); err != nil {
+		t.Error(err)
+	}
+	if got, want := l.TileCounts(), full.TileCounts(); got != want {
+		t.Errorf("with all shards, TileCounts = %v; want %v", got, want)
+	}
+
+	var other bytes.Buffer
+	if err := writeTables(&other, degPixels*2, levels, leaves); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.LoadShard(&other); err == nil || !strings.Contains(err.Error(), "pixels per degree") {
+		t.Errorf("LoadShard of other scale: err = %v; want pixels per degree error", err)
+	}
+	if err := l.LoadShard(bytes.NewReader(shards[nyc][:100])); err == nil {
+		t.Error("LoadShard of truncated shard succeeded")
+	}
+	if _, err := splitShards(degPixels, levels, leaves, 7); err == nil {
+		t.Error("splitShards into 7 degree cells succeeded")
+	}
+}
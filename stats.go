@@ -0,0 +1,533 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LevelStats describes one zoom level of a Lookuper's tables.
+type LevelStats struct {
+	TileSize int // width and height of the level's tiles, in pixels
+	Tiles    int // number of tiles
+	Solid    int // number of those tiles entirely in one region
+	Bytes    int // decompressed size of the level's tile index, summed over any shards
+}
+
+// Stats returns statistics about the compiled-in timezone tables.
+// See Lookuper.Stats.
+func Stats() [6]LevelStats {
+	return defaultLookuper().Stats()
+}
+
+// Stats returns statistics about each of l's zoom levels, indexed by
+// size: index 0 has the smallest (8 pixel) tiles and index 5 the
+// largest (256 pixel). It unpacks the tables if needed but otherwise
+// doesn't modify l.
+func (l *Lookuper) Stats() [6]LevelStats {
+	var st [6]LevelStats
+	for i := range st {
+		st[i].TileSize = 8 << uint(i)
+	}
+	if l.degPixels == -1 {
+		return st
+	}
+	t := l.mustLoad()
+	for i, zl := range t.levels {
+		if i >= len(st) {
+			break // sizes past 256 pixels aren't reported
+		}
+		st[i].Tiles = len(zl.keys)
+		st[i].Bytes = zl.indexBytes
+		for _, idx := range zl.idxs {
+			if _, ok := t.leaf[idx].(staticZone); ok {
+				st[i].Solid++
+			}
+		}
+	}
+	return st
+}
+
+// TileCounts returns the number of tiles of each size in the
+// compiled-in timezone tables. See Lookuper.TileCounts.
+func TileCounts() [6]int {
+	return defaultLookuper().TileCounts()
+}
+
+// TileCounts returns the number of tiles of each of l's sizes, indexed
+// like Stats, for estimating the memory its tables use once unpacked.
+// It's the Tiles of each of Stats' levels, but cheaper: the counts are
+// kept with the unpacked tables, which it unpacks if needed.
+func (l *Lookuper) TileCounts() [6]int {
+	var n [6]int
+	if l.degPixels == -1 {
+		return n
+	}
+	t := l.mustLoad()
+	for i, zl := range t.levels {
+		if i >= len(n) {
+			break // as in Stats
+		}
+		n[i] = len(zl.keys)
+	}
+	return n
+}
+
+// ForEachTile calls fn for each solid tile of the compiled-in
+// timezone tables. See Lookuper.ForEachTile.
+func ForEachTile(fn func(size uint8, x, y uint16, zone string)) {
+	defaultLookuper().ForEachTile(fn)
+}
+
+// ForEachTile calls fn for each of l's solid tiles: those entirely in
+// one region, zone. The tile is 8<<size pixels square, and x and y
+// are its position in tiles of that size from the top left of the
+// map, so its top left pixel is (x<<(size+3), y<<(size+3)). Tiles
+// that mix regions, which are all 8 pixels square, are skipped.
+//
+// Tiles are visited in order of size, smallest first, and then in
+// order of their position: by row, then column. ForEachTile unpacks
+// the tables if needed and is safe to call concurrently with lookups.
+func (l *Lookuper) ForEachTile(fn func(size uint8, x, y uint16, zone string)) {
+	if l.degPixels == -1 {
+		return
+	}
+	t := l.mustLoad()
+	for i, zl := range t.levels {
+		for j, idx := range zl.idxs {
+			if z, ok := t.leaf[idx].(staticZone); ok {
+				tk := zl.keys[j]
+				fn(uint8(i), tk.x(), tk.y(), string(z))
+			}
+		}
+	}
+}
+
+// ZonesInBBox returns the sorted, distinct timezones of the compiled-in
+// tables within the given box. See Lookuper.ZonesInBBox.
+func ZonesInBBox(minLat, minLong, maxLat, maxLong float64) []string {
+	return defaultLookuper().ZonesInBBox(minLat, minLong, maxLat, maxLong)
+}
+
+// ZonesInBBox returns the sorted, distinct names of l's regions at any
+// pixel within the given box, such as for a map legend. Rather than
+// sampling coordinates, it scans every tile overlapping the box, so
+// it doesn't miss small enclaves. A box whose minLong is greater than
+// its maxLong crosses the antimeridian. Coordinates are clamped to
+// the map.
+//
+// Only the tables are consulted, not overrides or the Antarctic
+// fallback. It returns nil for an empty box.
+func (l *Lookuper) ZonesInBBox(minLat, minLong, maxLat, maxLong float64) []string {
+	if l.degPixels == -1 || !(minLat <= maxLat) || math.IsNaN(minLong) || math.IsNaN(maxLong) {
+		return nil
+	}
+	w, h := 360*l.degPixels, 180*l.degPixels
+	pixel := func(v float64, n int) int {
+		p := int(v * float64(l.degPixels))
+		if p < 0 {
+			return 0
+		} else if p >= n {
+			return n - 1
+		}
+		return p
+	}
+	y0, y1 := pixel(90-maxLat, h), pixel(90-minLat, h)
+	x0, x1 := pixel(minLong+180, w), pixel(maxLong+180, w)
+	type span struct{ x0, x1 int }
+	spans := []span{{x0, x1}}
+	if minLong > maxLong {
+		spans = []span{{x0, w - 1}, {0, x1}}
+	}
+
+	t := l.mustLoad()
+	seen := map[string]bool{}
+	for level := range t.levels {
+		zl := &t.levels[level]
+		shift := uint(level) + 3
+		for yt := y0 >> shift; yt <= y1>>shift; yt++ {
+			for _, sp := range spans {
+				first := newTileKey(uint8(level), uint16(sp.x0>>shift), uint16(yt))
+				last := newTileKey(uint8(level), uint16(sp.x1>>shift), uint16(yt))
+				i := sort.Search(len(zl.keys), func(i int) bool { return zl.keys[i] >= first })
+				for ; i < len(zl.keys) && zl.keys[i] <= last; i++ {
+					tk := zl.keys[i]
+					z := t.leaf[zl.idxs[i]]
+					if name, ok := z.(staticZone); ok {
+						seen[string(name)] = true
+						continue
+					}
+					// Check just the tile's pixels in the box.
+					tx0, ty0 := int(tk.x())<<shift, int(tk.y())<<shift
+					tx1, ty1 := tx0+1<<shift-1, ty0+1<<shift-1
+					if tx0 < sp.x0 {
+						tx0 = sp.x0
+					}
+					if tx1 > sp.x1 {
+						tx1 = sp.x1
+					}
+					if ty0 < y0 {
+						ty0 = y0
+					}
+					if ty1 > y1 {
+						ty1 = y1
+					}
+					for y := ty0; y <= ty1; y++ {
+						for x := tx0; x <= tx1; x++ {
+							if name, _ := z.LookupZone(t.leaf, x, y, tk); name != "" {
+								seen[name] = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ZoneBounds returns the box enclosing the solid tiles of zone in the
+// compiled-in timezone tables. See Lookuper.ZoneBounds.
+func ZoneBounds(zone string) (minLat, minLong, maxLat, maxLong float64, ok bool) {
+	return defaultLookuper().ZoneBounds(zone)
+}
+
+// ZoneBounds returns the latitude and longitude box enclosing all of
+// l's solid tiles (see ForEachTile) of the region zone, such as for
+// centering a map on it. The box is to tile granularity, so it may
+// extend a little past the region, and it leaves out the region's
+// pixels in tiles it shares with others. A region spanning the
+// antimeridian gets a box spanning the whole map's width.
+//
+// ok is false if zone has no solid tiles, such as for an unknown zone
+// or one too small to fill any tile.
+func (l *Lookuper) ZoneBounds(zone string) (minLat, minLong, maxLat, maxLong float64, ok bool) {
+	x0, y0, x1, y1 := -1, -1, -1, -1
+	l.ForEachTile(func(size uint8, x, y uint16, z string) {
+		if z != zone {
+			return
+		}
+		shift := size + 3
+		tx0, ty0 := int(x)<<shift, int(y)<<shift
+		tx1, ty1 := tx0+8<<size, ty0+8<<size
+		if x0 == -1 {
+			x0, y0, x1, y1 = tx0, ty0, tx1, ty1
+			return
+		}
+		if tx0 < x0 {
+			x0 = tx0
+		}
+		if ty0 < y0 {
+			y0 = ty0
+		}
+		if tx1 > x1 {
+			x1 = tx1
+		}
+		if ty1 > y1 {
+			y1 = ty1
+		}
+	})
+	if x0 == -1 {
+		return 0, 0, 0, 0, false
+	}
+	if w := 360 * l.degPixels; x1 > w {
+		x1 = w
+	}
+	if h := 180 * l.degPixels; y1 > h {
+		y1 = h
+	}
+	deg := float64(l.degPixels)
+	return 90 - float64(y1)/deg, float64(x0)/deg - 180, 90 - float64(y0)/deg, float64(x1)/deg - 180, true
+}
+
+// ContainsTile reports whether any tile of the compiled-in timezone
+// tables covers the given latitude and longitude. See
+// Lookuper.ContainsTile.
+func ContainsTile(lat, long float64) bool {
+	return defaultLookuper().ContainsTile(lat, long)
+}
+
+// ContainsTile reports whether any of l's tiles covers the given
+// latitude and longitude, regardless of what it resolves to. A
+// coordinate where LookupName returns the empty string but which is
+// covered by a tile lies in a region the tables mark as having no
+// name, such as the ocean beside a coastline. One with no tile at all
+// is outside the generated data altogether.
+func (l *Lookuper) ContainsTile(lat, long float64) bool {
+	if l.degPixels == -1 {
+		return false
+	}
+	zl, _ := l.mustLoad().lookupLeaf(l.pixelOf(lat, long))
+	return zl != nil
+}
+
+// A TileKey identifies one tile of a Lookuper's tables.
+type TileKey struct {
+	Size int // width and height of the tile, in pixels: 8 to 256
+	X, Y int // position of the tile, in tiles of this size, from the top left
+}
+
+// LookupTile is like LookupZoneName but also returns the tile of the
+// compiled-in timezone tables that answered the lookup. See
+// Lookuper.LookupTile.
+func LookupTile(lat, long float64) (tk TileKey, zone string, ok bool) {
+	return defaultLookuper().LookupTile(lat, long)
+}
+
+// LookupTile is like LookupName but also returns the key of the tile
+// that answered the lookup. The ok result reports whether that whole
+// tile resolves to zone, in which case callers may memoize zone for
+// any coordinate in tk rather than per coordinate. If no tile covers
+// the coordinate, or the zone is from an override or the Antarctic
+// fallback rather than a tile, tk is the zero TileKey and ok is false.
+// Like LookupName, LookupTile applies l's overrides, so ok is also
+// false for tiles that an override may cover part of.
+func (l *Lookuper) LookupTile(lat, long float64) (tk TileKey, zone string, ok bool) {
+	if l.degPixels == -1 {
+		return TileKey{}, l.LookupName(lat, long), false
+	}
+	if zone, ok := l.override(lat, long); ok {
+		return TileKey{}, zone, false
+	}
+	t := l.mustLoad()
+	x, y := l.pixelOf(lat, long)
+	if zl, k := t.lookupLeaf(x, y); zl != nil {
+		zone, _ = zl.LookupZone(t.leaf, x, y, k)
+		_, ok = zl.(staticZone)
+		ok = ok && !l.overridesTile(k)
+		tk = TileKey{Size: 8 << k.size(), X: int(k.x()), Y: int(k.y())}
+	}
+	if zone == "" && l.fallback != nil {
+		if fz := l.fallback(lat, long); fz != "" {
+			return TileKey{}, fz, false
+		}
+	}
+	return tk, zone, ok
+}
+
+// ZonesForTiles returns the zones of the given tiles of the compiled-in
+// timezone tables. See Lookuper.ZonesForTiles.
+func ZonesForTiles(keys []TileKey) map[TileKey]string {
+	return defaultLookuper().ZonesForTiles(keys)
+}
+
+// ZonesForTiles resolves many of l's tiles at once, such as those
+// found with LookupTile or ForEachTile, for drawing maps a tile at a
+// time. The returned map has the zone of each of keys that's a tile of
+// l's entirely in one zone. Tiles l doesn't have, including any of
+// sizes it doesn't use, and those of more than one zone are omitted.
+// Like LookupTile, it ignores overrides and the Antarctic fallback.
+func (l *Lookuper) ZonesForTiles(keys []TileKey) map[TileKey]string {
+	m := make(map[TileKey]string)
+	if l.degPixels == -1 {
+		return m
+	}
+	t := l.mustLoad()
+	for _, tk := range keys {
+		level := -1
+		for i := range t.levels {
+			if tk.Size == 8<<uint(i) {
+				level = i
+			}
+		}
+		if level == -1 || tk.X < 0 || tk.Y < 0 || tk.X >= 360*l.degPixels/tk.Size || tk.Y >= 180*l.degPixels/tk.Size {
+			continue
+		}
+		idx, ok := t.levels[level].index(newTileKey(uint8(level), uint16(tk.X), uint16(tk.Y)))
+		if !ok {
+			continue
+		}
+		if z, ok := t.leaf[idx].(staticZone); ok && z != "" {
+			m[tk] = string(z)
+		}
+	}
+	return m
+}
+
+// SolidTileSize returns the largest tile of the compiled-in timezone
+// tables that's entirely one zone and covers the given latitude and
+// longitude. See Lookuper.SolidTileSize.
+func SolidTileSize(lat, long float64) (sizeShift uint8, zone string, ok bool) {
+	return defaultLookuper().SolidTileSize(lat, long)
+}
+
+// SolidTileSize returns the size of the largest of l's tiles that's
+// entirely one region, zone, and covers the given latitude and
+// longitude, for choosing how coarsely to cache lookups: the tile is
+// 8<<sizeShift pixels square. Points deep inside a large region are
+// in large tiles, and points near a border in small ones, or in none,
+// in which case ok is false. Like LookupTile, it ignores overrides
+// and the Antarctic fallback.
+func (l *Lookuper) SolidTileSize(lat, long float64) (sizeShift uint8, zone string, ok bool) {
+	if l.degPixels == -1 {
+		return 0, "", false
+	}
+	t := l.mustLoad()
+	x, y := l.pixelOf(lat, long)
+	for level := len(t.levels) - 1; level >= 0; level-- {
+		idx, found := t.levels[level].index(pixelTileKey(uint8(level), x, y))
+		if !found {
+			continue
+		}
+		if z, solid := t.leaf[idx].(staticZone); solid {
+			return uint8(level), string(z), true
+		}
+	}
+	return 0, "", false
+}
+
+// SnapToTile returns the center of the tile of the compiled-in
+// timezone tables containing the given latitude and longitude. See
+// Lookuper.SnapToTile.
+func SnapToTile(lat, long float64, sizeShift uint8) (centerLat, centerLong float64) {
+	return defaultLookuper().SnapToTile(lat, long, sizeShift)
+}
+
+// SnapToTile coarsens a coordinate to the center of the 8<<sizeShift
+// pixel square tile containing it, using the same mapping from
+// coordinates to pixels as lookups, for storing locations only to
+// tile granularity. sizeShift is at most 5, as for tile sizes in l's
+// tables, and larger values are treated as 5. The coordinate is first
+// clamped to the map, as for lookups. Tiles in the bottom row may
+// extend past the south pole, in which case the center is of the part
+// on the map.
+//
+// Away from borders, the center resolves to the same zone as the
+// coordinate. If l has no tables, both results are NaN.
+func (l *Lookuper) SnapToTile(lat, long float64, sizeShift uint8) (centerLat, centerLong float64) {
+	if l.degPixels == -1 {
+		return math.NaN(), math.NaN()
+	}
+	if sizeShift > 5 {
+		sizeShift = 5
+	}
+	size := 8 << sizeShift
+	x, y := l.pixelOf(lat, long)
+	x0, y0 := x&^(size-1), y&^(size-1)
+	x1, y1 := x0+size, y0+size
+	if w := 360 * l.degPixels; x1 > w {
+		x1 = w
+	}
+	if h := 180 * l.degPixels; y1 > h {
+		y1 = h
+	}
+	deg := float64(l.degPixels)
+	centerLong = float64(x0+x1)/2/deg - 180
+	centerLat = 90 - float64(y0+y1)/2/deg
+	return centerLat, centerLong
+}
+
+// DebugLookup returns a human-readable trace of how the compiled-in
+// timezone tables resolve the given latitude and longitude. See
+// Lookuper.DebugLookup.
+func DebugLookup(lat, long float64) string {
+	return defaultLookuper().DebugLookup(lat, long)
+}
+
+// DebugLookup returns a human-readable trace of how l resolves the
+// given latitude and longitude, for diagnosing surprising results: the
+// pixel it maps to, any override containing it, and, for each tile
+// size in the order lookups probe them, the tile containing the pixel,
+// whether l has it, and what its leaf resolves the pixel to. It's
+// meant for debugging and is much slower than a lookup.
+func (l *Lookuper) DebugLookup(lat, long float64) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "lat %v, long %v", lat, long)
+	if l.degPixels == -1 {
+		buf.WriteString(": tables not generated\n")
+		return buf.String()
+	}
+	t, err := l.load()
+	if err != nil {
+		fmt.Fprintf(&buf, ": %v\n", err)
+		return buf.String()
+	}
+	x, y := l.pixelOf(lat, long)
+	fmt.Fprintf(&buf, ": pixel (%d, %d) at %d pixels per degree\n", x, y, l.degPixels)
+	if zone, ok := l.override(lat, long); ok {
+		fmt.Fprintf(&buf, "override: %q\n", zone)
+	}
+
+	// The order lookupLeaf probes l's levels, of which tables
+	// generated with --max_tile_size have fewer than six.
+	order := make([]int, len(t.levels))
+	for i := range order {
+		order[i] = len(order) - 1 - i
+		if t.overlap {
+			order[i] = i
+		}
+	}
+	found := false
+	for _, level := range order {
+		tk := pixelTileKey(uint8(level), x, y)
+		fmt.Fprintf(&buf, "size %d (%d px): tile (%d, %d), key %08x: ", level, 8<<uint(level), tk.x(), tk.y(), uint32(tk))
+		idx, ok := t.levels[level].index(tk)
+		if !ok {
+			buf.WriteString("absent\n")
+			continue
+		}
+		zone, _ := t.leaf[idx].LookupZone(t.leaf, x, y, tk)
+		fmt.Fprintf(&buf, "leaf %d, %s, resolves to %q", idx, t.describeLeaf(t.leaf[idx]), zone)
+		if found {
+			buf.WriteString(" (shadowed)")
+		}
+		buf.WriteString("\n")
+		found = true
+	}
+	fmt.Fprintf(&buf, "result: %q\n", l.LookupName(lat, long))
+	return buf.String()
+}
+
+// describeLeaf returns a short description of z for DebugLookup.
+func (t *unpackedTables) describeLeaf(z zoneLooker) string {
+	name := func(idx uint16) string {
+		if idx == oceanIndex {
+			return `""`
+		}
+		if z, ok := t.leaf[idx].(staticZone); ok {
+			return fmt.Sprintf("%q", string(z))
+		}
+		return fmt.Sprintf("leaf %d", idx)
+	}
+	switch z := z.(type) {
+	case staticZone:
+		return "solid"
+	case oneBitTile:
+		return fmt.Sprintf("2-zone bitmap of %s and %s", name(z.idx[0]), name(z.idx[1]))
+	case pixmap:
+		seen := map[uint16]bool{}
+		var names []string
+		for i := 0; i < len(z); i += 2 {
+			idx := uint16(z[i])<<8 | uint16(z[i+1])
+			if !seen[idx] {
+				seen[idx] = true
+				names = append(names, name(idx))
+			}
+		}
+		return "pixmap of " + strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%T", z)
+}
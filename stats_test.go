@@ -0,0 +1,381 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	st := Stats()
+	total := 0
+	for i, ls := range st {
+		if want := 8 << uint(i); ls.TileSize != want {
+			t.Errorf("level %d: TileSize = %d; want %d", i, ls.TileSize, want)
+		}
+		if ls.Solid > ls.Tiles {
+			t.Errorf("level %d: %d solid tiles > %d tiles", i, ls.Solid, ls.Tiles)
+		}
+		if i > 0 && ls.Solid != ls.Tiles {
+			t.Errorf("level %d: only %d of %d tiles solid; only the smallest tiles have bitmaps", i, ls.Solid, ls.Tiles)
+		}
+		if ls.Bytes != ls.Tiles*6 {
+			t.Errorf("level %d: Bytes = %d; want %d", i, ls.Bytes, ls.Tiles*6)
+		}
+		total += ls.Tiles
+	}
+	if total == 0 {
+		t.Error("no tiles")
+	}
+	if st != Stats() {
+		t.Error("Stats changed between calls")
+	}
+}
+
+func TestTileCounts(t *testing.T) {
+	n := TileCounts()
+	sum := 0
+	for i, st := range Stats() {
+		if n[i] != st.Tiles {
+			t.Errorf("level %d: TileCounts = %d; Stats has %d tiles", i, n[i], st.Tiles)
+		}
+		sum += n[i]
+	}
+	if sum <= 0 {
+		t.Errorf("TileCounts sum = %d; want positive", sum)
+	}
+	if TileCounts() != n {
+		t.Error("TileCounts changed between calls")
+	}
+}
+
+func TestContainsTile(t *testing.T) {
+	cases := []struct {
+		lat, long float64
+		want      bool
+	}{
+		{40.7128, -74.0060, true}, // New York
+		{0, -140, false},          // mid-Pacific
+	}
+	for _, tt := range cases {
+		if got := ContainsTile(tt.lat, tt.long); got != tt.want {
+			t.Errorf("ContainsTile(%v, %v) = %v; want %v", tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+func TestLookupTile(t *testing.T) {
+	// Central Nebraska, near the Chicago/Denver border, is in a small
+	// tile; the Brazilian interior is in a large solid one.
+	tk, zone, ok := LookupTile(41.609, -101.4219)
+	if zone != "America/Denver" || tk.Size != 8 || ok {
+		t.Errorf("Nebraska: got %+v, %q, %v; want an 8 pixel, mixed America/Denver tile", tk, zone, ok)
+	}
+	tk, zone, ok = LookupTile(-10, -55)
+	if zone != "America/Cuiaba" || tk.Size != 128 || !ok {
+		t.Errorf("Brazil: got %+v, %q, %v; want a 128 pixel, solid America/Cuiaba tile", tk, zone, ok)
+	}
+	// Every coordinate in a solid tile should resolve the same way.
+	x0 := float64(tk.X*tk.Size)/32 - 180
+	y0 := 90 - float64(tk.Y*tk.Size)/32
+	deg := float64(tk.Size) / 32
+	for _, f := range []float64{0.01, 0.5, 0.99} {
+		lat, long := y0-f*deg, x0+f*deg
+		if got, _, _ := LookupTile(lat, long); got != tk {
+			t.Errorf("(%v, %v) in tile %+v; want %+v", lat, long, got, tk)
+		}
+		if got := LookupZoneName(lat, long); got != zone {
+			t.Errorf("LookupZoneName(%v, %v) = %q; want %q", lat, long, got, zone)
+		}
+	}
+	if tk, zone, ok := LookupTile(0, -30); tk != (TileKey{}) || zone != "" || ok {
+		t.Errorf("ocean: got %+v, %q, %v; want nothing", tk, zone, ok)
+	}
+	if tk, zone, ok := LookupTile(-80, 0); tk != (TileKey{}) || zone != "Antarctica/Troll" || ok {
+		t.Errorf("Antarctica: got %+v, %q, %v; want the fallback's zone and no tile", tk, zone, ok)
+	}
+}
+
+func TestZonesForTiles(t *testing.T) {
+	brazil, _, _ := LookupTile(-10, -55)            // solid America/Cuiaba
+	nebraska, _, _ := LookupTile(41.609, -101.4219) // mixed
+	keys := []TileKey{
+		brazil,
+		nebraska,
+		{Size: 128, X: brazil.X + 1000, Y: brazil.Y}, // off the map
+		{Size: 7, X: 1, Y: 1},                        // no such size
+		{Size: 256, X: 0, Y: 0},                      // ocean
+	}
+	solid := map[TileKey]string{}
+	ForEachTile(func(size uint8, x, y uint16, zone string) {
+		if len(solid) < 100 {
+			tk := TileKey{Size: 8 << size, X: int(x), Y: int(y)}
+			keys = append(keys, tk)
+			solid[tk] = zone
+		}
+	})
+	got := ZonesForTiles(keys)
+	if got[brazil] != "America/Cuiaba" {
+		t.Errorf("Brazil tile %+v = %q; want America/Cuiaba", brazil, got[brazil])
+	}
+	for tk, zone := range solid {
+		if got[tk] != zone {
+			t.Errorf("solid tile %+v = %q; want %q", tk, got[tk], zone)
+		}
+	}
+	for _, tk := range keys[1:5] {
+		if zone, ok := got[tk]; ok {
+			t.Errorf("tile %+v = %q; want it omitted", tk, zone)
+		}
+	}
+}
+
+func TestForEachTile(t *testing.T) {
+	st := Stats()
+	var n [6]int
+	lastSize, lastX, lastY := -1, -1, -1
+	ForEachTile(func(size uint8, x, y uint16, zone string) {
+		n[size]++
+		switch {
+		case int(size) < lastSize:
+			t.Fatalf("size %d after %d", size, lastSize)
+		case int(size) == lastSize && (int(y) < lastY || int(y) == lastY && int(x) <= lastX):
+			t.Fatalf("size %d: tile (%d, %d) after (%d, %d)", size, x, y, lastX, lastY)
+		}
+		lastSize, lastX, lastY = int(size), int(x), int(y)
+
+		// Check a pixel in the middle of the tile.
+		half := 4 << size
+		px, py := int(x)<<(size+3)+half, int(y)<<(size+3)+half
+		if got := lookupPixel(px, py); got != zone {
+			t.Fatalf("tile %d (%d, %d) is %q, but its pixel (%d, %d) is %q", size, x, y, zone, px, py, got)
+		}
+	})
+	for i := range n {
+		if n[i] != st[i].Solid {
+			t.Errorf("size %d: visited %d tiles; want %d", i, n[i], st[i].Solid)
+		}
+	}
+}
+
+func TestDebugLookup(t *testing.T) {
+	got := DebugLookup(41.609, -101.4219) // Nebraska, on a 2-zone tile
+	for _, want := range []string{
+		"size 5 (256 px)",
+		"absent",
+		`2-zone bitmap of "America/Denver" and "America/Chicago", resolves to "America/Denver"`,
+		`result: "America/Denver"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("DebugLookup missing %q; got:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "\nsize "); n != 6 {
+		t.Errorf("got %d sizes; want 6:\n%s", n, got)
+	}
+}
+
+// Tests tables with fewer than six levels, as generated with
+// --max_tile_size.
+func TestFewerLevels(t *testing.T) {
+	if degPixels == -1 {
+		t.Skip("data not generated yet")
+	}
+	levels, leaves := compiledTables(t)
+	l := &Lookuper{
+		degPixels: degPixels,
+		levelData: levelReaders(levels[:3]),
+		leafData:  bytesReader(leaves),
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	st, full := l.Stats(), Stats()
+	n := l.TileCounts()
+	for i := range st {
+		want := full[i]
+		if i >= 3 {
+			want.Tiles, want.Solid, want.Bytes = 0, 0, 0
+		}
+		if st[i] != want {
+			t.Errorf("level %d: Stats = %+v; want %+v", i, st[i], want)
+		}
+		if n[i] != want.Tiles {
+			t.Errorf("level %d: TileCounts = %d; want %d", i, n[i], want.Tiles)
+		}
+	}
+	for _, c := range [][2]float64{
+		{41.609, -101.4219}, // Nebraska, on a 2-zone tile
+		{-10, -55},          // central Brazil, in a big tile of the full tables
+	} {
+		got := l.DebugLookup(c[0], c[1])
+		if n := strings.Count(got, "\nsize "); n != 3 {
+			t.Errorf("DebugLookup(%v, %v): got %d sizes; want 3:\n%s", c[0], c[1], n, got)
+		}
+		if want := fmt.Sprintf("result: %q", l.LookupName(c[0], c[1])); !strings.Contains(got, want) {
+			t.Errorf("DebugLookup(%v, %v) missing %q; got:\n%s", c[0], c[1], want, got)
+		}
+	}
+	if got := l.LookupName(41.609, -101.4219); got != "America/Denver" {
+		t.Errorf("LookupName in Nebraska = %q; want America/Denver", got)
+	}
+	if m := l.ZonesForTiles([]TileKey{{Size: 256}}); len(m) != 0 {
+		t.Errorf("ZonesForTiles of a 256 pixel tile = %v; want none", m)
+	}
+	tiles := 0
+	l.ForEachTile(func(size uint8, x, y uint16, zone string) {
+		if size > 2 {
+			t.Fatalf("ForEachTile visited a tile of size %d", size)
+		}
+		tiles++
+	})
+	if want := st[1].Solid + st[2].Solid + st[0].Solid; tiles != want {
+		t.Errorf("ForEachTile visited %d tiles; want %d", tiles, want)
+	}
+}
+
+func TestSnapToTile(t *testing.T) {
+	cities := [][2]float64{
+		{40.7128, -74.0060},  // New York
+		{48.8566, 2.3522},    // Paris
+		{35.6762, 139.6503},  // Tokyo
+		{-33.8688, 151.2093}, // Sydney
+		{-15.7939, -47.8828}, // Brasília
+		{55.7558, 37.6173},   // Moscow
+	}
+	for _, c := range cities {
+		want := LookupZoneName(c[0], c[1])
+		for shift := uint8(0); shift <= 2; shift++ {
+			lat, long := SnapToTile(c[0], c[1], shift)
+			if got := LookupZoneName(lat, long); got != want {
+				t.Errorf("SnapToTile(%v, %v, %d) = (%v, %v) in %q; want in %q", c[0], c[1], shift, lat, long, got, want)
+			}
+			if lat2, long2 := SnapToTile(lat, long, shift); lat2 != lat || long2 != long {
+				t.Errorf("SnapToTile of center (%v, %v) = (%v, %v); want unchanged", lat, long, lat2, long2)
+			}
+		}
+	}
+
+	// At scale 32, 8 pixel tiles are a quarter degree.
+	if lat, long := SnapToTile(0.1, 0.1, 0); lat != 0.125 || long != 0.125 {
+		t.Errorf("SnapToTile(0.1, 0.1, 0) = (%v, %v); want (0.125, 0.125)", lat, long)
+	}
+	// 256 pixel tiles are 8 degrees, counting from (90, -180).
+	if lat, long := SnapToTile(0.1, 0.1, 9); lat != -2 || long != 0 {
+		t.Errorf("SnapToTile(0.1, 0.1, 9) = (%v, %v); want (-2, 0)", lat, long)
+	}
+	// Clamped and wrapped inputs; the bottom 256 pixel row is half a tile.
+	if lat, long := SnapToTile(-100, 540, 5); lat != -88 || long != -176 {
+		t.Errorf("SnapToTile(-100, 540, 5) = (%v, %v); want (-88, -176)", lat, long)
+	}
+}
+
+func TestZonesInBBox(t *testing.T) {
+	// Central Europe.
+	got := ZonesInBBox(45, 5, 52, 17)
+	for _, want := range []string{"Europe/Berlin", "Europe/Paris", "Europe/Prague", "Europe/Vienna", "Europe/Zurich", "Europe/Vaduz"} {
+		if i := sort.SearchStrings(got, want); i == len(got) || got[i] != want {
+			t.Errorf("ZonesInBBox(central Europe) lacks %q; got %q", want, got)
+		}
+	}
+	if !sort.StringsAreSorted(got) {
+		t.Errorf("ZonesInBBox not sorted: %q", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] == got[i-1] {
+			t.Errorf("ZonesInBBox has %q twice", got[i])
+		}
+	}
+	// Every zone found by sampling is included.
+	for lat := 45.0; lat <= 52; lat += 0.1 {
+		for long := 5.0; long <= 17; long += 0.1 {
+			z := LookupZoneName(lat, long)
+			if i := sort.SearchStrings(got, z); z != "" && (i == len(got) || got[i] != z) {
+				t.Errorf("ZonesInBBox lacks %q, at (%v, %v)", z, lat, long)
+			}
+		}
+	}
+
+	// Fiji, across the antimeridian.
+	got = ZonesInBBox(-19, 177, -16, -179)
+	if i := sort.SearchStrings(got, "Pacific/Fiji"); i == len(got) || got[i] != "Pacific/Fiji" {
+		t.Errorf("ZonesInBBox(Fiji) = %q; want Pacific/Fiji", got)
+	}
+	if got := ZonesInBBox(0, -140, 0.1, -139.9); len(got) != 0 {
+		t.Errorf("ZonesInBBox(ocean) = %q; want none", got)
+	}
+	if got := ZonesInBBox(10, 0, 5, 1); got != nil {
+		t.Errorf("ZonesInBBox(empty box) = %q; want nil", got)
+	}
+}
+
+func TestSolidTileSize(t *testing.T) {
+	// Deep inside Siberia, the largest tiles are solid.
+	if size, zone, ok := SolidTileSize(70, 120); !ok || size != 5 || zone != "Asia/Yakutsk" {
+		t.Errorf("SolidTileSize(Siberia) = %d, %q, %v; want 5, Asia/Yakutsk, true", size, zone, ok)
+	}
+	// The Nebraska panhandle's Mountain/Central border is in a mixed tile.
+	if size, zone, ok := SolidTileSize(41.609, -101.4219); ok {
+		t.Errorf("SolidTileSize(border) = %d, %q, true; want not ok", size, zone)
+	}
+	if _, _, ok := SolidTileSize(0, -140); ok {
+		t.Error("SolidTileSize(ocean) ok; want not ok")
+	}
+	// Whenever it's ok, the tile agrees with LookupTile.
+	for _, c := range [][2]float64{{40.7128, -74.0060}, {48.8566, 2.3522}, {35.6762, 139.6503}, {-15.7939, -47.8828}} {
+		size, zone, ok := SolidTileSize(c[0], c[1])
+		tk, tzone, tok := LookupTile(c[0], c[1])
+		if ok != tok || ok && (8<<size != tk.Size || zone != tzone) {
+			t.Errorf("at %v: SolidTileSize = %d, %q, %v; LookupTile = %+v, %q, %v", c, size, zone, ok, tk, tzone, tok)
+		}
+	}
+}
+
+func TestZoneBounds(t *testing.T) {
+	tests := []struct {
+		zone                             string
+		minLat, minLong, maxLat, maxLong float64 // box that must be inside
+	}{
+		{"Europe/Zurich", 46.5, 7, 47.5, 9.5},
+		{"Asia/Yakutsk", 55, 110, 72, 130},
+	}
+	for _, tt := range tests {
+		minLat, minLong, maxLat, maxLong, ok := ZoneBounds(tt.zone)
+		if !ok {
+			t.Errorf("ZoneBounds(%q) not ok", tt.zone)
+			continue
+		}
+		if minLat > tt.minLat || minLong > tt.minLong || maxLat < tt.maxLat || maxLong < tt.maxLong {
+			t.Errorf("ZoneBounds(%q) = (%v, %v) to (%v, %v); want to enclose (%v, %v) to (%v, %v)",
+				tt.zone, minLat, minLong, maxLat, maxLong, tt.minLat, tt.minLong, tt.maxLat, tt.maxLong)
+		}
+		// Not much bigger than the zone, either.
+		if maxLat-minLat > 2*(tt.maxLat-tt.minLat)+1 || maxLong-minLong > 2*(tt.maxLong-tt.minLong)+1 {
+			t.Errorf("ZoneBounds(%q) = (%v, %v) to (%v, %v); too big", tt.zone, minLat, minLong, maxLat, maxLong)
+		}
+		centerLat, centerLong := (minLat+maxLat)/2, (minLong+maxLong)/2
+		if got := LookupZoneName(centerLat, centerLong); got != tt.zone {
+			t.Errorf("center of %q box (%v, %v) is in %q", tt.zone, centerLat, centerLong, got)
+		}
+	}
+	if _, _, _, _, ok := ZoneBounds("Nowhere/Special"); ok {
+		t.Errorf("ZoneBounds of unknown zone is ok")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// streamWindow is how many input lines LookupStream buffers, at most,
+// and then resolves together.
+const streamWindow = 256
+
+// maxStreamLine is the longest input line LookupStream accepts.
+const maxStreamLine = 64 << 10
+
+type streamRequest struct {
+	Lat *float64 `json:"lat"`
+	Lng *float64 `json:"lng"`
+}
+
+type streamResponse struct {
+	Zone  *string `json:"zone,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+// A streamCoord is the index of a coordinate in LookupStream's window
+// and the key it's sorted by.
+type streamCoord struct {
+	key tileKey
+	i   int
+}
+
+// LookupStream reads newline-delimited JSON objects of the form
+//
+//	{"lat":40.7128,"lng":-74.0060}
+//
+// from r and writes one line to w for each of them, in order:
+//
+//	{"zone":"America/New_York"}
+//
+// The zone is empty if LookupZoneName would return the empty string.
+// A malformed line gets an object with an "error" field instead, and
+// the stream continues. Blank lines are skipped.
+//
+// Inputs are buffered and resolved in small windows, so memory use
+// doesn't grow with the size of the input. Each window is resolved
+// sorted by tile, so that, as with LookupZoneNames, nearby points reuse
+// their tile even if the input isn't in order. A window ends early,
+// and its results are written, whenever all the input read so far has
+// been handled, so interactive clients get each answer without waiting
+// for more input. The returned error is from reading r or writing w,
+// or reports an input line longer than 64 KB.
+func LookupStream(r io.Reader, w io.Writer) error {
+	l := defaultLookuper()
+	br := bufio.NewReaderSize(r, maxStreamLine)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	res := make([]streamResponse, 0, streamWindow)
+	coords := make([][2]float64, 0, streamWindow)
+	byTile := make([]streamCoord, 0, streamWindow)
+	sorted := make([][2]float64, 0, streamWindow)
+	zones := make([]string, streamWindow)
+	flush := func() error {
+		// Resolve the coordinates sorted by the biggest tile
+		// containing them, so runs of them in the same tile reuse
+		// it, then put the zones back in input order.
+		byTile = byTile[:0]
+		for i, c := range coords {
+			byTile = append(byTile, streamCoord{l.latLongToTileKey(maxSizeShift, c[0], c[1]), i})
+		}
+		sort.Slice(byTile, func(i, j int) bool { return byTile[i].key < byTile[j].key })
+		sorted = sorted[:0]
+		for _, c := range byTile {
+			sorted = append(sorted, coords[c.i])
+		}
+		for i, name := range l.LookupNames(sorted) {
+			zones[byTile[i].i] = name
+		}
+		n := 0
+		for i := range res {
+			if res[i].Error == "" {
+				res[i].Zone = &zones[n]
+				n++
+			}
+			if err := enc.Encode(res[i]); err != nil {
+				return err
+			}
+		}
+		res, coords = res[:0], coords[:0]
+		return bw.Flush()
+	}
+
+	for {
+		line, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			flush()
+			return errors.New("latlong: input line too long")
+		}
+		if err != nil && err != io.EOF {
+			flush()
+			return err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var req streamRequest
+			if err := json.Unmarshal(line, &req); err != nil {
+				res = append(res, streamResponse{Error: err.Error()})
+			} else if req.Lat == nil || req.Lng == nil {
+				res = append(res, streamResponse{Error: `missing "lat" or "lng"`})
+			} else {
+				res = append(res, streamResponse{})
+				coords = append(coords, [2]float64{*req.Lat, *req.Lng})
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		// Reading more would wait on r once nothing is buffered, so
+		// answer what has been read first.
+		if len(res) == streamWindow || len(res) > 0 && br.Buffered() == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
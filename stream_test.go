@@ -0,0 +1,180 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupStream(t *testing.T) {
+	in := `{"lat":40.7128,"lng":-74.0060}
+{"lat":37.7749,"lng":-122.4194}
+
+not json
+{"lat":0}
+{"lat":0,"lng":-30}
+`
+	var out bytes.Buffer
+	if err := LookupStream(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{
+		`{"zone":"America/New_York"}`,
+		`{"zone":"America/Los_Angeles"}`,
+		"error",
+		`{"error":"missing \"lat\" or \"lng\""}`,
+		`{"zone":""}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines; want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, line := range lines {
+		if want[i] == "error" {
+			var res struct{ Error string }
+			if err := json.Unmarshal([]byte(line), &res); err != nil || res.Error == "" {
+				t.Errorf("line %d = %s; want an error object", i, line)
+			}
+			continue
+		}
+		if line != want[i] {
+			t.Errorf("line %d = %s; want %s", i, line, want[i])
+		}
+	}
+}
+
+func TestLookupStreamWindows(t *testing.T) {
+	// Span several windows, with malformed lines throughout, and
+	// check each output line still matches its input.
+	coords := trackCoords(3*streamWindow + 7)
+	var in bytes.Buffer
+	for i, c := range coords {
+		if i%100 == 0 {
+			in.WriteString("{\n")
+		}
+		fmt.Fprintf(&in, "{\"lat\":%v,\"lng\":%v}\n", c[0], c[1])
+	}
+	var out bytes.Buffer
+	if err := LookupStream(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&out)
+	for i, c := range coords {
+		var res streamResponse
+		if i%100 == 0 {
+			if err := dec.Decode(&res); err != nil || res.Error == "" {
+				t.Fatalf("before coordinate %d: got %+v, %v; want an error", i, res, err)
+			}
+			res = streamResponse{}
+		}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatalf("coordinate %d: %v", i, err)
+		}
+		if want := LookupZoneName(c[0], c[1]); res.Zone == nil || *res.Zone != want {
+			t.Fatalf("coordinate %d %v: got %+v; want zone %q", i, c, res, want)
+		}
+	}
+	if dec.More() {
+		t.Error("extra output")
+	}
+}
+
+func TestLookupStreamScattered(t *testing.T) {
+	// Coordinates all over, which each window resolves out of order,
+	// must still come back in input order.
+	r := rand.New(rand.NewSource(1))
+	coords := make([][2]float64, 2*streamWindow+3)
+	var in bytes.Buffer
+	for i := range coords {
+		coords[i] = [2]float64{r.Float64()*180 - 90, r.Float64()*360 - 180}
+		fmt.Fprintf(&in, "{\"lat\":%v,\"lng\":%v}\n", coords[i][0], coords[i][1])
+	}
+	var out bytes.Buffer
+	if err := LookupStream(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&out)
+	for i, c := range coords {
+		var res streamResponse
+		if err := dec.Decode(&res); err != nil {
+			t.Fatalf("coordinate %d: %v", i, err)
+		}
+		if want := LookupZoneName(c[0], c[1]); res.Zone == nil || *res.Zone != want {
+			t.Fatalf("coordinate %d %v: got %+v; want zone %q", i, c, res, want)
+		}
+	}
+}
+
+func TestLookupStreamInteractive(t *testing.T) {
+	// A client sending a line at a time must get each answer before
+	// sending the next line.
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- LookupStream(inR, outW)
+		outW.Close()
+	}()
+	br := bufio.NewReader(outR)
+	for _, tt := range []struct {
+		line, want string
+	}{
+		{`{"lat":40.7128,"lng":-74.0060}`, `{"zone":"America/New_York"}`},
+		{`{"lat":`, ""}, // an error
+		{`{"lat":51.5074,"lng":-0.1278}`, `{"zone":"Europe/London"}`},
+	} {
+		if _, err := io.WriteString(inW, tt.line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		got := make(chan string, 1)
+		go func() {
+			line, _ := br.ReadString('\n')
+			got <- strings.TrimSpace(line)
+		}()
+		select {
+		case line := <-got:
+			if tt.want == "" && !strings.Contains(line, `"error"`) || tt.want != "" && line != tt.want {
+				t.Errorf("for %s: got %s; want %s", tt.line, line, tt.want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no answer for %s without more input", tt.line)
+		}
+	}
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookupStreamLongLine(t *testing.T) {
+	in := `{"lat":1,"lng":2}` + "\n" + strings.Repeat(" ", maxStreamLine+1) + "\n"
+	var out bytes.Buffer
+	if err := LookupStream(strings.NewReader(in), &out); err == nil {
+		t.Fatal("no error for long line")
+	}
+	if out.Len() == 0 {
+		t.Error("earlier lines weren't written")
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// The tables file format, as written by the generator's --tables_file
+// flag and read by ReadLookuper, is:
+//
+//	magic     [4]byte "LLtz"
+//	version   uint16, currently 1
+//	degPixels uint16
+//	then 7 blobs, each a uint32 length and that many bytes:
+//	          the gzip-compressed tile indexes of zoom levels 0 to 5,
+//	          then the gzip-compressed leaves
+//	crc       uint32, the IEEE CRC-32 of everything before it
+//
+// All integers are big-endian.
+const (
+	tablesMagic   = "LLtz"
+	tablesVersion = 1
+)
+
+// ReadLookuper returns a Lookuper for the tables read from r, which
+// must be in the format written by this package's generator with its
+// --tables_file flag. This lets programs ship timezone tables (or
+// tables for a region) separately from their binaries; the
+// package-level functions still use the compiled-in tables.
+//
+// It returns an error if the data isn't a tables file, is of an
+// unsupported version, or is corrupt.
+func ReadLookuper(r io.Reader) (*Lookuper, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	degPixels, levels, leaves, err := parseTables(b)
+	if err != nil {
+		return nil, err
+	}
+	return NewLookuper(degPixels, levels, leaves)
+}
+
+// OpenMapped is like ReadLookuper, but memory-maps the tables file at
+// path rather than reading it into the heap, on systems supporting it.
+// The tables are only unpacked from the mapping when first used, and
+// again after Release, so the compressed tables cost no heap memory and
+// the mapped pages may be reclaimed by the operating system between
+// uses.
+//
+// The whole file's checksum is verified before OpenMapped returns.
+// The file must not be modified or truncated while mapped, which can
+// crash the program; replace it by renaming a new file over it
+// instead. Close the Lookuper to unmap the file.
+func OpenMapped(path string) (*Lookuper, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size < int64(len(tablesMagic)+4+4) || int64(int(size)) != size {
+		return nil, errNotTables
+	}
+	b, unmap, err := mmapFile(f, int(size))
+	if err != nil {
+		return nil, err
+	}
+	degPixels, levels, leaves, err := parseTables(b)
+	if err == nil && degPixels == 0 {
+		err = errors.New("latlong: invalid degPixels 0")
+	}
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	l := &Lookuper{
+		degPixels: degPixels,
+		levelData: levelReaders(levels[:]),
+		leafData:  bytesReader(leaves),
+		unmap:     unmap,
+	}
+	return l, nil
+}
+
+var errClosed = errors.New("latlong: Lookuper is closed")
+
+// Close unmaps the tables file of a Lookuper returned by OpenMapped
+// and drops its unpacked tables. Lookups must not be started after
+// Close; they panic. For other Lookupers, Close does nothing. It
+// always returns nil after the first call.
+func (l *Lookuper) Close() error {
+	l.unpackMu.Lock()
+	defer l.unpackMu.Unlock()
+	if l.unmap == nil {
+		return nil
+	}
+	l.tables.Store((*unpackedTables)(nil))
+	l.unpackErr = errClosed
+	l.degraded = nil
+	err := l.unmap()
+	l.unmap = nil
+	return err
+}
+
+var errNotTables = errors.New("latlong: not a tables file")
+
+// parseTables parses a tables file. The returned blobs alias b.
+func parseTables(b []byte) (degPixels int, levels [6][]byte, leaves []byte, err error) {
+	if len(b) < len(tablesMagic)+4+4 || string(b[:len(tablesMagic)]) != tablesMagic {
+		return 0, levels, nil, errNotTables
+	}
+	body, sum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return 0, levels, nil, errors.New("latlong: tables file checksum mismatch")
+	}
+	p := body[len(tablesMagic):]
+	if v := binary.BigEndian.Uint16(p); v != tablesVersion {
+		return 0, levels, nil, fmt.Errorf("latlong: unsupported tables file version %d", v)
+	}
+	degPixels = int(binary.BigEndian.Uint16(p[2:]))
+	p = p[4:]
+	blob := func() ([]byte, error) {
+		if len(p) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		n := binary.BigEndian.Uint32(p)
+		p = p[4:]
+		if uint64(n) > uint64(len(p)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := p[:n:n]
+		p = p[n:]
+		return v, nil
+	}
+	for i := range levels {
+		if levels[i], err = blob(); err != nil {
+			return 0, levels, nil, fmt.Errorf("latlong: tables file zoom level %d: %v", i, err)
+		}
+	}
+	if leaves, err = blob(); err != nil {
+		return 0, levels, nil, fmt.Errorf("latlong: tables file leaves: %v", err)
+	}
+	if len(p) != 0 {
+		return 0, levels, nil, errors.New("latlong: trailing data in tables file")
+	}
+	return degPixels, levels, leaves, nil
+}
+
+// writeTables writes tables in the format ReadLookuper reads.
+func writeTables(w io.Writer, degPixels int, levels [6][]byte, leaves []byte) error {
+	if degPixels <= 0 || degPixels > 0xffff {
+		return fmt.Errorf("latlong: invalid degPixels %d", degPixels)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(tablesMagic)
+	binary.Write(&buf, binary.BigEndian, uint16(tablesVersion))
+	binary.Write(&buf, binary.BigEndian, uint16(degPixels))
+	for _, b := range append(levels[:], leaves) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadLookuper(t *testing.T) {
+	levels, leaves := compiledTables(t)
+	var buf bytes.Buffer
+	if err := writeTables(&buf, degPixels, levels, leaves); err != nil {
+		t.Fatal(err)
+	}
+	l, err := ReadLookuper(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range trackCoords(1000) {
+		if got, want := l.LookupName(c[0], c[1]), LookupZoneName(c[0], c[1]); got != want {
+			t.Errorf("LookupName(%v, %v) = %q; want %q", c[0], c[1], got, want)
+		}
+	}
+}
+
+func TestReadLookuperErrors(t *testing.T) {
+	levels, leaves := compiledTables(t)
+	var buf bytes.Buffer
+	if err := writeTables(&buf, degPixels, levels, leaves); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	// mod returns a copy of good with f applied.
+	mod := func(f func([]byte) []byte) []byte {
+		return f(append([]byte(nil), good...))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "not a tables file"},
+		{"magic", mod(func(b []byte) []byte { b[0] = 'X'; return b }), "not a tables file"},
+		{"truncated", good[:len(good)/2], "checksum"},
+		{"corrupt", mod(func(b []byte) []byte { b[100] ^= 1; return b }), "checksum"},
+		{"version", withCRC(mod(func(b []byte) []byte { b[5] = 2; return b })), "version 2"},
+		{"trailing", withCRC(mod(func(b []byte) []byte { return append(b, 0, 0, 0, 0) })), "trailing"},
+	}
+	for _, tt := range tests {
+		_, err := ReadLookuper(bytes.NewReader(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v; want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// withCRC returns tables file b, whose last four bytes are a CRC,
+// with that CRC recomputed.
+func withCRC(b []byte) []byte {
+	body := b[:len(b)-4]
+	binary.BigEndian.PutUint32(b[len(body):], crc32.ChecksumIEEE(body))
+	return b
+}
+
+// Tests that Lookupers for two datasets, here the compiled-in tables
+// and a copy read from a tables file, are independent of each other
+// and of the package-level functions.
+func TestIndependentLookupers(t *testing.T) {
+	a, err := NewLookuperFromCompiled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels, leaves := compiledTables(t)
+	var buf bytes.Buffer
+	if err := writeTables(&buf, degPixels, levels, leaves); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadLookuper(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == defaultLookuper() {
+		t.Fatal("NewLookuperFromCompiled returned the package's Lookuper")
+	}
+
+	const lat, long = 48.8566, 2.3522 // Paris
+	a.Override("Test/Zone", [][2]float64{{48, 2}, {49, 2}, {49, 3}, {48, 3}})
+	if got := a.LookupName(lat, long); got != "Test/Zone" {
+		t.Errorf("a.LookupName = %q; want override", got)
+	}
+	if got := b.LookupName(lat, long); got != "Europe/Paris" {
+		t.Errorf("b.LookupName = %q; want Europe/Paris", got)
+	}
+	if got := LookupZoneName(lat, long); got != "Europe/Paris" {
+		t.Errorf("LookupZoneName = %q; want Europe/Paris", got)
+	}
+
+	b.Release()
+	if tab, _ := a.tables.Load().(*unpackedTables); tab == nil {
+		t.Error("releasing b released a's tables")
+	}
+	for _, c := range [][2]float64{{40.7128, -74.0060}, {35.6762, 139.6503}, {-33.8688, 151.2093}} {
+		if x, y := a.LookupName(c[0], c[1]), b.LookupName(c[0], c[1]); x != y {
+			t.Errorf("at %v: a = %q, b = %q", c, x, y)
+		}
+	}
+}
+
+func TestOpenMapped(t *testing.T) {
+	levels, leaves := compiledTables(t)
+	var buf bytes.Buffer
+	if err := writeTables(&buf, degPixels, levels, leaves); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "latlong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tables")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := OpenMapped(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab, _ := l.tables.Load().(*unpackedTables); tab != nil {
+		t.Error("tables unpacked before first use")
+	}
+	for _, c := range [][2]float64{{40.7128, -74.0060}, {48.8566, 2.3522}, {-33.8688, 151.2093}, {0, -140}} {
+		if got, want := l.LookupName(c[0], c[1]), LookupZoneName(c[0], c[1]); got != want {
+			t.Errorf("LookupName(%v, %v) = %q; want %q", c[0], c[1], got, want)
+		}
+	}
+	l.Release()
+	if got, want := l.LookupName(35.6762, 139.6503), "Asia/Tokyo"; got != want {
+		t.Errorf("after Release, LookupName = %q; want %q", got, want)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+	func() {
+		defer func() {
+			if e := recover(); e != errClosed {
+				t.Errorf("LookupName after Close panicked with %v; want %v", e, errClosed)
+			}
+		}()
+		l.LookupName(40.7128, -74.0060)
+	}()
+
+	// A corrupt file is rejected up front.
+	b := buf.Bytes()
+	b[len(b)/2] ^= 0xff
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMapped(path); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("OpenMapped of corrupt file = %v; want checksum error", err)
+	}
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMapped(path); err != errNotTables {
+		t.Errorf("OpenMapped of empty file = %v; want %v", err, errNotTables)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LookupZoneNameAt is like LookupZoneName, but for a time in the given
+// year, for tagging historical timestamps: timezone borders move, as
+// zones split and regions change zones. Of the compiled-in tables, it
+// uses those for the latest year no later than year, or else the
+// earliest, where tables without a year count as this year's.
+//
+// Only one set of tables is compiled in for now, so the year doesn't
+// yet change the result; see Lookuper.Year.
+func LookupZoneNameAt(lat, long float64, year int) string {
+	return lookuperForYear([]*Lookuper{defaultLookuper()}, year).LookupName(lat, long)
+}
+
+// Year returns the year whose borders l's tables have, as set by the
+// generator's --year flag or SetYear, or 0 if unknown. Tables without
+// a year are taken to have current borders.
+func (l *Lookuper) Year() int {
+	return int(atomic.LoadInt32(&l.year))
+}
+
+// SetYear sets the year whose borders l's tables have, for tables
+// read with ReadLookuper or built with NewLookuper, which don't record
+// one. It may be called while l is in use, though lookups then racing
+// with it may pick l's tables by either year.
+func (l *Lookuper) SetYear(year int) {
+	atomic.StoreInt32(&l.year, int32(year))
+}
+
+// lookuperForYear returns the Lookuper of ls, which must be non-empty,
+// whose tables have the latest year no later than year, or else the
+// one with the earliest year. Tables without a year count as this
+// year's.
+func lookuperForYear(ls []*Lookuper, year int) *Lookuper {
+	now := time.Now().Year()
+	effective := func(l *Lookuper) int {
+		if y := l.Year(); y != 0 {
+			return y
+		}
+		return now
+	}
+	var best, earliest *Lookuper
+	for _, l := range ls {
+		y := effective(l)
+		if earliest == nil || y < effective(earliest) {
+			earliest = l
+		}
+		if y <= year && (best == nil || y > effective(best)) {
+			best = l
+		}
+	}
+	if best == nil {
+		return earliest
+	}
+	return best
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2014 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latlong
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLookupZoneNameAt(t *testing.T) {
+	const lat, long = 40.7128, -74.0060
+	want := LookupZoneName(lat, long)
+	for _, year := range []int{1900, 2000, 9999} {
+		if got := LookupZoneNameAt(lat, long, year); got != want {
+			t.Errorf("LookupZoneNameAt(%v, %v, %d) = %q; want %q", lat, long, year, got, want)
+		}
+	}
+}
+
+func TestLookuperForYear(t *testing.T) {
+	ls := make([]*Lookuper, 3)
+	for i, year := range []int{2010, 0, 1990} {
+		ls[i] = &Lookuper{degPixels: -1}
+		ls[i].SetYear(year)
+	}
+	l2010, current, l1990 := ls[0], ls[1], ls[2]
+	if l1990.Year() != 1990 || current.Year() != 0 {
+		t.Fatalf("Year = %d, %d; want 1990, 0", l1990.Year(), current.Year())
+	}
+	cases := []struct {
+		year int
+		want *Lookuper
+	}{
+		{1900, l1990}, // before all: the earliest
+		{1990, l1990},
+		{2009, l1990},
+		{2010, l2010},
+		{2015, l2010},
+		{9999, current},
+	}
+	for _, tt := range cases {
+		if got := lookuperForYear(ls, tt.year); got != tt.want {
+			t.Errorf("lookuperForYear(%d) has year %d; want %d", tt.year, got.Year(), tt.want.Year())
+		}
+	}
+	if got := lookuperForYear(ls[1:2], 1900); got != current {
+		t.Errorf("lookuperForYear of only current tables = %p; want %p", got, current)
+	}
+}
+
+// Tests that SetYear may be called while lookups are in progress. Run
+// with -race.
+func TestSetYearConcurrent(t *testing.T) {
+	l := newCompiledLookuper()
+	other := &Lookuper{degPixels: -1}
+	other.SetYear(1900)
+	ls := []*Lookuper{l, other}
+	want := l.LookupName(40.7128, -74.0060)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if i == 0 {
+					l.SetYear(2000 + j)
+					continue
+				}
+				if got := lookuperForYear(ls, 9999); got != l {
+					t.Errorf("lookuperForYear(9999) picked the 1900 tables")
+					return
+				}
+				if got := l.LookupName(40.7128, -74.0060); got != want {
+					t.Errorf("LookupName = %q; want %q", got, want)
+					return
+				}
+				_ = l.Year()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := l.Year(); got != 2199 {
+		t.Errorf("Year = %d; want 2199", got)
+	}
+}
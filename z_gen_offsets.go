@@ -0,0 +1,438 @@
+// Auto-generated file. See README or Makefile.
+//
+// Standard UTC offsets as of 2026-01-01T00:00:00Z.
+
+package latlong
+
+func init() {
+	standardOffsetsAt = "2026-01-01T00:00:00Z"
+	standardOffsets = map[string]int32{
+		"Africa/Abidjan":                 0,
+		"Africa/Accra":                   0,
+		"Africa/Addis_Ababa":             10800,
+		"Africa/Algiers":                 3600,
+		"Africa/Asmara":                  10800,
+		"Africa/Bamako":                  0,
+		"Africa/Bangui":                  3600,
+		"Africa/Banjul":                  0,
+		"Africa/Bissau":                  0,
+		"Africa/Blantyre":                7200,
+		"Africa/Brazzaville":             3600,
+		"Africa/Bujumbura":               7200,
+		"Africa/Cairo":                   7200,
+		"Africa/Casablanca":              0,
+		"Africa/Ceuta":                   3600,
+		"Africa/Conakry":                 0,
+		"Africa/Dakar":                   0,
+		"Africa/Dar_es_Salaam":           10800,
+		"Africa/Djibouti":                10800,
+		"Africa/Douala":                  3600,
+		"Africa/El_Aaiun":                0,
+		"Africa/Freetown":                0,
+		"Africa/Gaborone":                7200,
+		"Africa/Harare":                  7200,
+		"Africa/Johannesburg":            7200,
+		"Africa/Juba":                    7200,
+		"Africa/Kampala":                 10800,
+		"Africa/Khartoum":                7200,
+		"Africa/Kigali":                  7200,
+		"Africa/Kinshasa":                3600,
+		"Africa/Lagos":                   3600,
+		"Africa/Libreville":              3600,
+		"Africa/Lome":                    0,
+		"Africa/Luanda":                  3600,
+		"Africa/Lubumbashi":              7200,
+		"Africa/Lusaka":                  7200,
+		"Africa/Malabo":                  3600,
+		"Africa/Maputo":                  7200,
+		"Africa/Maseru":                  7200,
+		"Africa/Mbabane":                 7200,
+		"Africa/Mogadishu":               10800,
+		"Africa/Monrovia":                0,
+		"Africa/Nairobi":                 10800,
+		"Africa/Ndjamena":                3600,
+		"Africa/Niamey":                  3600,
+		"Africa/Nouakchott":              0,
+		"Africa/Ouagadougou":             0,
+		"Africa/Porto-Novo":              3600,
+		"Africa/Sao_Tome":                0,
+		"Africa/Tripoli":                 7200,
+		"Africa/Tunis":                   3600,
+		"Africa/Windhoek":                7200,
+		"America/Adak":                   -36000,
+		"America/Anchorage":              -32400,
+		"America/Anguilla":               -14400,
+		"America/Antigua":                -14400,
+		"America/Araguaina":              -10800,
+		"America/Argentina/Buenos_Aires": -10800,
+		"America/Argentina/Catamarca":    -10800,
+		"America/Argentina/Cordoba":      -10800,
+		"America/Argentina/Jujuy":        -10800,
+		"America/Argentina/La_Rioja":     -10800,
+		"America/Argentina/Mendoza":      -10800,
+		"America/Argentina/Rio_Gallegos": -10800,
+		"America/Argentina/Salta":        -10800,
+		"America/Argentina/San_Juan":     -10800,
+		"America/Argentina/San_Luis":     -10800,
+		"America/Argentina/Tucuman":      -10800,
+		"America/Argentina/Ushuaia":      -10800,
+		"America/Aruba":                  -14400,
+		"America/Asuncion":               -10800,
+		"America/Atikokan":               -18000,
+		"America/Bahia":                  -10800,
+		"America/Bahia_Banderas":         -21600,
+		"America/Barbados":               -14400,
+		"America/Belem":                  -10800,
+		"America/Belize":                 -21600,
+		"America/Blanc-Sablon":           -14400,
+		"America/Boa_Vista":              -14400,
+		"America/Bogota":                 -18000,
+		"America/Boise":                  -25200,
+		"America/Cambridge_Bay":          -25200,
+		"America/Campo_Grande":           -14400,
+		"America/Cancun":                 -18000,
+		"America/Caracas":                -14400,
+		"America/Cayenne":                -10800,
+		"America/Cayman":                 -18000,
+		"America/Chicago":                -21600,
+		"America/Chihuahua":              -21600,
+		"America/Coral_Harbour":          -18000,
+		"America/Costa_Rica":             -21600,
+		"America/Creston":                -25200,
+		"America/Cuiaba":                 -14400,
+		"America/Curacao":                -14400,
+		"America/Danmarkshavn":           0,
+		"America/Dawson":                 -25200,
+		"America/Dawson_Creek":           -25200,
+		"America/Denver":                 -25200,
+		"America/Detroit":                -18000,
+		"America/Dominica":               -14400,
+		"America/Edmonton":               -25200,
+		"America/Eirunepe":               -18000,
+		"America/El_Salvador":            -21600,
+		"America/Fort_Nelson":            -25200,
+		"America/Fortaleza":              -10800,
+		"America/Glace_Bay":              -14400,
+		"America/Godthab":                -7200,
+		"America/Goose_Bay":              -14400,
+		"America/Grand_Turk":             -18000,
+		"America/Grenada":                -14400,
+		"America/Guadeloupe":             -14400,
+		"America/Guatemala":              -21600,
+		"America/Guayaquil":              -18000,
+		"America/Guyana":                 -14400,
+		"America/Halifax":                -14400,
+		"America/Havana":                 -18000,
+		"America/Hermosillo":             -25200,
+		"America/Indiana/Indianapolis":   -18000,
+		"America/Indiana/Knox":           -21600,
+		"America/Indiana/Marengo":        -18000,
+		"America/Indiana/Petersburg":     -18000,
+		"America/Indiana/Tell_City":      -21600,
+		"America/Indiana/Vevay":          -18000,
+		"America/Indiana/Vincennes":      -18000,
+		"America/Indiana/Winamac":        -18000,
+		"America/Inuvik":                 -25200,
+		"America/Iqaluit":                -18000,
+		"America/Jamaica":                -18000,
+		"America/Juneau":                 -32400,
+		"America/Kentucky/Louisville":    -18000,
+		"America/Kentucky/Monticello":    -18000,
+		"America/Kralendijk":             -14400,
+		"America/La_Paz":                 -14400,
+		"America/Lima":                   -18000,
+		"America/Los_Angeles":            -28800,
+		"America/Lower_Princes":          -14400,
+		"America/Maceio":                 -10800,
+		"America/Managua":                -21600,
+		"America/Manaus":                 -14400,
+		"America/Marigot":                -14400,
+		"America/Martinique":             -14400,
+		"America/Matamoros":              -21600,
+		"America/Mazatlan":               -25200,
+		"America/Menominee":              -21600,
+		"America/Merida":                 -21600,
+		"America/Metlakatla":             -32400,
+		"America/Mexico_City":            -21600,
+		"America/Miquelon":               -10800,
+		"America/Moncton":                -14400,
+		"America/Monterrey":              -21600,
+		"America/Montevideo":             -10800,
+		"America/Montreal":               -18000,
+		"America/Montserrat":             -14400,
+		"America/Nassau":                 -18000,
+		"America/New_York":               -18000,
+		"America/Nipigon":                -18000,
+		"America/Nome":                   -32400,
+		"America/Noronha":                -7200,
+		"America/North_Dakota/Beulah":    -21600,
+		"America/North_Dakota/Center":    -21600,
+		"America/North_Dakota/New_Salem": -21600,
+		"America/Ojinaga":                -21600,
+		"America/Panama":                 -18000,
+		"America/Pangnirtung":            -18000,
+		"America/Paramaribo":             -10800,
+		"America/Phoenix":                -25200,
+		"America/Port-au-Prince":         -18000,
+		"America/Port_of_Spain":          -14400,
+		"America/Porto_Velho":            -14400,
+		"America/Puerto_Rico":            -14400,
+		"America/Rainy_River":            -21600,
+		"America/Rankin_Inlet":           -21600,
+		"America/Recife":                 -10800,
+		"America/Regina":                 -21600,
+		"America/Resolute":               -21600,
+		"America/Rio_Branco":             -18000,
+		"America/Santarem":               -10800,
+		"America/Santiago":               -14400,
+		"America/Santo_Domingo":          -14400,
+		"America/Sao_Paulo":              -10800,
+		"America/Scoresbysund":           -7200,
+		"America/Sitka":                  -32400,
+		"America/St_Barthelemy":          -14400,
+		"America/St_Johns":               -12600,
+		"America/St_Kitts":               -14400,
+		"America/St_Lucia":               -14400,
+		"America/St_Thomas":              -14400,
+		"America/St_Vincent":             -14400,
+		"America/Swift_Current":          -21600,
+		"America/Tegucigalpa":            -21600,
+		"America/Thule":                  -14400,
+		"America/Thunder_Bay":            -18000,
+		"America/Tijuana":                -28800,
+		"America/Toronto":                -18000,
+		"America/Tortola":                -14400,
+		"America/Vancouver":              -28800,
+		"America/Whitehorse":             -25200,
+		"America/Winnipeg":               -21600,
+		"America/Yakutat":                -32400,
+		"America/Yellowknife":            -25200,
+		"Antarctica/Casey":               28800,
+		"Antarctica/Davis":               25200,
+		"Antarctica/DumontDUrville":      36000,
+		"Antarctica/Macquarie":           36000,
+		"Antarctica/Mawson":              18000,
+		"Antarctica/McMurdo":             43200,
+		"Antarctica/Palmer":              -10800,
+		"Antarctica/Rothera":             -10800,
+		"Antarctica/Syowa":               10800,
+		"Antarctica/Troll":               0,
+		"Antarctica/Vostok":              18000,
+		"Arctic/Longyearbyen":            3600,
+		"Asia/Aden":                      10800,
+		"Asia/Almaty":                    18000,
+		"Asia/Amman":                     10800,
+		"Asia/Anadyr":                    43200,
+		"Asia/Aqtau":                     18000,
+		"Asia/Aqtobe":                    18000,
+		"Asia/Ashgabat":                  18000,
+		"Asia/Baghdad":                   10800,
+		"Asia/Bahrain":                   10800,
+		"Asia/Baku":                      14400,
+		"Asia/Bangkok":                   25200,
+		"Asia/Barnaul":                   25200,
+		"Asia/Beirut":                    7200,
+		"Asia/Bishkek":                   21600,
+		"Asia/Brunei":                    28800,
+		"Asia/Chita":                     32400,
+		"Asia/Choibalsan":                28800,
+		"Asia/Chongqing":                 28800,
+		"Asia/Colombo":                   19800,
+		"Asia/Damascus":                  10800,
+		"Asia/Dhaka":                     21600,
+		"Asia/Dili":                      32400,
+		"Asia/Dubai":                     14400,
+		"Asia/Dushanbe":                  18000,
+		"Asia/Gaza":                      7200,
+		"Asia/Harbin":                    28800,
+		"Asia/Hebron":                    7200,
+		"Asia/Ho_Chi_Minh":               25200,
+		"Asia/Hong_Kong":                 28800,
+		"Asia/Hovd":                      25200,
+		"Asia/Irkutsk":                   28800,
+		"Asia/Jakarta":                   25200,
+		"Asia/Jayapura":                  32400,
+		"Asia/Jerusalem":                 7200,
+		"Asia/Kabul":                     16200,
+		"Asia/Kamchatka":                 43200,
+		"Asia/Karachi":                   18000,
+		"Asia/Kashgar":                   21600,
+		"Asia/Kathmandu":                 20700,
+		"Asia/Khandyga":                  32400,
+		"Asia/Kolkata":                   19800,
+		"Asia/Krasnoyarsk":               25200,
+		"Asia/Kuala_Lumpur":              28800,
+		"Asia/Kuching":                   28800,
+		"Asia/Kuwait":                    10800,
+		"Asia/Macau":                     28800,
+		"Asia/Magadan":                   39600,
+		"Asia/Makassar":                  28800,
+		"Asia/Manila":                    28800,
+		"Asia/Muscat":                    14400,
+		"Asia/Nicosia":                   7200,
+		"Asia/Novokuznetsk":              25200,
+		"Asia/Novosibirsk":               25200,
+		"Asia/Omsk":                      21600,
+		"Asia/Oral":                      18000,
+		"Asia/Phnom_Penh":                25200,
+		"Asia/Pontianak":                 25200,
+		"Asia/Pyongyang":                 32400,
+		"Asia/Qatar":                     10800,
+		"Asia/Qyzylorda":                 18000,
+		"Asia/Rangoon":                   23400,
+		"Asia/Riyadh":                    10800,
+		"Asia/Sakhalin":                  39600,
+		"Asia/Samarkand":                 18000,
+		"Asia/Seoul":                     32400,
+		"Asia/Shanghai":                  28800,
+		"Asia/Singapore":                 28800,
+		"Asia/Srednekolymsk":             39600,
+		"Asia/Taipei":                    28800,
+		"Asia/Tashkent":                  18000,
+		"Asia/Tbilisi":                   14400,
+		"Asia/Tehran":                    12600,
+		"Asia/Thimphu":                   21600,
+		"Asia/Tokyo":                     32400,
+		"Asia/Tomsk":                     25200,
+		"Asia/Ulaanbaatar":               28800,
+		"Asia/Urumqi":                    21600,
+		"Asia/Ust-Nera":                  36000,
+		"Asia/Vientiane":                 25200,
+		"Asia/Vladivostok":               36000,
+		"Asia/Yakutsk":                   32400,
+		"Asia/Yekaterinburg":             18000,
+		"Asia/Yerevan":                   14400,
+		"Atlantic/Azores":                -3600,
+		"Atlantic/Bermuda":               -14400,
+		"Atlantic/Canary":                0,
+		"Atlantic/Cape_Verde":            -3600,
+		"Atlantic/Faroe":                 0,
+		"Atlantic/Madeira":               0,
+		"Atlantic/Reykjavik":             0,
+		"Atlantic/South_Georgia":         -7200,
+		"Atlantic/St_Helena":             0,
+		"Atlantic/Stanley":               -10800,
+		"Australia/Adelaide":             34200,
+		"Australia/Brisbane":             36000,
+		"Australia/Broken_Hill":          34200,
+		"Australia/Currie":               36000,
+		"Australia/Darwin":               34200,
+		"Australia/Eucla":                31500,
+		"Australia/Hobart":               36000,
+		"Australia/Lindeman":             36000,
+		"Australia/Lord_Howe":            37800,
+		"Australia/Melbourne":            36000,
+		"Australia/Perth":                28800,
+		"Australia/Sydney":               36000,
+		"Europe/Amsterdam":               3600,
+		"Europe/Andorra":                 3600,
+		"Europe/Astrakhan":               14400,
+		"Europe/Athens":                  7200,
+		"Europe/Belgrade":                3600,
+		"Europe/Berlin":                  3600,
+		"Europe/Bratislava":              3600,
+		"Europe/Brussels":                3600,
+		"Europe/Bucharest":               7200,
+		"Europe/Budapest":                3600,
+		"Europe/Busingen":                3600,
+		"Europe/Chisinau":                7200,
+		"Europe/Copenhagen":              3600,
+		"Europe/Dublin":                  0,
+		"Europe/Gibraltar":               3600,
+		"Europe/Guernsey":                0,
+		"Europe/Helsinki":                7200,
+		"Europe/Isle_of_Man":             0,
+		"Europe/Istanbul":                10800,
+		"Europe/Jersey":                  0,
+		"Europe/Kaliningrad":             7200,
+		"Europe/Kiev":                    7200,
+		"Europe/Kirov":                   10800,
+		"Europe/Lisbon":                  0,
+		"Europe/Ljubljana":               3600,
+		"Europe/London":                  0,
+		"Europe/Luxembourg":              3600,
+		"Europe/Madrid":                  3600,
+		"Europe/Malta":                   3600,
+		"Europe/Mariehamn":               7200,
+		"Europe/Minsk":                   10800,
+		"Europe/Monaco":                  3600,
+		"Europe/Moscow":                  10800,
+		"Europe/Oslo":                    3600,
+		"Europe/Paris":                   3600,
+		"Europe/Podgorica":               3600,
+		"Europe/Prague":                  3600,
+		"Europe/Riga":                    7200,
+		"Europe/Rome":                    3600,
+		"Europe/Samara":                  14400,
+		"Europe/San_Marino":              3600,
+		"Europe/Sarajevo":                3600,
+		"Europe/Simferopol":              10800,
+		"Europe/Skopje":                  3600,
+		"Europe/Sofia":                   7200,
+		"Europe/Stockholm":               3600,
+		"Europe/Tallinn":                 7200,
+		"Europe/Tirane":                  3600,
+		"Europe/Ulyanovsk":               14400,
+		"Europe/Uzhgorod":                7200,
+		"Europe/Vaduz":                   3600,
+		"Europe/Vatican":                 3600,
+		"Europe/Vienna":                  3600,
+		"Europe/Vilnius":                 7200,
+		"Europe/Volgograd":               10800,
+		"Europe/Warsaw":                  3600,
+		"Europe/Zagreb":                  3600,
+		"Europe/Zaporozhye":              7200,
+		"Europe/Zurich":                  3600,
+		"Indian/Antananarivo":            10800,
+		"Indian/Chagos":                  21600,
+		"Indian/Christmas":               25200,
+		"Indian/Cocos":                   23400,
+		"Indian/Comoro":                  10800,
+		"Indian/Kerguelen":               18000,
+		"Indian/Mahe":                    14400,
+		"Indian/Maldives":                18000,
+		"Indian/Mauritius":               14400,
+		"Indian/Mayotte":                 10800,
+		"Indian/Reunion":                 14400,
+		"Pacific/Apia":                   46800,
+		"Pacific/Auckland":               43200,
+		"Pacific/Bougainville":           39600,
+		"Pacific/Chatham":                45900,
+		"Pacific/Chuuk":                  36000,
+		"Pacific/Easter":                 -21600,
+		"Pacific/Efate":                  39600,
+		"Pacific/Enderbury":              46800,
+		"Pacific/Fakaofo":                46800,
+		"Pacific/Fiji":                   43200,
+		"Pacific/Funafuti":               43200,
+		"Pacific/Galapagos":              -21600,
+		"Pacific/Gambier":                -32400,
+		"Pacific/Guadalcanal":            39600,
+		"Pacific/Guam":                   36000,
+		"Pacific/Honolulu":               -36000,
+		"Pacific/Johnston":               -36000,
+		"Pacific/Kiritimati":             50400,
+		"Pacific/Kosrae":                 39600,
+		"Pacific/Kwajalein":              43200,
+		"Pacific/Majuro":                 43200,
+		"Pacific/Marquesas":              -34200,
+		"Pacific/Midway":                 -39600,
+		"Pacific/Nauru":                  43200,
+		"Pacific/Niue":                   -39600,
+		"Pacific/Norfolk":                39600,
+		"Pacific/Noumea":                 39600,
+		"Pacific/Pago_Pago":              -39600,
+		"Pacific/Palau":                  32400,
+		"Pacific/Pitcairn":               -28800,
+		"Pacific/Pohnpei":                39600,
+		"Pacific/Port_Moresby":           36000,
+		"Pacific/Rarotonga":              -36000,
+		"Pacific/Saipan":                 36000,
+		"Pacific/Tahiti":                 -36000,
+		"Pacific/Tarawa":                 43200,
+		"Pacific/Tongatapu":              46800,
+		"Pacific/Wake":                   43200,
+		"Pacific/Wallis":                 43200,
+		"Pacific/Yap":                    36000,
+	}
+}
@@ -4,7 +4,7 @@ package latlong
 
 func init() {
 	degPixels = 32
-	zoomLevels = [6]*zoomLevel{
+	zoomLevels = []*zoomLevel{
 		5: &zoomLevel{
 			gzipData: "H4sIAAAAAAAA/yTPP2ukZRQF8HOf592sJtmdnUyyO7szk5lNMpnJzIgsaGF3a7E4oCD4CcRCkVWLoOEiJojgHxQE/yCCn0D8ArcRtfATWFso2GillTxnm1/xvufeex4Cu7ggcEPexHcEeniFwC3Zl3tyIPebvoWfCL8utcE15ZpyTbmm/EDewS+ED+VdeU+O5NieJ3wiD+VUzvAP4fflkTyWc7tH+MLuEr6UZ3LVDOBXIiqeJkI9Qw1Dbwy9MdQ21DbUNtQ29u2YiIPWKu7YC0SMWpNQw1DDmNqICLUKtQr1ibXubpqp66krqSupK6krOWhXUrfytj1HZLtiAJ6ioc0atvEjDWv8RcOmfY8tXNHiutzGi7S4gS9psWcTWgzkfbtNi7nt0GJhA1osmwn7mJY37ZCWQzyg5TF+o6Uy2TIFxd5nQZUz/M2CU31f2zcs/jg+ZfEenmXxgc1YfNoyrqSf4HcWn+M/Fj+VK/uQxdfNKPYBS+zgJZZY2rcscWbvsMTGvmZJWLCkSSWzym17jyV3ZB8vs+QYS5ac2BssObU3WfIIf7DkHP+y5AJ/suTKrlhybZ+wZNtfUewLVrSdFZ19xIprcheXrNi3OSum9horjux1VrRtFW1Pdc16ta9YvZOa8oM25WN7ldUP7S1WP7EHrD6XS1uy+so+Y/WNvcsasHPW0J7Qnrhml6yxZZ+zxmN2wRq7+J41buEH1ujLUdsfuhLaH9qfSqaSqf45xJA1Z/aQNZXMR8mFLVhzZU+y5sbO2QH2Njv08Aw7tFd0OLMn2GGtv8p4z07Zed9O2PkIQ3Y+tofsfCKnUnlXPpSMPTmQK/z8fwAAAP//lMWldFwEAAA=",
 		},